@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/pipeline"
+	"gamenet/internal/pkg/wiki"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// runDLQ implements "gamenet dlq list|retry|purge".
+func runDLQ(args []string) {
+	flags := flag.NewFlagSet("dlq", flag.ExitOnError)
+	stage := flags.String("stage", "", "only dead letters of this stage (extract, store or graph)")
+	class := flags.String("class", "", "only dead letters with this error class, e.g. ner_timeout")
+	ids := flags.String("id", "", "only these dead letters (comma-separated IDs)")
+	limit := flags.Int("limit", 0, "at most this many dead letters (list and retry)")
+	all := flags.Bool("all", false, "purge every dead letter when no other filter is given")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gamenet dlq list|retry|purge [-stage S] [-class C] [-id 1,2] [-limit N] [-all]")
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	action := args[0]
+	flags.Parse(args[1:])
+
+	filter := db.DeadLetterFilter{Stage: *stage, ErrorClass: *class, Limit: *limit}
+	if *ids != "" {
+		for _, field := range strings.Split(*ids, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+			if err != nil {
+				log.Fatalf("Invalid dead letter ID %q", field)
+			}
+			filter.IDs = append(filter.IDs, id)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	pgConn, err := db.InitPostgres()
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer pgConn.Close()
+
+	switch action {
+	case "list":
+		letters, err := db.ListDeadLetters(ctx, pgConn, filter)
+		if err != nil {
+			log.Fatalf("Failed to list dead letters: %v", err)
+		}
+		for _, letter := range letters {
+			fmt.Printf("%6d  %-8s %-28s %3d  %s  %s (page %d)\n      %s\n", letter.ID, letter.Stage, letter.ErrorClass,
+				letter.Attempts, letter.LastFailedAt.Format("2006-01-02 15:04:05"), letter.Title, letter.PageID, letter.Error)
+		}
+		fmt.Printf("%d dead letters\n", len(letters))
+
+	case "retry":
+		retryDeadLetters(ctx, pgConn, filter)
+
+	case "purge":
+		if filter.Stage == "" && filter.ErrorClass == "" && len(filter.IDs) == 0 && !*all {
+			log.Fatalf("Refusing to purge every dead letter without -all")
+		}
+		filter.Limit = 0
+		purged, err := db.PurgeDeadLetters(ctx, pgConn, filter)
+		if err != nil {
+			log.Fatalf("Failed to purge dead letters: %v", err)
+		}
+		fmt.Printf("Purged %d dead letters\n", purged)
+
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// retryDeadLetters replays the dead letters matching filter through the pipeline:
+// extraction failures from their stored page, storage failures from their stored game.
+// Graph failures are written to Neo4j again as PostgreSQL stores the game now.
+// Letters that succeed are removed; those that fail again have their attempts bumped.
+func retryDeadLetters(ctx context.Context, pgConn *sql.DB, filter db.DeadLetterFilter) {
+	letters, err := db.ListDeadLetters(ctx, pgConn, filter)
+	if err != nil {
+		log.Fatalf("Failed to list dead letters: %v", err)
+	}
+	if len(letters) == 0 {
+		fmt.Println("No dead letters to retry.")
+		return
+	}
+
+	graph, err := newGraphWriter()
+	if err != nil {
+		log.Fatalf("Failed to set up Neo4j: %v", err)
+	}
+	if graph != nil {
+		defer db.CloseNeo4j()
+	}
+
+	extractor, closeExtractor, err := newExtractor(ctx, pgConn, newIngestConfig().Extractor)
+	if err != nil {
+		log.Fatalf("Failed to set up entity extractor: %v", err)
+	}
+	defer closeExtractor()
+
+	// Pages go through extraction again; games only need to be stored
+	games := make(chan wiki.GameRecord)
+	source := pipeline.Source(ctx, "dlq", 0, func(ctx context.Context, emit func(wiki.Page) error) error {
+		defer close(games)
+		for _, letter := range letters {
+			var err error
+			switch {
+			case letter.Stage == db.StageGraph:
+				continue
+			case letter.Page != nil:
+				err = emit(*letter.Page)
+			case letter.Game != nil:
+				select {
+				case games <- *letter.Game:
+				case <-ctx.Done():
+					err = ctx.Err()
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err := processPages(ctx, pgConn, graph, extractor, nil, source, games); err != nil {
+		log.Printf("Stopped retrying dead letters: %v", err)
+	}
+	retryGraphLetters(ctx, pgConn, graph, letters)
+
+	// Whatever is still there failed again
+	filter.IDs = make([]int64, len(letters))
+	for i, letter := range letters {
+		filter.IDs[i] = letter.ID
+	}
+	filter.Limit = 0
+	remaining, err := db.ListDeadLetters(context.Background(), pgConn, filter)
+	if err != nil {
+		log.Fatalf("Failed to list dead letters: %v", err)
+	}
+	fmt.Printf("Retried %d dead letters: %d cleared, %d failed again\n",
+		len(letters), len(letters)-len(remaining), len(remaining))
+}
+
+// deadLetter records the item of a failed stage as a dead letter. Items abandoned
+// because the pipeline was cancelled are left to a resumed run instead.
+func deadLetter(pgConn *sql.DB, run *db.IngestRun, stageErr *pipeline.StageError) {
+	if errors.Is(stageErr.Err, context.Canceled) {
+		return
+	}
+	var runID int64
+	if run != nil {
+		runID = run.ID
+	}
+	if err := db.RecordDeadLetter(context.Background(), pgConn, stageErr.Stage, stageErr.Item, stageErr.Err, runID); err != nil {
+		log.Print(err)
+	}
+}
+
+// retryGraphLetters writes the games of the graph dead letters among letters to Neo4j
+// from PostgreSQL, which may hold a newer version than the game that failed.
+func retryGraphLetters(ctx context.Context, pgConn *sql.DB, graph *db.GraphWriter, letters []db.DeadLetter) {
+	var pageIDs []int
+	for _, letter := range letters {
+		if letter.Stage == db.StageGraph {
+			pageIDs = append(pageIDs, letter.PageID)
+		}
+	}
+	if len(pageIDs) == 0 {
+		return
+	}
+	if graph == nil {
+		log.Printf("Neo4j is not configured, leaving %d graph dead letters", len(pageIDs))
+		return
+	}
+
+	failed := make(map[int]bool)
+	fail := func(err error) {
+		var batchErr *db.GraphBatchError
+		if errors.As(err, &batchErr) {
+			for _, game := range batchErr.Games {
+				failed[game.PageID] = true
+			}
+		}
+		deadLetterGraphBatch(pgConn, nil, err)
+	}
+	for _, pageID := range pageIDs {
+		game, err := db.StoredGraphGame(ctx, pgConn, pageID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// The game is gone; "gamenet graph sync" removes it from Neo4j
+			continue
+		case err != nil:
+			log.Printf("Failed to read page %d from PostgreSQL: %v", pageID, err)
+			failed[pageID] = true
+			continue
+		}
+		if err := graph.Write(game); err != nil {
+			fail(err)
+		}
+	}
+	if err := graph.Flush(); err != nil {
+		fail(err)
+	}
+
+	for _, pageID := range pageIDs {
+		if !failed[pageID] {
+			resolveDeadLetters(pgConn, pageID, db.StageGraph)
+		}
+	}
+}
+
+// deadLetterGraphBatch records every game of a batch Neo4j rejected as a graph dead
+// letter. err is the error GraphWriter returned for the batch.
+func deadLetterGraphBatch(pgConn *sql.DB, run *db.IngestRun, err error) {
+	var batchErr *db.GraphBatchError
+	if !errors.As(err, &batchErr) {
+		log.Printf("Failed to write to Neo4j: %v", err)
+		return
+	}
+	var runID int64
+	if run != nil {
+		runID = run.ID
+	}
+	pageIDs := make([]string, len(batchErr.Games))
+	for i, game := range batchErr.Games {
+		pageIDs[i] = strconv.Itoa(game.PageID)
+		record := wiki.GameRecord{PageID: game.PageID, Title: game.Title, Entities: game.Entities}
+		if err := db.RecordDeadLetter(context.Background(), pgConn, db.StageGraph, record, batchErr.Err, runID); err != nil {
+			log.Print(err)
+		}
+	}
+	log.Printf("Failed to write %d games to Neo4j (pages %s): %v; run \"gamenet dlq retry -stage graph\" or \"gamenet graph sync\" to write them",
+		len(batchErr.Games), strings.Join(pageIDs, ", "), batchErr.Err)
+}
+
+// resolveDeadLetters removes the dead letters of a page that got through stage, logging
+// rather than failing when they cannot be removed.
+func resolveDeadLetters(pgConn *sql.DB, pageID int, stage string) {
+	if err := db.ResolveDeadLetters(context.Background(), pgConn, pageID, stage); err != nil {
+		log.Print(err)
+	}
+}
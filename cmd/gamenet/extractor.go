@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gamenet/internal/pkg/wiki"
+	"log"
+	"os"
+)
+
+// entityNames canonicalizes the entities of every extracted game. It is set up by newExtractor.
+var entityNames *wiki.Canonicalizer
+
+// labelReport counts how the labels of the process's extractions were mapped and
+// which entities were lost, for the ingestion run summary.
+var labelReport = &wiki.LabelReport{}
+
+// newExtractor builds the entity extractor of the given kind ("ner" or "gazetteer"), as
+// configured by ENTITY_EXTRACTOR, and the canonicalizer its entities go through. NER
+// labels are mapped with the rules in NER_LABEL_MAP, or the built-in ones. The
+// returned cleanup function releases any resources the extractor holds.
+func newExtractor(ctx context.Context, pgConn *sql.DB, kind string) (wiki.EntityExtractor, func(), error) {
+	names, err := newCanonicalizer(ctx, pgConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	entityNames = names
+
+	switch kind {
+	case "", "ner":
+		// Start the NER worker processes once so every page reuses the loaded model
+		config := wiki.NERPoolConfigFromEnv()
+		if path := os.Getenv("NER_LABEL_MAP"); path != "" {
+			rules, err := wiki.LoadLabelRulesFile(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			config.Labels = wiki.NewLabelMap(rules)
+		}
+		config.LabelReport = labelReport
+		nerPool, err := wiki.NewNERPool(config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to start NER workers: %v", err)
+		}
+		wiki.SetDefaultNERPool(nerPool)
+		return nerPool, func() { nerPool.Close() }, nil
+
+	case "gazetteer":
+		// Combine the built-in seed list with a configured file and the names already in PostgreSQL
+		entries := wiki.DefaultGazetteerEntries()
+		if path := os.Getenv("GAZETTEER_FILE"); path != "" {
+			fileEntries, err := wiki.LoadGazetteerFile(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			entries = append(entries, fileEntries...)
+		}
+		dbEntries, err := wiki.LoadGazetteerPostgres(ctx, pgConn)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, dbEntries...)
+
+		gazetteer := wiki.NewGazetteer(entries)
+		log.Printf("Loaded gazetteer with %d names", gazetteer.Len())
+		return gazetteer, func() {}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown ENTITY_EXTRACTOR %q (expected ner or gazetteer)", kind)
+	}
+}
+
+// newCanonicalizer combines the built-in alias list with ENTITY_ALIASES_FILE and seeds
+// it with the entities already in PostgreSQL, so new spellings map to existing rows.
+func newCanonicalizer(ctx context.Context, pgConn *sql.DB) (*wiki.Canonicalizer, error) {
+	aliases := wiki.DefaultEntityAliases()
+	if path := os.Getenv("ENTITY_ALIASES_FILE"); path != "" {
+		fileAliases, err := wiki.LoadEntityAliasesFile(path)
+		if err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, fileAliases...)
+	}
+
+	names := wiki.NewCanonicalizer(aliases)
+	stored, err := wiki.LoadEntitiesPostgres(ctx, pgConn)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range stored {
+		names.Add(entity)
+	}
+	log.Printf("Loaded %d entity aliases and %d stored entities", len(aliases), len(stored))
+	return names, nil
+}
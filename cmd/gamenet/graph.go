@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"gamenet/internal/pkg/db"
+	"log"
+	"os"
+	"time"
+)
+
+// runGraph implements "gamenet graph sync".
+func runGraph(args []string) {
+	flags := flag.NewFlagSet("graph sync", flag.ExitOnError)
+	full := flags.Bool("full", false, "compare every game (the default unless -incremental or -since is given)")
+	incremental := flags.Bool("incremental", false, "only compare games updated since the last sync")
+	since := flags.String("since", "", "only compare games updated after this RFC 3339 time")
+	dryRun := flags.Bool("dry-run", false, "report the diff without changing Neo4j")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gamenet graph sync [-full | -incremental | -since TIME] [-dry-run]")
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 || args[0] != "sync" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	flags.Parse(args[1:])
+	if *full && (*incremental || *since != "") {
+		log.Fatalf("-full cannot be combined with -incremental or -since")
+	}
+
+	pgConn, err := db.InitPostgres()
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer pgConn.Close()
+
+	if err := db.InitNeo4j(); err != nil {
+		log.Fatalf("Failed to connect to Neo4j: %v", err)
+	}
+	defer db.CloseNeo4j()
+
+	if err := db.NewGraphWriter(db.Neo4jDriver).EnsureGraphSchema(); err != nil {
+		log.Fatalf("Failed to prepare Neo4j: %v", err)
+	}
+
+	opts := db.GraphSyncOptions{DryRun: *dryRun}
+	switch {
+	case *since != "":
+		opts.Since, err = time.Parse(time.RFC3339, *since)
+		if err != nil {
+			log.Fatalf("Invalid -since: %v", err)
+		}
+	case *incremental:
+		opts.Since, err = db.LastGraphSync(db.Neo4jDriver)
+		if err != nil {
+			log.Fatalf("Failed to read the last sync time: %v", err)
+		}
+		if opts.Since.IsZero() {
+			log.Println("Graph has never been synced, running a full sync")
+		}
+	}
+
+	report, err := db.SyncGraph(context.Background(), pgConn, db.Neo4jDriver, opts)
+	if err != nil {
+		log.Fatalf("Graph sync failed: %v", err)
+	}
+	fmt.Println(report)
+
+	if !opts.DryRun {
+		if err := db.RecordGraphSync(db.Neo4jDriver, report.SyncedAt); err != nil {
+			log.Fatalf("Failed to record the sync time: %v", err)
+		}
+	}
+
+	// A full sync wrote every stored game, including those whose batch failed during ingestion
+	if !opts.DryRun && report.Full {
+		if _, err := db.PurgeDeadLetters(context.Background(), pgConn, db.DeadLetterFilter{Stage: db.StageGraph}); err != nil {
+			log.Fatalf("Failed to clear graph dead letters: %v", err)
+		}
+	}
+}
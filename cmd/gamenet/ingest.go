@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runIngest implements "gamenet ingest [-resume RUN] [-dump FILE]": it crawls Wikipedia once,
+// without serving HTTP, and exits when the crawl is done or interrupted. The exit
+// status is non-zero unless the run completed.
+func runIngest(args []string) {
+	if status := ingest(args); status != db.RunCompleted {
+		os.Exit(1)
+	}
+}
+
+// ingest sets up the databases and extractor, runs one ingestion and returns its final status.
+func ingest(args []string) string {
+	flags := flag.NewFlagSet("ingest", flag.ExitOnError)
+	resume := flags.Int64("resume", 0, "ID of an interrupted or failed run to continue")
+	dump := flags.String("dump", "", "read pages from a pages-articles.xml(.bz2) dump instead of the API")
+	flags.BoolVar(&wikiOffline, "offline", wikiOffline, "serve every Wikipedia request from WIKI_CACHE_DIR without network access")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gamenet ingest [-resume RUN] [-dump FILE] [-offline]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *resume != 0 && *dump != "" {
+		log.Fatal("-dump cannot be combined with -resume; a resumed run reads the source it started with")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	pgConn, err := db.InitPostgres()
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer pgConn.Close()
+
+	if _, err := db.MigrateUp(ctx, pgConn); err != nil {
+		log.Fatalf("Failed to migrate PostgreSQL schema: %v", err)
+	}
+
+	// A resumed run keeps the configuration it was started with
+	var run *db.IngestRun
+	if *resume != 0 {
+		run, err = db.ResumeIngestRun(ctx, pgConn, *resume)
+	} else {
+		config := newIngestConfig()
+		config.Dump = *dump
+		run, err = db.StartIngestRun(ctx, pgConn, config)
+	}
+	if err != nil {
+		log.Fatalf("Failed to start ingestion run: %v", err)
+	}
+	defer run.Close()
+
+	graph, err := newGraphWriter()
+	if err != nil {
+		log.Fatalf("Failed to set up Neo4j: %v", err)
+	}
+	if graph != nil {
+		defer db.CloseNeo4j()
+	}
+
+	extractor, closeExtractor, err := newExtractor(ctx, pgConn, run.Config.Extractor)
+	if err != nil {
+		log.Fatalf("Failed to set up entity extractor: %v", err)
+	}
+	defer closeExtractor()
+
+	return runIngestion(ctx, pgConn, graph, extractor, run)
+}
+
+// runIngestion runs the pipeline for run and records how it ended: interrupted when
+// ctx was cancelled, failed when the crawl stopped on an error, completed otherwise.
+// It returns the final status.
+func runIngestion(ctx context.Context, pgConn *sql.DB, graph *db.GraphWriter, extractor wiki.EntityExtractor, run *db.IngestRun) string {
+	if run.Config.Dump != "" {
+		log.Printf("Starting ingestion run %d of %s from %s", run.ID, run.Config.Category, run.Config.Dump)
+	} else {
+		log.Printf("Starting ingestion run %d of %s", run.ID, run.Config.Category)
+	}
+
+	err := runPipeline(ctx, pgConn, graph, extractor, run)
+
+	status := db.RunCompleted
+	switch {
+	case ctx.Err() != nil || errors.Is(err, context.Canceled):
+		status = db.RunInterrupted
+	case err != nil:
+		status = db.RunFailed
+	}
+
+	// ctx may already be cancelled, but the outcome must still be recorded
+	if err := run.Finish(context.Background(), status); err != nil {
+		log.Printf("Failed to record the end of run %d: %v", run.ID, err)
+	}
+	counts, err := run.PageCounts(context.Background())
+	if err != nil {
+		log.Printf("Failed to count pages of run %d: %v", run.ID, err)
+	}
+	log.Printf("Ingestion run %d %s: %d stored, %d failed", run.ID, status, counts[db.PageStored], counts[db.PageFailed])
+	labels := labelReport.Counts()
+	if err := run.AddLabelCounts(context.Background(), labels); err != nil {
+		log.Printf("Failed to record the label report of run %d: %v", run.ID, err)
+	}
+	log.Printf("Entity labels of run %d: %s", run.ID, labels)
+	if status != db.RunCompleted {
+		log.Printf("Continue it with: gamenet ingest -resume %d", run.ID)
+	}
+	return status
+}
+
+// newIngestConfig builds the configuration of a new run from WIKI_CATEGORY,
+// WIKI_API_URL, WIKI_CATEGORY_DEPTH, WIKI_INFOBOX and ENTITY_EXTRACTOR.
+func newIngestConfig() db.IngestConfig {
+	client := wiki.NewClientFromEnv()
+	extractor := os.Getenv("ENTITY_EXTRACTOR")
+	if extractor == "" {
+		extractor = "ner"
+	}
+	return db.IngestConfig{
+		Category:      wikiCategory(),
+		APIURL:        client.BaseURL,
+		CategoryDepth: client.MaxDepth,
+		Infobox:       client.IncludeWikitext,
+		Extractor:     extractor,
+	}
+}
+
+// wikiOffline is set by "gamenet ingest -offline" to answer every Wikipedia request from the cache.
+var wikiOffline bool
+
+// newRunClient returns a Wikipedia client configured like run that skips the pages
+// the run has already stored.
+func newRunClient(ctx context.Context, run *db.IngestRun) (*wiki.Client, error) {
+	skip, err := skipStoredPages(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+
+	// Etiquette settings (rate limit, User-Agent, ...) come from the environment, not the run
+	client := wiki.NewClientFromEnv()
+	client.BaseURL = run.Config.APIURL
+	client.MaxDepth = run.Config.CategoryDepth
+	client.IncludeWikitext = run.Config.Infobox
+	client.SkipPage = skip
+
+	if wikiOffline {
+		if client.Cache == nil {
+			return nil, errors.New("offline mode needs a cache directory in WIKI_CACHE_DIR")
+		}
+		client.Cache.Offline = true
+	}
+	if client.Cache != nil {
+		mode := "online"
+		if client.Cache.Offline {
+			mode = "offline"
+		}
+		log.Printf("Caching Wikipedia responses in %s (%s)", client.Cache.Dir, mode)
+	}
+	return client, nil
+}
+
+// newRunDumpReader returns a reader of the run's XML dump that keeps the pages of the
+// run's category and skips the pages the run has already stored.
+func newRunDumpReader(ctx context.Context, run *db.IngestRun) (*wiki.DumpReader, error) {
+	skip, err := skipStoredPages(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := wiki.NewDumpReader(run.Config.Category)
+	reader.IncludeWikitext = run.Config.Infobox
+	reader.SkipPage = skip
+	return reader, nil
+}
+
+// skipStoredPages returns a SkipPage function matching the pages run has already stored.
+func skipStoredPages(ctx context.Context, run *db.IngestRun) (func(int) bool, error) {
+	stored, err := run.StoredPages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) > 0 {
+		log.Printf("Skipping %d pages already stored by run %d", len(stored), run.ID)
+	}
+	return func(pageID int) bool { return stored[pageID] }, nil
+}
+
+// markPage records the state of a page in run, logging rather than failing when the
+// state cannot be written. Pages processed outside of a run (nil) are not recorded.
+func markPage(run *db.IngestRun, pageID int, title, state string, cause error) {
+	if run == nil {
+		return
+	}
+	if err := run.MarkPage(context.Background(), pageID, title, state, cause); err != nil {
+		log.Print(err)
+	}
+}
@@ -1,153 +1,303 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/pipeline"
 	"gamenet/internal/pkg/wiki"
 	"log"
-	"sync"
+	"os"
+	"strconv"
+	"time"
 )
 
-// GameData structure to hold game title, description, and extracted entities
-type GameData struct {
-	Title       string
-	Description string
-	Entities    []wiki.Entity
-}
+// usage describes the available commands.
+const usage = `Usage: gamenet [command]
+
+Commands:
+  (none)                     Serve HTTP on :8080 while crawling Wikipedia into PostgreSQL
+  ingest [-resume RUN]       Crawl Wikipedia into PostgreSQL once, or resume an earlier run;
+                             -dump FILE reads a pages-articles.xml(.bz2) dump instead of the API,
+                             -offline serves Wikipedia from WIKI_CACHE_DIR only
+  refresh [-dry-run]         Re-extract games whose Wikipedia page changed; drop deleted ones
+  migrate up|down|status     Apply, revert or list database schema migrations
+  graph sync                 Reconcile the Neo4j graph with PostgreSQL
+  similar TITLE              List the games most similar to a game
+  path FROM TO               Show a shortest connection between two games or entities
+  dlq list|retry|purge       Inspect, replay or drop pages that failed extraction or storage
+`
 
 func main() {
-	// Initialize a connection to the PostgreSQL database
-	pgConn, err := db.InitPostgres()
-	if err != nil {
-		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	command := ""
+	if len(os.Args) > 1 {
+		command = os.Args[1]
 	}
-	defer pgConn.Close() // Close the database connection when the program ends
-
-	// Channels for coordinating between goroutines
-	wikiChannel := make(chan wiki.WikiResponse) // Channel to pass fetched Wikipedia data
-	nerChannel := make(chan GameData)           // Channel to pass processed NER (Named Entity Recognition) data
-	doneChannel := make(chan bool)              // Channel to signal when all tasks are completed
 
-	var wg sync.WaitGroup // WaitGroup to wait for all goroutines to finish
+	switch command {
+	case "":
+		runService()
+	case "ingest":
+		runIngest(os.Args[2:])
+	case "refresh":
+		runRefresh(os.Args[2:])
+	case "migrate":
+		runMigrate(os.Args[2:])
+	case "graph":
+		runGraph(os.Args[2:])
+	case "dlq":
+		runDLQ(os.Args[2:])
+	case "similar":
+		runSimilar(os.Args[2:])
+	case "path":
+		runPath(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
 
-	// Start the goroutine for fetching Wikipedia data
-	wg.Add(1)
-	go fetchWikipediaData(wikiChannel, &wg) // Fetch Wikipedia data and send it to the wikiChannel
+// runPipeline crawls Wikipedia, extracts entities from every page and stores the results in PostgreSQL,
+// recording the progress of every page in run. Pages the run already stored are skipped.
+// Runs configured with an XML dump read their pages from it instead of the API.
+// When graph is not nil, every game PostgreSQL stored is then written to Neo4j as well.
+//
+// Each stage runs the number of workers configured by newPipelineConfig, connected by bounded
+// channels so a slow stage holds back the ones before it. Cancelling ctx stops the crawl; pages
+// already fetched are still processed and stored, unless that takes longer than pipelineDrainTimeout.
+// The returned error is the reason the crawl stopped early, if any.
+func runPipeline(ctx context.Context, pgConn *sql.DB, graph *db.GraphWriter, extractor wiki.EntityExtractor, run *db.IngestRun) error {
+	if run.Config.Dump != "" {
+		return runDumpPipeline(ctx, pgConn, graph, extractor, run)
+	}
 
-	// Start the goroutine for processing NER on the fetched Wikipedia data
-	wg.Add(1)
-	go processNER(wikiChannel, nerChannel, &wg) // Process the NER and pass results to nerChannel
+	client, err := newRunClient(ctx, run)
+	if err != nil {
+		return err
+	}
 
-	// Start the goroutine for inserting game data and entities into the database
-	wg.Add(1)
-	go insertGameData(pgConn, nerChannel, doneChannel, &wg) // Insert data into the database, signaled by nerChannel
+	// Fetch pages from Wikipedia, then extract their entities and store the games
+	fetch := pipeline.Source(ctx, "fetch", newPipelineConfig().FetchBuffer, func(ctx context.Context, emit func(wiki.Page) error) error {
+		return fetchWikipediaData(ctx, client, run, emit)
+	})
+	err = processPages(ctx, pgConn, graph, extractor, run, fetch, nil)
 
-	// Wait for all goroutines to complete
-	wg.Wait()
-	close(doneChannel) // Close the doneChannel when all goroutines are finished
-	fmt.Println("All tasks completed.")
+	stats := client.Stats()
+	log.Printf("Wikipedia requests: %d sent, %d retried, %d throttled", stats.Requests, stats.Retries, stats.Throttled)
+	if client.Cache != nil {
+		cacheStats := client.Cache.Stats()
+		log.Printf("Wikipedia cache: %d hits, %d misses, %d revalidated", cacheStats.Hits, cacheStats.Misses, cacheStats.NotModified)
+	}
+	return err
 }
 
-// fetchWikipediaData fetches data from Wikipedia and sends it through the wikiChannel.
-// This is executed as a goroutine.
-func fetchWikipediaData(wikiChannel chan<- wiki.WikiResponse, wg *sync.WaitGroup) {
-	defer wg.Done() // Mark this goroutine as done when function completes
-
-	// Fetch data from Wikipedia
-	wikiData, err := wiki.FetchWikiData("video_game")
+// runDumpPipeline is runPipeline for runs reading a Wikipedia XML dump instead of the API.
+func runDumpPipeline(ctx context.Context, pgConn *sql.DB, graph *db.GraphWriter, extractor wiki.EntityExtractor, run *db.IngestRun) error {
+	reader, err := newRunDumpReader(ctx, run)
 	if err != nil {
-		log.Fatalf("Failed to fetch data from Wikipedia: %v", err)
+		return err
 	}
 
-	// Send the fetched data through the channel
-	wikiChannel <- *wikiData
-	close(wikiChannel) // Close the channel after sending all data
+	fetch := pipeline.Source(ctx, "fetch", newPipelineConfig().FetchBuffer, func(ctx context.Context, emit func(wiki.Page) error) error {
+		return reader.WalkFile(ctx, run.Config.Dump, emitPages(ctx, run, emit))
+	})
+	return processPages(ctx, pgConn, graph, extractor, run, fetch, nil)
 }
 
-// processNER reads data from wikiChannel, processes it for NER, and sends it to nerChannel.
-// This is executed as a goroutine.
-func processNER(wikiChannel <-chan wiki.WikiResponse, nerChannel chan<- GameData, wg *sync.WaitGroup) {
-	defer wg.Done() // Mark this goroutine as done when function completes
-
-	// Process each Wikipedia page data from the wikiChannel
-	for wikiData := range wikiChannel {
-		for _, page := range wikiData.Query.Pages {
-			title := page.Title
-			description := page.Extract
-
-			// Run NER (Named Entity Recognition) on the page description
-			entities, err := wiki.RunNER(description)
-			if err != nil {
-				log.Printf("Failed to run NER on %s: %v", title, err)
-				continue
+// processPages runs the extract, store and graph stages on the pages produced by source,
+// and the store and graph stages on the already extracted games received from games,
+// which may be nil. Failed pages are logged, marked as failed in run (when not nil) and
+// recorded as dead letters, as is every game of a batch Neo4j rejected. The returned
+// error is the one source stopped on, if any.
+func processPages(ctx context.Context, pgConn *sql.DB, graph *db.GraphWriter, extractor wiki.EntityExtractor, run *db.IngestRun, source pipeline.Stage[wiki.Page], games <-chan wiki.GameRecord) error {
+	config := newPipelineConfig()
+
+	// Stages after the source keep working for a grace period once ctx is cancelled, then
+	// give up on the pages left so the caller can record the run before closing anything
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	stopDrain := context.AfterFunc(ctx, func() { time.AfterFunc(pipelineDrainTimeout, cancelWork) })
+	defer stopDrain()
+
+	extract := pipeline.Map(workCtx, db.StageExtract, config.Extract, source.Out, func(ctx context.Context, page wiki.Page) (wiki.GameRecord, error) {
+		return extractGame(ctx, pgConn, extractor, run, page)
+	})
+	extracted := pipeline.Merge(workCtx, config.Extract.Buffer, extract.Out, games)
+
+	store := pipeline.Map(workCtx, db.StageStore, config.Store, extracted, func(ctx context.Context, game wiki.GameRecord) (wiki.GameRecord, error) {
+		return storeGame(ctx, pgConn, run, game)
+	})
+
+	// Only games PostgreSQL stored go on to Neo4j, with their entities named as it stored
+	// them; without Neo4j they end here
+	graphSink := pipeline.Sink(workCtx, db.StageGraph, config.Graph, store.Out, func(ctx context.Context, game wiki.GameRecord) error {
+		if graph == nil {
+			return nil
+		}
+		return graph.Write(db.GraphGame{PageID: game.PageID, Title: game.Title, Entities: game.Entities})
+	})
+	stages := []<-chan error{source.Errors, extract.Errors, store.Errors, graphSink.Errors}
+
+	// Log every failure, mark the page as failed and keep it as a dead letter for a later retry;
+	// only a failed source ends the run early
+	var fetchErr error
+	pipeline.Collect(func(err error) {
+		var stageErr *pipeline.StageError
+		if !errors.As(err, &stageErr) {
+			log.Print(err)
+			return
+		}
+		switch item := stageErr.Item.(type) {
+		case wiki.Page:
+			log.Printf("Failed to extract entities from %s: %v", item.Title, stageErr.Err)
+			markPage(run, item.PageID, item.Title, db.PageFailed, stageErr.Err)
+			deadLetter(pgConn, run, stageErr)
+		case wiki.GameRecord:
+			if stageErr.Stage == db.StageGraph {
+				// The game is stored; only its batch is missing from Neo4j
+				deadLetterGraphBatch(pgConn, run, stageErr.Err)
+				return
+			}
+			log.Printf("Failed to insert %s: %v", item.Title, stageErr.Err)
+			markPage(run, item.PageID, item.Title, db.PageFailed, stageErr.Err)
+			deadLetter(pgConn, run, stageErr)
+		default:
+			switch {
+			case errors.Is(err, context.Canceled):
+				log.Printf("Stopped %s.", stageErr.Stage)
+			case stageErr.Stage == "fetch":
+				log.Printf("Failed to fetch data from Wikipedia: %v", stageErr.Err)
+			default:
+				log.Printf("%s failed: %v", stageErr.Stage, stageErr.Err)
 			}
+			fetchErr = stageErr.Err
+		}
+	}, stages...)
+	for range graphSink.Out {
+	}
+
+	// Write whatever is left in the last, partial batch
+	if graph != nil {
+		if err := graph.Flush(); err != nil {
+			deadLetterGraphBatch(pgConn, run, err)
+		}
+	}
+
+	fmt.Println("All tasks completed.")
+	return fetchErr
+}
+
+// fetchWikipediaData walks the run's Wikipedia category and emits every page it finds
+// until the walk completes or ctx is cancelled.
+func fetchWikipediaData(ctx context.Context, client *wiki.Client, run *db.IngestRun, emit func(wiki.Page) error) error {
+	// Walk the category and forward every batch of pages as soon as it arrives
+	return client.WalkCategory(ctx, run.Config.Category, emitPages(ctx, run, emit))
+}
 
-			// Send the processed game data (with entities) to the nerChannel
-			nerChannel <- GameData{
-				Title:       title,
-				Description: description,
-				Entities:    entities,
+// emitPages returns a batch callback that records the pages as fetched in run (when
+// not nil) and emits them one by one.
+func emitPages(ctx context.Context, run *db.IngestRun, emit func(wiki.Page) error) func([]wiki.Page) error {
+	return func(batch []wiki.Page) error {
+		if run != nil {
+			if err := run.MarkFetched(ctx, batch); err != nil {
+				return err
 			}
 		}
+		for _, page := range batch {
+			if err := emit(page); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-	// Close the nerChannel after all data has been processed
-	close(nerChannel)
 }
 
-// InsertGameWithEntities inserts the game and its related entities (Developers, Platforms, Genres) into the database concurrently.
-func InsertGameWithEntities(db *sql.DB, title, summary, releaseDate string, entities []Entity) error {
-	// Insert the game into the database and get the gameID
-	gameID, err := insertGame(db, title, summary, releaseDate)
+// extractGame extracts the entities of a page with the configured extractor (NER or
+// gazetteer) and its infobox, and turns it into a game ready to be stored.
+func extractGame(ctx context.Context, pgConn *sql.DB, extractor wiki.EntityExtractor, run *db.IngestRun, page wiki.Page) (wiki.GameRecord, error) {
+	// Run the entity extractor on the page description
+	entities, err := extractor.Extract(ctx, page.Extract)
 	if err != nil {
-		return fmt.Errorf("failed to insert game: %v", err)
+		return wiki.GameRecord{}, err
 	}
 
-	var wg sync.WaitGroup                      // WaitGroup to track goroutines inserting entities
-	errChan := make(chan error, len(entities)) // Channel to collect any errors from the goroutines
-
-	// For each entity (e.g., Developer, Platform, Genre), insert it concurrently
-	for _, entity := range entities {
-		wg.Add(1) // Increment WaitGroup counter for each entity
+	// Add the structured facts from the page's infobox, if it has one
+	releaseDate := ""
+	if infobox, ok := wiki.ParseInfobox(page.Wikitext); ok {
+		entities = wiki.MergeEntities(infobox.Entities(), entities)
+		releaseDate = infobox.ReleaseDate()
+	}
+	// Map every spelling of a developer, platform or genre to one canonical name
+	entities = entityNames.CanonicalizeAll(entities)
+	for i := range entities {
+		entities[i].RevisionID = page.LastRevID
+	}
+	markPage(run, page.PageID, page.Title, db.PageExtracted, nil)
+	resolveDeadLetters(pgConn, page.PageID, db.StageExtract)
 
-		go func(entity Entity) {
-			defer wg.Done() // Mark this goroutine as done after the entity is processed
+	return wiki.GameRecord{
+		PageID:      page.PageID,
+		Title:       page.Title,
+		Summary:     page.Extract,
+		ReleaseDate: releaseDate,
+		RevisionID:  page.LastRevID,
+		Aliases:     page.Aliases,
+		Entities:    entities,
+	}, nil
+}
 
-			var insertErr error
-			// Insert the entity based on its type (Developer, Platform, Genre)
-			switch entity.Label {
-			case "Developer":
-				insertErr = insertDeveloper(db, gameID, entity.Text)
-			case "Platform":
-				insertErr = insertPlatform(db, gameID, entity.Text)
-			case "Genre":
-				insertErr = insertGenre(db, gameID, entity.Text)
-			default:
-				return
-			}
+// storeGame upserts a game with its entities in PostgreSQL and returns it with the
+// entities as they were stored.
+func storeGame(ctx context.Context, pgConn *sql.DB, run *db.IngestRun, game wiki.GameRecord) (wiki.GameRecord, error) {
+	_, stored, err := wiki.UpsertGameEntities(ctx, pgConn, game)
+	if err != nil {
+		return game, err
+	}
+	labelReport.CountUnstored(game.Entities)
+	game.Entities = stored
+	markPage(run, game.PageID, game.Title, db.PageStored, nil)
+	// Graph dead letters stay until Neo4j has the game, which storing it says nothing about
+	resolveDeadLetters(pgConn, game.PageID, db.StageStore)
+	return game, nil
+}
 
-			// If there is an error, send it to the error channel
-			if insertErr != nil {
-				errChan <- fmt.Errorf("failed to insert entity (%s): %v", entity.Text, insertErr)
-			}
-		}(entity) // Pass the current entity to the goroutine
+// wikiCategory returns the Wikipedia category to crawl, taken from WIKI_CATEGORY if set.
+func wikiCategory() string {
+	if category := os.Getenv("WIKI_CATEGORY"); category != "" {
+		return category
 	}
+	return wiki.DefaultCategory
+}
 
-	wg.Wait()      // Wait for all entity-inserting goroutines to finish
-	close(errChan) // Close the error channel after all goroutines have finished
+// pipelineConfig sizes the stages of the ingestion pipeline.
+type pipelineConfig struct {
+	FetchBuffer int                  // Pages fetched ahead of extraction
+	Extract     pipeline.StageConfig // Concurrent entity extractions; more than NER_WORKERS just queue on the pool
+	Store       pipeline.StageConfig // Concurrent PostgreSQL upserts; keep well below the connection pool size
+	Graph       pipeline.StageConfig // Concurrent Neo4j writers
+}
 
-	// Collect any errors from the error channel
-	var errorMessages []string
-	for err := range errChan {
-		if err != nil {
-			errorMessages = append(errorMessages, err.Error())
-		}
+// newPipelineConfig returns the pipeline configuration from PIPELINE_EXTRACT_WORKERS,
+// PIPELINE_STORE_WORKERS and PIPELINE_BUFFER. The extract stage defaults to NER_WORKERS
+// workers so every NER process is kept busy.
+func newPipelineConfig() pipelineConfig {
+	buffer := envInt("PIPELINE_BUFFER", 32)
+	return pipelineConfig{
+		FetchBuffer: buffer,
+		Extract:     pipeline.StageConfig{Workers: envInt("PIPELINE_EXTRACT_WORKERS", envInt("NER_WORKERS", 2)), Buffer: buffer},
+		Store:       pipeline.StageConfig{Workers: envInt("PIPELINE_STORE_WORKERS", 4), Buffer: buffer},
+		Graph:       pipeline.StageConfig{Workers: 1},
 	}
+}
 
-	// If there were any errors, return them
-	if len(errorMessages) > 0 {
-		return fmt.Errorf("multiple errors occurred: %v", errorMessages)
+// envInt returns the positive integer in the named environment variable, or fallback.
+func envInt(name string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
 	}
-
-	return nil
+	return fallback
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"gamenet/internal/pkg/db"
+	"log"
+	"os"
+)
+
+// runMigrate implements "gamenet migrate up|down|status".
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	steps := flags.Int("steps", 1, "number of migrations to revert with down")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gamenet migrate up|down|status [-steps N]")
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	action := args[0]
+	flags.Parse(args[1:])
+
+	pgConn, err := db.InitPostgres()
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer pgConn.Close()
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		applied, err := db.MigrateUp(ctx, pgConn)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		for _, migration := range applied {
+			fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date.")
+		}
+
+	case "down":
+		reverted, err := db.MigrateDown(ctx, pgConn, *steps)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		for _, migration := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No migrations to revert.")
+		}
+
+	case "status":
+		statuses, err := db.MigrationStatuses(ctx, pgConn)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", status.Version, status.Name, state)
+		}
+
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"gamenet/internal/pkg/db"
+	"log"
+	"os"
+	"strings"
+)
+
+// runPath implements "gamenet path FROM TO".
+func runPath(args []string) {
+	flags := flag.NewFlagSet("path", flag.ExitOnError)
+	relationships := flags.String("relationships", "", "comma-separated relationship types to follow (default "+strings.Join(db.PathRelationships(), ",")+")")
+	maxHops := flags.Int("max-hops", db.DefaultPathHops, "maximum number of relationships on the path")
+	backend := flags.String("backend", queryBackend(), "search with postgres (in Go) or neo4j (in Cypher)")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gamenet path [-relationships R,S] [-max-hops N] [-backend postgres|neo4j] FROM TO")
+		fmt.Fprintln(os.Stderr, "FROM and TO are game titles or kind/id references such as games/12 or developers/3.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	opts := db.PathOptions{MaxHops: *maxHops}
+	if *relationships != "" {
+		for _, relationship := range strings.Split(*relationships, ",") {
+			opts.Relationships = append(opts.Relationships, strings.ToUpper(strings.TrimSpace(relationship)))
+		}
+	}
+
+	ctx := context.Background()
+	pgConn, err := db.InitPostgres()
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer pgConn.Close()
+
+	if *backend == "neo4j" {
+		if err := db.InitNeo4j(); err != nil {
+			log.Fatalf("Failed to connect to Neo4j: %v", err)
+		}
+		defer db.CloseNeo4j()
+	}
+	finder, err := newPathFinder(db.NewCatalog(pgConn), *backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	from, err := parsePathArg(ctx, pgConn, flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	to, err := parsePathArg(ctx, pgConn, flags.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	path, err := finder.ShortestPath(ctx, from, to, opts)
+	if err != nil {
+		log.Fatalf("Failed to find a path: %v", err)
+	}
+	fmt.Printf("%d hops:\n", len(path.Relationships))
+	for i, node := range path.Nodes {
+		fmt.Printf("  %s [%s]\n", node.Name, node.Ref())
+		if i < len(path.Relationships) {
+			fmt.Printf("    -%s-\n", path.Relationships[i])
+		}
+	}
+}
+
+// parsePathArg reads a node reference such as developers/3 or, failing that, the
+// title of a game.
+func parsePathArg(ctx context.Context, pgConn *sql.DB, arg string) (db.PathNode, error) {
+	if node, err := db.ParsePathNode(arg); err == nil {
+		return node, nil
+	}
+	gameID, err := findGame(ctx, pgConn, arg)
+	if err != nil {
+		return db.PathNode{}, fmt.Errorf("failed to find %q: %v", arg, err)
+	}
+	return db.PathNode{Kind: "games", ID: gameID}, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/pipeline"
+	"gamenet/internal/pkg/wiki"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runRefresh implements "gamenet refresh": it compares the revision every stored game
+// was extracted from with the latest revision of its Wikipedia page, extracts the
+// edited and moved pages again and removes the games whose page was deleted.
+func runRefresh(args []string) {
+	flags := flag.NewFlagSet("refresh", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report what changed without updating the databases")
+	allowDeletes := flags.Bool("allow-mass-delete", false, "delete the games of missing pages even when they are a large share of the catalog")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gamenet refresh [-dry-run] [-allow-mass-delete]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	pgConn, err := db.InitPostgres()
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer pgConn.Close()
+
+	if _, err := db.MigrateUp(ctx, pgConn); err != nil {
+		log.Fatalf("Failed to migrate PostgreSQL schema: %v", err)
+	}
+
+	games, err := db.TrackedGames(ctx, pgConn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// One prop=info request per 50 pages tells us the latest revision of each
+	client := wiki.NewClientFromEnv()
+	info, err := client.FetchPageInfo(ctx, db.PageIDs(games))
+	if err != nil {
+		log.Fatalf("Failed to fetch page revisions: %v", err)
+	}
+	plan := db.PlanRefresh(games, info)
+
+	for _, game := range plan.Moved {
+		log.Printf("Page %d was moved to %q", game.PageID, game.Title)
+	}
+	for _, game := range plan.Deleted {
+		log.Printf("Page %d (%s) was deleted", game.PageID, game.Title)
+	}
+	fmt.Printf("%d games tracked: %d unchanged, %d changed (%d moved), %d deleted, %d not reported\n",
+		len(games), len(plan.Unchanged), len(plan.Changed), len(plan.Moved), len(plan.Deleted), len(plan.Unknown))
+	if *dryRun {
+		return
+	}
+	if err := plan.CheckDeletions(len(games)); err != nil && !*allowDeletes {
+		log.Fatalf("%v; check WIKI_API_URL, or pass -allow-mass-delete", err)
+	}
+
+	graph, err := newGraphWriter()
+	if err != nil {
+		log.Fatalf("Failed to set up Neo4j: %v", err)
+	}
+	if graph != nil {
+		defer db.CloseNeo4j()
+	}
+
+	// Deleted pages leave the databases, together with any dead letters they left behind
+	deleted := db.PageIDs(plan.Deleted)
+	if _, err := db.DeleteGames(ctx, pgConn, deleted); err != nil {
+		log.Fatal(err)
+	}
+	if graph != nil {
+		if err := graph.DeleteGames(deleted); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, pageID := range deleted {
+		resolveDeadLetters(pgConn, pageID, "")
+	}
+
+	if len(plan.Changed) == 0 {
+		return
+	}
+
+	extractor, closeExtractor, err := newExtractor(ctx, pgConn, newIngestConfig().Extractor)
+	if err != nil {
+		log.Fatalf("Failed to set up entity extractor: %v", err)
+	}
+	defer closeExtractor()
+
+	// Changed pages go through the same pipeline as a crawl, outside of any run
+	fetch := pipeline.Source(ctx, "fetch", newPipelineConfig().FetchBuffer, func(ctx context.Context, emit func(wiki.Page) error) error {
+		return client.FetchPages(ctx, db.PageIDs(plan.Changed), emitPages(ctx, nil, emit))
+	})
+	if err := processPages(ctx, pgConn, graph, extractor, nil, fetch, nil); err != nil {
+		log.Fatalf("Refresh stopped: %v", err)
+	}
+	log.Printf("Refreshed %d changed pages; failures are listed by gamenet dlq list", len(plan.Changed))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gamenet/internal/pkg/api"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout bounds how long a terminating process waits for in-flight pages
+// and HTTP requests together, leaving a margin below Kubernetes' default 30s grace
+// period. The last httpShutdownTimeout of it is kept for closing HTTP connections;
+// pages still in flight after pipelineDrainTimeout are cancelled.
+const (
+	shutdownTimeout      = 25 * time.Second
+	httpShutdownTimeout  = 5 * time.Second
+	pipelineDrainTimeout = shutdownTimeout - httpShutdownTimeout
+)
+
+// runService serves the HTTP endpoints and runs the ingestion pipeline until the
+// process receives SIGTERM or SIGINT, then drains the pipeline and shuts down.
+func runService() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Initialize a connection to the PostgreSQL database
+	pgConn, err := db.InitPostgres()
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer pgConn.Close() // Close the database connection when the program ends
+
+	// Bring the schema up to date before writing anything
+	if _, err := db.MigrateUp(ctx, pgConn); err != nil {
+		log.Fatalf("Failed to migrate PostgreSQL schema: %v", err)
+	}
+
+	server := api.NewServerFromEnv()
+	server.AddCheck("postgres", pgConn.PingContext)
+	catalog := db.NewCatalog(pgConn)
+	server.HandleCatalog(catalog)
+	server.HandleGraphQL(catalog)
+	server.HandleSearch(catalog)
+
+	// Neo4j is optional; only check and write to it when it is configured
+	graph, err := newGraphWriter()
+	if err != nil {
+		log.Fatalf("Failed to set up Neo4j: %v", err)
+	}
+	if graph != nil {
+		defer db.CloseNeo4j()
+		server.AddCheck("neo4j", db.PingNeo4j)
+	}
+	recommender, err := newRecommender(catalog, queryBackend())
+	if err != nil {
+		log.Fatalf("Failed to set up similar games: %v", err)
+	}
+	server.HandleSimilar(recommender)
+	finder, err := newPathFinder(catalog, queryBackend())
+	if err != nil {
+		log.Fatalf("Failed to set up path queries: %v", err)
+	}
+	server.HandlePath(finder)
+
+	// Serve before the extractor is set up: loading the NER model takes a while, during
+	// which /healthz answers and /readyz reports the workers as not ready yet
+	config := newIngestConfig()
+	var nerCheck api.PendingCheck
+	if config.Extractor == "" || config.Extractor == "ner" {
+		server.AddCheck("ner", nerCheck.Check)
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			log.Fatalf("HTTP server failed: %v", err)
+		}
+	}()
+
+	// Set up the configured entity extractor (NER workers or gazetteer)
+	extractor, closeExtractor, err := newExtractor(ctx, pgConn, config.Extractor)
+	if err != nil {
+		log.Fatalf("Failed to set up entity extractor: %v", err)
+	}
+	defer closeExtractor() // Release the extractor when the program ends
+	if pool, ok := extractor.(*wiki.NERPool); ok {
+		nerCheck.Set(pool.Check)
+	}
+
+	// Every start of the service is recorded as a new ingestion run
+	run, err := db.StartIngestRun(ctx, pgConn, config)
+	if err != nil {
+		log.Fatalf("Failed to start ingestion run: %v", err)
+	}
+	defer run.Close()
+
+	pipelineDone := make(chan struct{})
+	go func() {
+		defer close(pipelineDone)
+		runIngestion(ctx, pgConn, graph, extractor, run)
+	}()
+
+	// Keep serving after the crawl finishes; only a signal ends the process
+	<-ctx.Done()
+	log.Println("Shutting down...")
+	server.Drain()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Let the pages that were already fetched finish extraction and storage. The pipeline
+	// cancels the ones left after pipelineDrainTimeout, and must have recorded the end of
+	// the run before the deferred closes release the extractor and PostgreSQL
+	<-pipelineDone
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down HTTP server: %v", err)
+	}
+}
+
+// newGraphWriter connects to Neo4j and prepares its schema when NEO4J_HOST is set.
+// It returns nil when Neo4j is not configured.
+func newGraphWriter() (*db.GraphWriter, error) {
+	if os.Getenv("NEO4J_HOST") == "" {
+		return nil, nil
+	}
+	if err := db.InitNeo4j(); err != nil {
+		return nil, err
+	}
+
+	graph := db.NewGraphWriter(db.Neo4jDriver)
+	if err := graph.EnsureGraphSchema(); err != nil {
+		db.CloseNeo4j()
+		return nil, err
+	}
+	return graph, nil
+}
+
+// queryBackend returns the configured backend of similar-game and path queries: the
+// GRAPH_QUERY_BACKEND environment variable, or neo4j when Neo4j is configured and
+// postgres otherwise.
+func queryBackend() string {
+	if backend := os.Getenv("GRAPH_QUERY_BACKEND"); backend != "" {
+		return backend
+	}
+	if os.Getenv("NEO4J_HOST") != "" {
+		return "neo4j"
+	}
+	return "postgres"
+}
+
+// checkQueryBackend checks that backend is postgres, or neo4j with the driver set up
+// by db.InitNeo4j.
+func checkQueryBackend(backend string) error {
+	switch backend {
+	case "postgres":
+		return nil
+	case "neo4j":
+		if db.Neo4jDriver == nil {
+			return fmt.Errorf("the neo4j query backend needs NEO4J_HOST")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown query backend %q (expected postgres or neo4j)", backend)
+	}
+}
+
+// newRecommender returns the recommender of the given backend.
+func newRecommender(catalog *db.Catalog, backend string) (api.Recommender, error) {
+	if err := checkQueryBackend(backend); err != nil {
+		return nil, err
+	}
+	if backend == "neo4j" {
+		return db.NewGraphRecommender(db.Neo4jDriver, catalog), nil
+	}
+	return catalog, nil
+}
+
+// newPathFinder returns the path finder of the given backend.
+func newPathFinder(catalog *db.Catalog, backend string) (api.PathFinder, error) {
+	if err := checkQueryBackend(backend); err != nil {
+		return nil, err
+	}
+	if backend == "neo4j" {
+		return db.NewGraphPathFinder(db.Neo4jDriver, catalog), nil
+	}
+	return catalog, nil
+}
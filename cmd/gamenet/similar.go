@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// runSimilar implements "gamenet similar <title>".
+func runSimilar(args []string) {
+	flags := flag.NewFlagSet("similar", flag.ExitOnError)
+	limit := flags.Int("limit", db.DefaultSimilarLimit, "number of games to list")
+	metric := flags.String("metric", db.MetricJaccard, "overlap metric: "+db.MetricJaccard+" or "+db.MetricAdamicAdar)
+	backend := flags.String("backend", queryBackend(), "compute with postgres (in Go) or neo4j (in Cypher)")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gamenet similar [-limit N] [-metric M] [-backend postgres|neo4j] TITLE")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	title := strings.Join(flags.Args(), " ")
+	if title == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	pgConn, err := db.InitPostgres()
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer pgConn.Close()
+
+	if *backend == "neo4j" {
+		if err := db.InitNeo4j(); err != nil {
+			log.Fatalf("Failed to connect to Neo4j: %v", err)
+		}
+		defer db.CloseNeo4j()
+	}
+	recommender, err := newRecommender(db.NewCatalog(pgConn), *backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	gameID, err := findGame(ctx, pgConn, title)
+	if err != nil {
+		log.Fatalf("Failed to find %q: %v", title, err)
+	}
+	result, err := recommender.SimilarGames(ctx, gameID, db.SimilarOptions{Metric: *metric, Limit: *limit})
+	if err != nil {
+		log.Fatalf("Failed to find similar games: %v", err)
+	}
+
+	fmt.Printf("Games similar to %s (%s):\n", formatGame(result.Game), result.Metric)
+	for i, game := range result.Similar {
+		fmt.Printf("%3d. %-50s %6.3f  %s\n", i+1, formatGame(game.GameSummary), game.Score, formatShared(game.Shared))
+	}
+	if len(result.Similar) == 0 {
+		fmt.Println("  none share a developer, publisher, platform, genre or series with it")
+	}
+}
+
+// findGame returns the ID of the game known by title. A title no game is known by
+// is resolved like a Wikipedia link, following redirects created since the game was
+// stored, and looked up again.
+func findGame(ctx context.Context, pgConn *sql.DB, title string) (int, error) {
+	gameID, err := wiki.FindGameID(ctx, pgConn, title)
+	if !errors.Is(err, wiki.ErrGameNotFound) {
+		return gameID, err
+	}
+	resolved, resolveErr := wiki.NewClientFromEnv().ResolveTitles(ctx, []string{title})
+	if resolveErr != nil {
+		return 0, fmt.Errorf("%v (resolving the title on Wikipedia failed: %v)", err, resolveErr)
+	}
+	page := resolved[title]
+	if page.Missing || page.Title == wiki.NormalizeTitle(title) {
+		return 0, err
+	}
+	return wiki.FindGameID(ctx, pgConn, page.Title)
+}
+
+// formatGame formats a game's title and release year.
+func formatGame(game db.GameSummary) string {
+	if game.ReleaseYear == 0 {
+		return game.Title
+	}
+	return fmt.Sprintf("%s (%d)", game.Title, game.ReleaseYear)
+}
+
+// formatShared lists the shared entities by kind, e.g. "developers: Nintendo; series: Mario".
+func formatShared(shared map[string][]string) string {
+	kinds := make([]string, 0, len(shared))
+	for kind := range shared {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		parts = append(parts, kind+": "+strings.Join(shared[kind], ", "))
+	}
+	return strings.Join(parts, "; ")
+}
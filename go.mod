@@ -1,7 +1,11 @@
-module GameNet
+module gamenet
 
 go 1.22.4
 
 require github.com/lib/pq v1.10.9
 
-require github.com/neo4j/neo4j-go-driver/v4 v4.4.7
+require (
+	github.com/graph-gophers/dataloader/v7 v7.1.0
+	github.com/graph-gophers/graphql-go v1.5.0
+	github.com/neo4j/neo4j-go-driver/v4 v4.4.7
+)
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"gamenet/internal/pkg/db"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Catalog is the read-only game catalog served by the REST API. *db.Catalog is the
+// PostgreSQL implementation.
+type Catalog interface {
+	ListGames(ctx context.Context, filter db.GameFilter) (db.GameList, error)
+	Game(ctx context.Context, id int) (db.GameDetail, error)
+	Entity(ctx context.Context, kind string, id int) (db.EntityDetail, error)
+}
+
+// GameListResponse is the JSON body of the game listings.
+type GameListResponse struct {
+	Games      []db.GameSummary `json:"games"`
+	NextCursor string           `json:"next_cursor,omitempty"` // Pass as ?cursor= to get the next page; absent on the last page
+}
+
+// errorResponse is the JSON body of a failed request.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// catalogAPI serves the REST endpoints of a Catalog.
+type catalogAPI struct {
+	catalog Catalog
+}
+
+// HandleCatalog registers the read-only REST API over the game catalog:
+//
+//	GET /games                    games, filtered by ?developer=, ?platform=, ?genre= and ?year=
+//	GET /games/{id}               a game with its developers, publishers, platforms and genres
+//	GET /{kind}/{id}              a developer, publisher, platform or genre
+//	GET /{kind}/{id}/games        the games of a developer, publisher, platform or genre
+//
+// Listings return at most ?limit= games (db.DefaultGameLimit by default) and a
+// next_cursor to pass as ?cursor= for the next page.
+func (s *Server) HandleCatalog(catalog Catalog) {
+	a := &catalogAPI{catalog: catalog}
+	s.mux.HandleFunc("GET /games", a.handleListGames)
+	s.mux.HandleFunc("GET /games/{id}", a.handleGame)
+	for _, kind := range db.CatalogKinds {
+		s.mux.HandleFunc("GET /"+kind+"/{id}", a.handleEntity(kind))
+		s.mux.HandleFunc("GET /"+kind+"/{id}/games", a.handleEntityGames(kind))
+	}
+}
+
+// handleListGames serves GET /games.
+func (a *catalogAPI) handleListGames(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseGameFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a.writeGames(w, r, filter)
+}
+
+// handleGame serves GET /games/{id}.
+func (a *catalogAPI) handleGame(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	game, err := a.catalog.Game(r.Context(), id)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, game)
+}
+
+// handleEntity serves GET /{kind}/{id}.
+func (a *catalogAPI) handleEntity(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		entity, err := a.catalog.Entity(r.Context(), kind, id)
+		if err != nil {
+			writeCatalogError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, entity)
+	}
+}
+
+// handleEntityGames serves GET /{kind}/{id}/games. The /games filters apply too.
+func (a *catalogAPI) handleEntityGames(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		filter, err := parseGameFilter(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// An unknown entity is a 404 rather than an empty list
+		if _, err := a.catalog.Entity(r.Context(), kind, id); err != nil {
+			writeCatalogError(w, err)
+			return
+		}
+
+		filter.EntityKind, filter.EntityID = kind, id
+		a.writeGames(w, r, filter)
+	}
+}
+
+// writeGames lists the games matching filter.
+func (a *catalogAPI) writeGames(w http.ResponseWriter, r *http.Request, filter db.GameFilter) {
+	list, err := a.catalog.ListGames(r.Context(), filter)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+	response := GameListResponse{Games: list.Games}
+	if list.Next != 0 {
+		response.NextCursor = EncodeCursor(list.Next)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// parseGameFilter reads the filters, limit and cursor of a game listing.
+func parseGameFilter(r *http.Request) (db.GameFilter, error) {
+	query := r.URL.Query()
+	filter := db.GameFilter{
+		Developer: query.Get("developer"),
+		Platform:  query.Get("platform"),
+		Genre:     query.Get("genre"),
+	}
+
+	if year := query.Get("year"); year != "" {
+		n, err := strconv.Atoi(year)
+		if err != nil || n <= 0 {
+			return filter, errors.New("year must be a positive number")
+		}
+		filter.Year = n
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > db.MaxGameLimit {
+			return filter, errors.New("limit must be between 1 and " + strconv.Itoa(db.MaxGameLimit))
+		}
+		filter.Limit = n
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := DecodeCursor(cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = after
+	}
+	return filter, nil
+}
+
+// EncodeCursor returns the opaque cursor of the page following the game with the given ID.
+func EncodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("g" + strconv.Itoa(id)))
+}
+
+// DecodeCursor returns the game ID encoded in a cursor.
+func DecodeCursor(cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(decoded) < 2 || decoded[0] != 'g' {
+		return 0, errors.New("invalid cursor")
+	}
+	id, err := strconv.Atoi(string(decoded[1:]))
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return id, nil
+}
+
+// pathID reads the {id} path parameter, answering 400 when it is not a positive number.
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "id must be a positive number")
+		return 0, false
+	}
+	return id, true
+}
+
+// writeCatalogError answers 404 for missing games and entities and 500 otherwise,
+// without exposing database errors to clients.
+func writeCatalogError(w http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.Printf("Catalog request failed: %v", err)
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+// writeError writes a JSON error response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,554 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"gamenet/internal/pkg/db"
+	"github.com/graph-gophers/dataloader/v7"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// graphqlSchema is the GraphQL schema of the game catalog. Games and entities link
+// to each other in both directions, so clients can walk from a game to its
+// developers and on to their other games in one request.
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	game(id: ID!): Game
+	games(developer: String, platform: String, genre: String, year: Int, first: Int, after: String): GameConnection!
+	developer(id: ID!): Developer
+	publisher(id: ID!): Publisher
+	platform(id: ID!): Platform
+	genre(id: ID!): Genre
+}
+
+type GameConnection {
+	games: [Game!]!
+	nextCursor: String
+}
+
+type Game {
+	id: ID!
+	pageId: Int
+	title: String!
+	summary: String
+	releaseDate: String
+	releaseYear: Int
+	developers: [Developer!]!
+	publishers: [Publisher!]!
+	platforms: [Platform!]!
+	genres: [Genre!]!
+}
+
+type Developer {
+	id: ID!
+	name: String!
+	wikiTitle: String
+	confidence: Float
+	source: String
+	games(exclude: ID, developer: ID, platform: ID, genre: ID, sharePlatformWith: ID, first: Int, after: String): GameConnection!
+}
+
+type Publisher {
+	id: ID!
+	name: String!
+	wikiTitle: String
+	confidence: Float
+	source: String
+	games(exclude: ID, developer: ID, platform: ID, genre: ID, sharePlatformWith: ID, first: Int, after: String): GameConnection!
+}
+
+type Platform {
+	id: ID!
+	name: String!
+	wikiTitle: String
+	confidence: Float
+	source: String
+	games(exclude: ID, developer: ID, platform: ID, genre: ID, sharePlatformWith: ID, first: Int, after: String): GameConnection!
+}
+
+type Genre {
+	id: ID!
+	name: String!
+	wikiTitle: String
+	confidence: Float
+	source: String
+	games(exclude: ID, developer: ID, platform: ID, genre: ID, sharePlatformWith: ID, first: Int, after: String): GameConnection!
+}
+`
+
+// loaderWait is how long a loader collects keys before querying them in one batch.
+const loaderWait = 5 * time.Millisecond
+
+// Every level of games and entities multiplies the rows a query can reach, so
+// queries are limited in depth and size, and in the games they return: every page
+// of games counts its full size against graphqlMaxGames before it is queried.
+const (
+	graphqlMaxDepth     = 8
+	graphqlMaxBodyBytes = 16 << 10
+	graphqlMaxGames     = 1000
+)
+
+// errTooManyGames is returned for the pages of games past a request's graphqlMaxGames.
+var errTooManyGames = errors.New("query requests more than " + strconv.Itoa(graphqlMaxGames) + " games; lower first or nest fewer lists")
+
+// BatchCatalog is a Catalog that also answers queries for many games or entities at
+// once, which the GraphQL resolvers batch their lookups into.
+type BatchCatalog interface {
+	Catalog
+	GamesByID(ctx context.Context, ids []int) (map[int]db.GameDetail, error)
+	EntitiesOfGames(ctx context.Context, kind string, gameIDs []int) (map[int][]db.EntityLink, error)
+	ListGamesOfEntities(ctx context.Context, kind string, entityIDs []int, filter db.GameFilter) (map[int]db.GameList, error)
+}
+
+// HandleGraphQL registers the GraphQL endpoint (POST /graphql) over the game catalog.
+// Every request gets its own loaders, so the games, entities and pages of entity games
+// a query reaches are fetched with one query per kind and level instead of one per
+// join-table row or entity.
+func (s *Server) HandleGraphQL(catalog BatchCatalog) {
+	schema := graphql.MustParseSchema(graphqlSchema, &graphqlResolver{catalog: catalog}, graphql.MaxDepth(graphqlMaxDepth))
+	handler := &relay.Handler{Schema: schema}
+
+	s.mux.HandleFunc("POST /graphql", func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, graphqlMaxBodyBytes)
+		ctx := context.WithValue(r.Context(), loadersKey{}, newLoaders(catalog))
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// loadersKey is the context key of a request's loaders.
+type loadersKey struct{}
+
+// loaders batch and cache the catalog lookups of a single GraphQL request, and
+// count the games it has asked for.
+type loaders struct {
+	catalog      BatchCatalog
+	games        *dataloader.Loader[int, db.GameDetail]
+	gameEntities map[string]*dataloader.Loader[int, []db.EntityLink] // By entity kind
+	entityGames  *dataloader.Loader[db.GameFilter, db.GameList]      // By filter naming the entity in EntityKind and EntityID
+	reserved     atomic.Int64                                        // Games of the pages queried so far
+}
+
+// newLoaders returns fresh loaders over catalog.
+func newLoaders(catalog BatchCatalog) *loaders {
+	l := &loaders{
+		catalog:      catalog,
+		games:        newLoader(catalog.GamesByID),
+		gameEntities: make(map[string]*dataloader.Loader[int, []db.EntityLink]),
+	}
+	for _, kind := range db.CatalogKinds {
+		kind := kind
+		l.gameEntities[kind] = newLoader(func(ctx context.Context, ids []int) (map[int][]db.EntityLink, error) {
+			return catalog.EntitiesOfGames(ctx, kind, ids)
+		})
+	}
+	l.entityGames = dataloader.NewBatchedLoader(l.loadEntityGames, dataloader.WithWait[db.GameFilter, db.GameList](loaderWait))
+	return l
+}
+
+// loadEntityGames answers a batch of pages of entity games with one query for all
+// entities of the same kind asking for the same page.
+func (l *loaders) loadEntityGames(ctx context.Context, filters []db.GameFilter) []*dataloader.Result[db.GameList] {
+	groups := make(map[db.GameFilter][]int)
+	for _, filter := range filters {
+		page := filter
+		page.EntityID = 0
+		groups[page] = append(groups[page], filter.EntityID)
+	}
+
+	results := make(map[db.GameFilter]*dataloader.Result[db.GameList], len(filters))
+	for page, ids := range groups {
+		lists, err := l.catalog.ListGamesOfEntities(ctx, page.EntityKind, ids, page)
+		for _, id := range ids {
+			filter := page
+			filter.EntityID = id
+			results[filter] = &dataloader.Result[db.GameList]{Data: lists[id], Error: err}
+		}
+	}
+
+	ordered := make([]*dataloader.Result[db.GameList], len(filters))
+	for i, filter := range filters {
+		ordered[i] = results[filter]
+	}
+	return ordered
+}
+
+// reserve counts a page of up to limit games (db.DefaultGameLimit when 0) against
+// the request's graphqlMaxGames.
+func (l *loaders) reserve(limit int) error {
+	if limit == 0 {
+		limit = db.DefaultGameLimit
+	}
+	if l.reserved.Add(int64(limit)) > graphqlMaxGames {
+		return errTooManyGames
+	}
+	return nil
+}
+
+// newLoader returns a loader answering each batch of IDs with a single call to fetch.
+// IDs missing from the result load the zero value.
+func newLoader[V any](fetch func(ctx context.Context, ids []int) (map[int]V, error)) *dataloader.Loader[int, V] {
+	batch := func(ctx context.Context, ids []int) []*dataloader.Result[V] {
+		values, err := fetch(ctx, ids)
+		results := make([]*dataloader.Result[V], len(ids))
+		for i, id := range ids {
+			results[i] = &dataloader.Result[V]{Data: values[id], Error: err}
+		}
+		return results
+	}
+	return dataloader.NewBatchedLoader(batch, dataloader.WithWait[int, V](loaderWait))
+}
+
+// requestLoaders returns the loaders of the request ctx belongs to.
+func requestLoaders(ctx context.Context) (*loaders, error) {
+	l, ok := ctx.Value(loadersKey{}).(*loaders)
+	if !ok {
+		return nil, errors.New("GraphQL request has no loaders")
+	}
+	return l, nil
+}
+
+// graphqlResolver resolves the Query type.
+type graphqlResolver struct {
+	catalog BatchCatalog
+}
+
+// Game resolves Query.game; a missing game is null.
+func (r *graphqlResolver) Game(ctx context.Context, args struct{ ID graphql.ID }) (*gameResolver, error) {
+	l, err := requestLoaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseGraphQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	game, err := l.games.Load(ctx, id)()
+	if err != nil || game.ID == 0 {
+		return nil, err
+	}
+	return &gameResolver{id: id, loaders: l}, nil
+}
+
+// Games resolves Query.games, which takes the filters and paging of GET /games.
+func (r *graphqlResolver) Games(ctx context.Context, args struct {
+	Developer, Platform, Genre *string
+	Year, First                *int32
+	After                      *string
+}) (*gameConnectionResolver, error) {
+	l, err := requestLoaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := db.GameFilter{Developer: deref(args.Developer), Platform: deref(args.Platform), Genre: deref(args.Genre)}
+	if args.Year != nil {
+		filter.Year = int(*args.Year)
+	}
+	if filter.Limit, filter.After, err = parsePage(args.First, args.After); err != nil {
+		return nil, err
+	}
+
+	if err := l.reserve(filter.Limit); err != nil {
+		return nil, err
+	}
+	list, err := l.catalog.ListGames(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return newGameConnection(l, list), nil
+}
+
+// Developer resolves Query.developer; a missing developer is null.
+func (r *graphqlResolver) Developer(ctx context.Context, args struct{ ID graphql.ID }) (*entityResolver, error) {
+	return r.entity(ctx, "developers", args.ID)
+}
+
+// Publisher resolves Query.publisher; a missing publisher is null.
+func (r *graphqlResolver) Publisher(ctx context.Context, args struct{ ID graphql.ID }) (*entityResolver, error) {
+	return r.entity(ctx, "publishers", args.ID)
+}
+
+// Platform resolves Query.platform; a missing platform is null.
+func (r *graphqlResolver) Platform(ctx context.Context, args struct{ ID graphql.ID }) (*entityResolver, error) {
+	return r.entity(ctx, "platforms", args.ID)
+}
+
+// Genre resolves Query.genre; a missing genre is null.
+func (r *graphqlResolver) Genre(ctx context.Context, args struct{ ID graphql.ID }) (*entityResolver, error) {
+	return r.entity(ctx, "genres", args.ID)
+}
+
+// entity looks up a developer, publisher, platform or genre by ID.
+func (r *graphqlResolver) entity(ctx context.Context, kind string, graphqlID graphql.ID) (*entityResolver, error) {
+	l, err := requestLoaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseGraphQLID(graphqlID)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := r.catalog.Entity(ctx, kind, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	link := db.EntityLink{ID: entity.ID, Name: entity.Name, WikiTitle: entity.WikiTitle}
+	return &entityResolver{kind: kind, link: link, loaders: l}, nil
+}
+
+// gameConnectionResolver resolves a page of games.
+type gameConnectionResolver struct {
+	games      []*gameResolver
+	nextCursor *string
+}
+
+// Games resolves GameConnection.games.
+func (c *gameConnectionResolver) Games() []*gameResolver { return c.games }
+
+// NextCursor resolves GameConnection.nextCursor.
+func (c *gameConnectionResolver) NextCursor() *string { return c.nextCursor }
+
+// gameResolver resolves a Game, loading its fields in batches with the other games
+// of the request.
+type gameResolver struct {
+	id      int
+	loaders *loaders
+}
+
+// ID resolves Game.id.
+func (g *gameResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(g.id))
+}
+
+// PageID resolves Game.pageId.
+func (g *gameResolver) PageID(ctx context.Context) (*int32, error) {
+	game, err := g.load(ctx)
+	if err != nil || game.PageID == 0 {
+		return nil, err
+	}
+	pageID := int32(game.PageID)
+	return &pageID, nil
+}
+
+// Title resolves Game.title.
+func (g *gameResolver) Title(ctx context.Context) (string, error) {
+	game, err := g.load(ctx)
+	return game.Title, err
+}
+
+// Summary resolves Game.summary.
+func (g *gameResolver) Summary(ctx context.Context) (*string, error) {
+	game, err := g.load(ctx)
+	if err != nil || game.Summary == "" {
+		return nil, err
+	}
+	return &game.Summary, nil
+}
+
+// ReleaseDate resolves Game.releaseDate.
+func (g *gameResolver) ReleaseDate(ctx context.Context) (*string, error) {
+	game, err := g.load(ctx)
+	if err != nil || game.ReleaseDate == "" {
+		return nil, err
+	}
+	return &game.ReleaseDate, nil
+}
+
+// ReleaseYear resolves Game.releaseYear.
+func (g *gameResolver) ReleaseYear(ctx context.Context) (*int32, error) {
+	game, err := g.load(ctx)
+	if err != nil || game.ReleaseYear == 0 {
+		return nil, err
+	}
+	year := int32(game.ReleaseYear)
+	return &year, nil
+}
+
+// Developers resolves Game.developers.
+func (g *gameResolver) Developers(ctx context.Context) ([]*entityResolver, error) {
+	return g.entities(ctx, "developers")
+}
+
+// Publishers resolves Game.publishers.
+func (g *gameResolver) Publishers(ctx context.Context) ([]*entityResolver, error) {
+	return g.entities(ctx, "publishers")
+}
+
+// Platforms resolves Game.platforms.
+func (g *gameResolver) Platforms(ctx context.Context) ([]*entityResolver, error) {
+	return g.entities(ctx, "platforms")
+}
+
+// Genres resolves Game.genres.
+func (g *gameResolver) Genres(ctx context.Context) ([]*entityResolver, error) {
+	return g.entities(ctx, "genres")
+}
+
+// load returns the game's row, batched with the other games of the request.
+func (g *gameResolver) load(ctx context.Context) (db.GameDetail, error) {
+	return g.loaders.games.Load(ctx, g.id)()
+}
+
+// entities returns the game's entities of one kind.
+func (g *gameResolver) entities(ctx context.Context, kind string) ([]*entityResolver, error) {
+	links, err := g.loaders.gameEntities[kind].Load(ctx, g.id)()
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*entityResolver, len(links))
+	for i, link := range links {
+		resolvers[i] = &entityResolver{kind: kind, link: link, loaders: g.loaders}
+	}
+	return resolvers, nil
+}
+
+// entityResolver resolves a Developer, Publisher, Platform or Genre. The confidence and source
+// are those of the link from the game the entity was reached from, if any.
+type entityResolver struct {
+	kind    string
+	link    db.EntityLink
+	loaders *loaders
+}
+
+// ID resolves the id field of Developer, Publisher, Platform and Genre.
+func (e *entityResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(e.link.ID))
+}
+
+// Name resolves the name field of Developer, Publisher, Platform and Genre.
+func (e *entityResolver) Name() string {
+	return e.link.Name
+}
+
+// WikiTitle resolves the wikiTitle field of Developer, Publisher, Platform and Genre.
+func (e *entityResolver) WikiTitle() *string {
+	return optional(e.link.WikiTitle)
+}
+
+// Confidence resolves the confidence field of Developer, Publisher, Platform and Genre.
+func (e *entityResolver) Confidence() *float64 {
+	if e.link.Confidence == 0 {
+		return nil
+	}
+	return &e.link.Confidence
+}
+
+// Source resolves the source field of Developer, Publisher, Platform and Genre.
+func (e *entityResolver) Source() *string {
+	return optional(e.link.Source)
+}
+
+// entityGamesArgs narrows the games of an entity to those also linked to the given
+// entities, or sharing a platform with a game, and can leave one game out. First
+// and After page through them like Query.games.
+type entityGamesArgs struct {
+	Exclude           *graphql.ID
+	Developer         *graphql.ID
+	Platform          *graphql.ID
+	Genre             *graphql.ID
+	SharePlatformWith *graphql.ID
+	First             *int32
+	After             *string
+}
+
+// Games resolves a page of the games of the entity, filtered by args, batched with
+// the same page of the other entities of the request.
+func (e *entityResolver) Games(ctx context.Context, args entityGamesArgs) (*gameConnectionResolver, error) {
+	filter := db.GameFilter{EntityKind: e.kind, EntityID: e.link.ID}
+	var err error
+	if filter.Limit, filter.After, err = parsePage(args.First, args.After); err != nil {
+		return nil, err
+	}
+	for _, f := range []struct {
+		id     *graphql.ID
+		target *int
+	}{
+		{args.Exclude, &filter.Exclude},
+		{args.Developer, &filter.DeveloperID},
+		{args.Platform, &filter.PlatformID},
+		{args.Genre, &filter.GenreID},
+		{args.SharePlatformWith, &filter.SharePlatformWith},
+	} {
+		if f.id == nil {
+			continue
+		}
+		if *f.target, err = parseGraphQLID(*f.id); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.loaders.reserve(filter.Limit); err != nil {
+		return nil, err
+	}
+	list, err := e.loaders.entityGames.Load(ctx, filter)()
+	if err != nil {
+		return nil, err
+	}
+	return newGameConnection(e.loaders, list), nil
+}
+
+// newGameConnection resolves a page of games.
+func newGameConnection(l *loaders, list db.GameList) *gameConnectionResolver {
+	connection := &gameConnectionResolver{games: []*gameResolver{}}
+	for _, game := range list.Games {
+		connection.games = append(connection.games, &gameResolver{id: game.ID, loaders: l})
+	}
+	if list.Next != 0 {
+		cursor := EncodeCursor(list.Next)
+		connection.nextCursor = &cursor
+	}
+	return connection
+}
+
+// parsePage converts the first and after arguments of a list of games to a limit
+// (0 when not given) and the ID of the game the page follows.
+func parsePage(first *int32, after *string) (limit, afterID int, err error) {
+	if first != nil {
+		if *first <= 0 || *first > db.MaxGameLimit {
+			return 0, 0, errors.New("first must be between 1 and " + strconv.Itoa(db.MaxGameLimit))
+		}
+		limit = int(*first)
+	}
+	if after != nil {
+		if afterID, err = DecodeCursor(*after); err != nil {
+			return 0, 0, err
+		}
+	}
+	return limit, afterID, nil
+}
+
+// parseGraphQLID converts a GraphQL ID to a database ID.
+func parseGraphQLID(id graphql.ID) (int, error) {
+	n, err := strconv.Atoi(string(id))
+	if err != nil || n <= 0 {
+		return 0, errors.New("invalid id " + strconv.Quote(string(id)))
+	}
+	return n, nil
+}
+
+// optional returns nil for an empty string, for nullable GraphQL fields.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// deref returns the string s points to, or "" for nil.
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
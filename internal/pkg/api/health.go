@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkTimeout bounds how long a single readiness check may take.
+const checkTimeout = 3 * time.Second
+
+// CheckResult is the outcome of a single readiness check.
+type CheckResult struct {
+	Status string `json:"status"`          // "ok" or "error"
+	Error  string `json:"error,omitempty"` // Why the check failed
+}
+
+// ReadinessReport is the JSON body returned by /readyz.
+type ReadinessReport struct {
+	Status string                 `json:"status"` // "ready", "not ready" or "draining"
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// ErrStarting is reported by a PendingCheck until its dependency is set up.
+var ErrStarting = errors.New("starting")
+
+// PendingCheck is the readiness check of a dependency that is set up after the server
+// starts, such as worker processes loading a model. It fails with ErrStarting until
+// Set provides the dependency's own check.
+type PendingCheck struct {
+	check atomic.Pointer[Check]
+}
+
+// Set makes the pending check run check from now on.
+func (p *PendingCheck) Set(check Check) {
+	p.check.Store(&check)
+}
+
+// Check runs the check given to Set, or fails with ErrStarting before it was called.
+func (p *PendingCheck) Check(ctx context.Context) error {
+	check := p.check.Load()
+	if check == nil {
+		return ErrStarting
+	}
+	return (*check)(ctx)
+}
+
+// handleLiveness reports that the process is up. It never touches dependencies,
+// so a slow database does not get the pod restarted.
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadiness runs every registered check concurrently and reports the
+// status of each dependency. Any failing check makes the response a 503.
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	report := s.Readiness(r.Context())
+
+	status := http.StatusOK
+	if report.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// Readiness runs every registered check and summarizes the results.
+func (s *Server) Readiness(ctx context.Context) ReadinessReport {
+	s.mu.RLock()
+	checks := append([]namedCheck(nil), s.checks...)
+	s.mu.RUnlock()
+
+	report := ReadinessReport{Status: "ready", Checks: make(map[string]CheckResult, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c namedCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			result := CheckResult{Status: "ok"}
+			if err := c.check(checkCtx); err != nil {
+				result = CheckResult{Status: "error", Error: err.Error()}
+			}
+
+			mu.Lock()
+			report.Checks[c.name] = result
+			if result.Status != "ok" {
+				report.Status = "not ready"
+			}
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	if s.draining.Load() {
+		report.Status = "draining"
+	}
+	return report
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
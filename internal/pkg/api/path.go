@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"gamenet/internal/pkg/db"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PathFinder finds shortest connection paths between games and entities.
+// *db.Catalog searches PostgreSQL and *db.GraphPathFinder Neo4j.
+type PathFinder interface {
+	ShortestPath(ctx context.Context, from, to db.PathNode, opts db.PathOptions) (db.Path, error)
+}
+
+// HandlePath registers GET /path?from=games/1&to=developers/2, a shortest path between
+// two games or entities given as kind/id. ?relationships= restricts it to a
+// comma-separated list of relationship types (db.PathRelationships by default) and
+// ?max_hops= bounds its length (db.DefaultPathHops by default).
+func (s *Server) HandlePath(finder PathFinder) {
+	s.mux.HandleFunc("GET /path", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		from, err := db.ParsePathNode(query.Get("from"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "from: "+err.Error())
+			return
+		}
+		to, err := db.ParsePathNode(query.Get("to"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "to: "+err.Error())
+			return
+		}
+
+		// The finder validates the relationship types
+		var opts db.PathOptions
+		if relationships := query.Get("relationships"); relationships != "" {
+			for _, relationship := range strings.Split(relationships, ",") {
+				opts.Relationships = append(opts.Relationships, strings.ToUpper(strings.TrimSpace(relationship)))
+			}
+		}
+		if hops := query.Get("max_hops"); hops != "" {
+			n, err := strconv.Atoi(hops)
+			if err != nil || n <= 0 || n > db.MaxPathHops {
+				writeError(w, http.StatusBadRequest, "max_hops must be between 1 and "+strconv.Itoa(db.MaxPathHops))
+				return
+			}
+			opts.MaxHops = n
+		}
+
+		path, err := finder.ShortestPath(r.Context(), from, to, opts)
+		if errors.Is(err, db.ErrNoPath) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, db.ErrInvalidPathOptions) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			writeCatalogError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, path)
+	})
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"gamenet/internal/pkg/db"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Searcher finds games by words and completes names. *db.Catalog is the PostgreSQL
+// implementation.
+type Searcher interface {
+	Search(ctx context.Context, query string, limit, offset int) ([]db.SearchResult, error)
+	Autocomplete(ctx context.Context, prefix string, limit int) ([]db.Suggestion, error)
+}
+
+// SearchResponse is the JSON body of GET /search.
+type SearchResponse struct {
+	Query   string            `json:"query"`
+	Results []db.SearchResult `json:"results"`
+}
+
+// SuggestionResponse is the JSON body of GET /autocomplete.
+type SuggestionResponse struct {
+	Query       string          `json:"query"`
+	Suggestions []db.Suggestion `json:"suggestions"`
+}
+
+// HandleSearch registers the search endpoints:
+//
+//	GET /search?q=              games ranked by how well their title and summary match,
+//	                            with highlighted HTML snippets; ?offset= pages through them
+//	GET /autocomplete?q=        game titles and developer, publisher, platform, genre and series
+//	                            names completing q
+//
+// Both return at most ?limit= results (db.DefaultSearchLimit by default).
+func (s *Server) HandleSearch(searcher Searcher) {
+	s.mux.HandleFunc("GET /search", func(w http.ResponseWriter, r *http.Request) {
+		query, limit, ok := parseSearch(w, r)
+		if !ok {
+			return
+		}
+		offset := 0
+		if value := r.URL.Query().Get("offset"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, "offset must be a non-negative number")
+				return
+			}
+			offset = n
+		}
+
+		results, err := searcher.Search(r.Context(), query, limit, offset)
+		if err != nil {
+			writeCatalogError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, SearchResponse{Query: query, Results: results})
+	})
+
+	s.mux.HandleFunc("GET /autocomplete", func(w http.ResponseWriter, r *http.Request) {
+		query, limit, ok := parseSearch(w, r)
+		if !ok {
+			return
+		}
+		suggestions, err := searcher.Autocomplete(r.Context(), query, limit)
+		if err != nil {
+			writeCatalogError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, SuggestionResponse{Query: query, Suggestions: suggestions})
+	})
+}
+
+// parseSearch reads the ?q= and ?limit= parameters, answering 400 when they are invalid.
+func parseSearch(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "q must not be empty")
+		return "", 0, false
+	}
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > db.MaxSearchLimit {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(db.MaxSearchLimit))
+			return "", 0, false
+		}
+		limit = n
+	}
+	return query, limit, true
+}
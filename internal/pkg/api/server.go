@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultAddr is the address the HTTP server listens on when HTTP_ADDR is not set.
+const DefaultAddr = ":8080"
+
+// Check reports whether a dependency (database, worker pool, ...) is usable.
+type Check func(ctx context.Context) error
+
+// namedCheck is a readiness check and the name it is reported under.
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Server is the HTTP server of the gamenet binary. It serves the health endpoints
+// probed by Kubernetes and any handlers registered with Handle.
+type Server struct {
+	httpServer *http.Server
+	mux        *http.ServeMux
+
+	mu       sync.RWMutex
+	checks   []namedCheck
+	draining atomic.Bool // Set once shutdown has started, so /readyz fails
+}
+
+// NewServer returns a Server listening on addr with the health endpoints registered.
+func NewServer(addr string) *Server {
+	s := &Server{mux: http.NewServeMux()}
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	s.mux.HandleFunc("GET /healthz", s.handleLiveness)
+	s.mux.HandleFunc("GET /health", s.handleLiveness) // Kept for probes configured before /healthz existed
+	s.mux.HandleFunc("GET /readyz", s.handleReadiness)
+	return s
+}
+
+// NewServerFromEnv returns a Server listening on HTTP_ADDR, or DefaultAddr if it is not set.
+func NewServerFromEnv() *Server {
+	addr := os.Getenv("HTTP_ADDR")
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	return NewServer(addr)
+}
+
+// AddCheck registers a readiness check reported by /readyz under the given name.
+func (s *Server) AddCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, namedCheck{name: name, check: check})
+}
+
+// Handle registers a handler for the given pattern (see http.ServeMux).
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Handler returns the server's request router, e.g. for use with httptest.
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// ListenAndServe serves HTTP requests until Shutdown is called.
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("HTTP server listening on %s", listener.Addr())
+
+	err = s.httpServer.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Drain marks the server as shutting down: /readyz starts failing so load
+// balancers stop routing to it, while requests keep being served.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// Shutdown drains the server and stops it, waiting for in-flight requests until ctx expires.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Drain()
+	return s.httpServer.Shutdown(ctx)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"gamenet/internal/pkg/db"
+	"net/http"
+	"strconv"
+)
+
+// Recommender ranks the games similar to a game. *db.Catalog computes the ranking in
+// Go over PostgreSQL and *db.GraphRecommender with Cypher over Neo4j.
+type Recommender interface {
+	SimilarGames(ctx context.Context, id int, opts db.SimilarOptions) (db.SimilarGames, error)
+}
+
+// HandleSimilar registers GET /games/{id}/similar, the games most similar to a game
+// with their scores and the entities they share with it. ?limit= bounds the number of
+// games (db.DefaultSimilarLimit by default) and ?metric= picks db.MetricJaccard, the
+// default, or db.MetricAdamicAdar.
+func (s *Server) HandleSimilar(recommender Recommender) {
+	s.mux.HandleFunc("GET /games/{id}/similar", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+
+		query := r.URL.Query()
+		opts := db.SimilarOptions{Metric: query.Get("metric")}
+		switch opts.Metric {
+		case "", db.MetricJaccard, db.MetricAdamicAdar:
+		default:
+			writeError(w, http.StatusBadRequest, "metric must be "+db.MetricJaccard+" or "+db.MetricAdamicAdar)
+			return
+		}
+		if limit := query.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n <= 0 || n > db.MaxSimilarLimit {
+				writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(db.MaxSimilarLimit))
+				return
+			}
+			opts.Limit = n
+		}
+
+		similar, err := recommender.SimilarGames(r.Context(), id, opts)
+		if err != nil {
+			writeCatalogError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, similar)
+	})
+}
@@ -0,0 +1,443 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strconv"
+	"strings"
+)
+
+// Default and maximum number of games returned by ListGames.
+const (
+	DefaultGameLimit = 50
+	MaxGameLimit     = 200
+)
+
+// ErrNotFound is returned when a game or entity does not exist.
+var ErrNotFound = errors.New("not found")
+
+// catalogTable describes the tables an entity kind is stored in.
+type catalogTable struct {
+	table      string // Lookup table holding each name once
+	joinTable  string // Table linking games to the lookup table
+	joinColumn string // Column of the join table referencing the lookup table
+}
+
+// catalogTables maps the entity kinds of the catalog, as used in URLs, to their tables.
+var catalogTables = map[string]catalogTable{
+	"developers": {"Developers", "GameDevelopers", "developer_id"},
+	"publishers": {"Publishers", "GamePublishers", "publisher_id"},
+	"platforms":  {"Platforms", "GamePlatforms", "platform_id"},
+	"genres":     {"Genres", "GameGenres", "genre_id"},
+	"series":     {"Series", "GameSeries", "series_id"},
+}
+
+// CatalogKinds lists the entity kinds of the catalog in the order they are reported.
+var CatalogKinds = []string{"developers", "publishers", "platforms", "genres"}
+
+// releaseYear extracts the first four-digit number of a free-text release date.
+const releaseYear = `substring(g.release_date from '\d{4}')`
+
+// GameFilter selects the games returned by ListGames. Empty fields do not filter;
+// all others must hold.
+type GameFilter struct {
+	Developer         string // Name of one of the game's developers, compared case-insensitively
+	Platform          string // Name of one of the game's platforms, compared case-insensitively
+	Genre             string // Name of one of the game's genres, compared case-insensitively
+	DeveloperID       int    // ID of one of the game's developers
+	PlatformID        int    // ID of one of the game's platforms
+	GenreID           int    // ID of one of the game's genres
+	EntityKind        string // Kind of EntityID: one of CatalogKinds
+	EntityID          int    // ID of one of the game's entities of kind EntityKind
+	Exclude           int    // ID of a game left out
+	SharePlatformWith int    // ID of a game the game shares a platform with
+	Year              int    // Year of the game's first release
+	After             int    // Cursor: only games with a larger ID are returned
+	Limit             int    // Maximum number of games; DefaultGameLimit when 0, at most MaxGameLimit
+}
+
+// GameSummary is a game as listed by ListGames.
+type GameSummary struct {
+	ID          int    `json:"id"`
+	PageID      int    `json:"page_id,omitempty"`
+	Title       string `json:"title"`
+	ReleaseDate string `json:"release_date,omitempty"`
+	ReleaseYear int    `json:"release_year,omitempty"`
+}
+
+// GameList is a page of games. Next is the cursor to pass as GameFilter.After for
+// the following page, or 0 on the last page.
+type GameList struct {
+	Games []GameSummary
+	Next  int
+}
+
+// EntityLink is a developer, publisher, platform or genre of a game and how the link was extracted.
+type EntityLink struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	WikiTitle  string  `json:"wiki_title,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Source     string  `json:"source,omitempty"`
+}
+
+// GameDetail is a game with its summary and entities.
+type GameDetail struct {
+	GameSummary
+	Summary    string       `json:"summary,omitempty"`
+	Developers []EntityLink `json:"developers"`
+	Publishers []EntityLink `json:"publishers"`
+	Platforms  []EntityLink `json:"platforms"`
+	Genres     []EntityLink `json:"genres"`
+}
+
+// EntityDetail is a developer, publisher, platform or genre and how many games it is linked to.
+type EntityDetail struct {
+	ID        int    `json:"id"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	WikiTitle string `json:"wiki_title,omitempty"`
+	GameCount int    `json:"game_count"`
+}
+
+// Catalog answers read-only queries about the stored games and their entities.
+type Catalog struct {
+	db *sql.DB
+}
+
+// NewCatalog returns a catalog reading from db.
+func NewCatalog(db *sql.DB) *Catalog {
+	return &Catalog{db: db}
+}
+
+// ListGames returns the games matching filter in order of ID.
+func (c *Catalog) ListGames(ctx context.Context, filter GameFilter) (GameList, error) {
+	limit := gameLimit(filter)
+	args := []interface{}{filter.After}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	conditions, err := gameConditions(filter, arg)
+	if err != nil {
+		return GameList{}, err
+	}
+
+	// Fetch one game more than asked to know whether there is a next page
+	query := `SELECT g.id, COALESCE(g.page_id, 0), g.title, COALESCE(g.release_date, ''), COALESCE(` + releaseYear + `, '')
+		FROM Games g WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY g.id LIMIT ` + arg(limit+1)
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return GameList{}, fmt.Errorf("could not list games: %v", err)
+	}
+	defer rows.Close()
+
+	list := GameList{Games: []GameSummary{}}
+	for rows.Next() {
+		game, err := scanGameSummary(rows)
+		if err != nil {
+			return GameList{}, err
+		}
+		list.Games = append(list.Games, game)
+	}
+	if err := rows.Err(); err != nil {
+		return GameList{}, err
+	}
+	return list.truncate(limit), nil
+}
+
+// ListGamesOfEntities returns, for each of the given entities of one kind, the page
+// of its games matching filter, as ListGames would with the entity as EntityID. The
+// pages are read in one query; filter's EntityKind and EntityID are ignored.
+func (c *Catalog) ListGamesOfEntities(ctx context.Context, kind string, entityIDs []int, filter GameFilter) (map[int]GameList, error) {
+	t, ok := catalogTables[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown entity kind %q", kind)
+	}
+	filter.EntityKind, filter.EntityID = "", 0
+
+	limit := gameLimit(filter)
+	args := []interface{}{filter.After}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	conditions, err := gameConditions(filter, arg)
+	if err != nil {
+		return nil, err
+	}
+	conditions = append(conditions, `l.`+t.joinColumn+` = ANY(`+arg(pq.Array(entityIDs))+`)`)
+
+	// Number the games of every entity to cut each page, plus one game to know whether
+	// there is a next page, in SQL
+	query := `SELECT entity_id, id, page_id, title, release_date, release_year FROM (
+			SELECT l.` + t.joinColumn + ` AS entity_id, g.id, COALESCE(g.page_id, 0) AS page_id, g.title,
+				COALESCE(g.release_date, '') AS release_date, COALESCE(` + releaseYear + `, '') AS release_year,
+				ROW_NUMBER() OVER (PARTITION BY l.` + t.joinColumn + ` ORDER BY g.id) AS n
+			FROM ` + t.joinTable + ` l JOIN Games g ON g.id = l.game_id
+			WHERE ` + strings.Join(conditions, " AND ") + `
+		) page WHERE n <= ` + arg(limit+1) + ` ORDER BY entity_id, id`
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not list games of %s: %v", kind, err)
+	}
+	defer rows.Close()
+
+	lists := make(map[int]GameList, len(entityIDs))
+	for _, id := range entityIDs {
+		lists[id] = GameList{Games: []GameSummary{}}
+	}
+	for rows.Next() {
+		var entityID int
+		var game GameSummary
+		var year string
+		if err := rows.Scan(&entityID, &game.ID, &game.PageID, &game.Title, &game.ReleaseDate, &year); err != nil {
+			return nil, err
+		}
+		game.ReleaseYear, _ = strconv.Atoi(year)
+		list := lists[entityID]
+		list.Games = append(list.Games, game)
+		lists[entityID] = list
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for id, list := range lists {
+		lists[id] = list.truncate(limit)
+	}
+	return lists, nil
+}
+
+// gameLimit returns the number of games a page of filter holds.
+func gameLimit(filter GameFilter) int {
+	if filter.Limit <= 0 {
+		return DefaultGameLimit
+	}
+	return min(filter.Limit, MaxGameLimit)
+}
+
+// gameConditions returns the SQL conditions on the games g matching filter, apart
+// from the cursor. arg adds a query argument and returns its placeholder.
+func gameConditions(filter GameFilter, arg func(v interface{}) string) ([]string, error) {
+	conditions := []string{"g.id > $1"}
+	for _, f := range []struct {
+		kind string
+		name string
+		id   int
+	}{
+		{"developers", filter.Developer, filter.DeveloperID},
+		{"platforms", filter.Platform, filter.PlatformID},
+		{"genres", filter.Genre, filter.GenreID},
+	} {
+		t := catalogTables[f.kind]
+		if f.name != "" {
+			conditions = append(conditions, `EXISTS (SELECT 1 FROM `+t.joinTable+` l JOIN `+t.table+` e ON e.id = l.`+t.joinColumn+`
+				WHERE l.game_id = g.id AND lower(e.name) = lower(`+arg(f.name)+`))`)
+		}
+		if f.id != 0 {
+			conditions = append(conditions, `EXISTS (SELECT 1 FROM `+t.joinTable+` l
+				WHERE l.game_id = g.id AND l.`+t.joinColumn+` = `+arg(f.id)+`)`)
+		}
+	}
+	if filter.EntityID != 0 {
+		t, ok := catalogTables[filter.EntityKind]
+		if !ok {
+			return nil, fmt.Errorf("unknown entity kind %q", filter.EntityKind)
+		}
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM `+t.joinTable+` l
+			WHERE l.game_id = g.id AND l.`+t.joinColumn+` = `+arg(filter.EntityID)+`)`)
+	}
+	if filter.Exclude != 0 {
+		conditions = append(conditions, `g.id <> `+arg(filter.Exclude))
+	}
+	if filter.SharePlatformWith != 0 {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM GamePlatforms l
+			JOIN GamePlatforms other ON other.platform_id = l.platform_id
+			WHERE l.game_id = g.id AND other.game_id = `+arg(filter.SharePlatformWith)+`)`)
+	}
+	if filter.Year != 0 {
+		conditions = append(conditions, releaseYear+` = `+arg(strconv.Itoa(filter.Year)))
+	}
+	return conditions, nil
+}
+
+// truncate cuts a list fetched with one game more than limit down to limit games,
+// setting Next when the extra game shows there is a following page.
+func (l GameList) truncate(limit int) GameList {
+	if len(l.Games) > limit {
+		l.Games = l.Games[:limit]
+		l.Next = l.Games[limit-1].ID
+	}
+	return l
+}
+
+// Game returns the game with the given ID and its developers, publishers, platforms
+// and genres, or ErrNotFound.
+func (c *Catalog) Game(ctx context.Context, id int) (GameDetail, error) {
+	row := c.db.QueryRowContext(ctx, `SELECT g.id, COALESCE(g.page_id, 0), g.title, COALESCE(g.release_date, ''),
+			COALESCE(`+releaseYear+`, ''), COALESCE(g.summary, '')
+		FROM Games g WHERE g.id = $1`, id)
+
+	var game GameDetail
+	var year string
+	err := row.Scan(&game.ID, &game.PageID, &game.Title, &game.ReleaseDate, &year, &game.Summary)
+	if errors.Is(err, sql.ErrNoRows) {
+		return GameDetail{}, fmt.Errorf("game %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return GameDetail{}, fmt.Errorf("could not read game %d: %v", id, err)
+	}
+	game.ReleaseYear, _ = strconv.Atoi(year)
+
+	for _, kind := range CatalogKinds {
+		links, err := c.gameEntities(ctx, kind, id)
+		if err != nil {
+			return GameDetail{}, err
+		}
+		switch kind {
+		case "developers":
+			game.Developers = links
+		case "publishers":
+			game.Publishers = links
+		case "platforms":
+			game.Platforms = links
+		case "genres":
+			game.Genres = links
+		}
+	}
+	return game, nil
+}
+
+// Entity returns the developer, publisher, platform or genre with the given ID, or ErrNotFound.
+// kind is one of CatalogKinds.
+func (c *Catalog) Entity(ctx context.Context, kind string, id int) (EntityDetail, error) {
+	t, ok := catalogTables[kind]
+	if !ok {
+		return EntityDetail{}, fmt.Errorf("unknown entity kind %q", kind)
+	}
+
+	entity := EntityDetail{Kind: kind}
+	err := c.db.QueryRowContext(ctx, `SELECT e.id, e.name, COALESCE(e.wiki_title, ''),
+			(SELECT count(*) FROM `+t.joinTable+` l WHERE l.`+t.joinColumn+` = e.id)
+		FROM `+t.table+` e WHERE e.id = $1`, id).Scan(&entity.ID, &entity.Name, &entity.WikiTitle, &entity.GameCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return EntityDetail{}, fmt.Errorf("%s %d: %w", strings.TrimSuffix(kind, "s"), id, ErrNotFound)
+	}
+	if err != nil {
+		return EntityDetail{}, fmt.Errorf("could not read %s %d: %v", strings.TrimSuffix(kind, "s"), id, err)
+	}
+	return entity, nil
+}
+
+// gameEntities returns the entities of one kind linked to a game, by name.
+func (c *Catalog) gameEntities(ctx context.Context, kind string, gameID int) ([]EntityLink, error) {
+	t := catalogTables[kind]
+	rows, err := c.db.QueryContext(ctx, `SELECT e.id, e.name, COALESCE(e.wiki_title, ''), COALESCE(l.confidence, 0), COALESCE(l.source, '')
+		FROM `+t.joinTable+` l JOIN `+t.table+` e ON e.id = l.`+t.joinColumn+`
+		WHERE l.game_id = $1 ORDER BY e.name`, gameID)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s of game %d: %v", kind, gameID, err)
+	}
+	defer rows.Close()
+
+	links := []EntityLink{}
+	for rows.Next() {
+		var link EntityLink
+		if err := rows.Scan(&link.ID, &link.Name, &link.WikiTitle, &link.Confidence, &link.Source); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// scanGameSummary reads a row of id, page_id, title, release_date and release year.
+func scanGameSummary(rows *sql.Rows) (GameSummary, error) {
+	var game GameSummary
+	var year string
+	if err := rows.Scan(&game.ID, &game.PageID, &game.Title, &game.ReleaseDate, &year); err != nil {
+		return GameSummary{}, err
+	}
+	game.ReleaseYear, _ = strconv.Atoi(year)
+	return game, nil
+}
+
+// GamesByID returns the games with the given IDs, without their entities. IDs of
+// games that do not exist are left out.
+func (c *Catalog) GamesByID(ctx context.Context, ids []int) (map[int]GameDetail, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT g.id, COALESCE(g.page_id, 0), g.title, COALESCE(g.release_date, ''),
+			COALESCE(`+releaseYear+`, ''), COALESCE(g.summary, '')
+		FROM Games g WHERE g.id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("could not read games: %v", err)
+	}
+	defer rows.Close()
+
+	games := make(map[int]GameDetail, len(ids))
+	for rows.Next() {
+		var game GameDetail
+		var year string
+		if err := rows.Scan(&game.ID, &game.PageID, &game.Title, &game.ReleaseDate, &year, &game.Summary); err != nil {
+			return nil, err
+		}
+		game.ReleaseYear, _ = strconv.Atoi(year)
+		games[game.ID] = game
+	}
+	return games, rows.Err()
+}
+
+// EntitiesOfGames returns the entities of one kind linked to each of the given games,
+// by name.
+func (c *Catalog) EntitiesOfGames(ctx context.Context, kind string, gameIDs []int) (map[int][]EntityLink, error) {
+	t, ok := catalogTables[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown entity kind %q", kind)
+	}
+	rows, err := c.db.QueryContext(ctx, `SELECT l.game_id, e.id, e.name, COALESCE(e.wiki_title, ''), COALESCE(l.confidence, 0), COALESCE(l.source, '')
+		FROM `+t.joinTable+` l JOIN `+t.table+` e ON e.id = l.`+t.joinColumn+`
+		WHERE l.game_id = ANY($1) ORDER BY e.name`, pq.Array(gameIDs))
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s of games: %v", kind, err)
+	}
+	defer rows.Close()
+
+	links := make(map[int][]EntityLink, len(gameIDs))
+	for rows.Next() {
+		var gameID int
+		var link EntityLink
+		if err := rows.Scan(&gameID, &link.ID, &link.Name, &link.WikiTitle, &link.Confidence, &link.Source); err != nil {
+			return nil, err
+		}
+		links[gameID] = append(links[gameID], link)
+	}
+	return links, rows.Err()
+}
+
+// GamesOfEntities returns the IDs of the games linked to each of the given entities
+// of one kind, in order.
+func (c *Catalog) GamesOfEntities(ctx context.Context, kind string, entityIDs []int) (map[int][]int, error) {
+	t, ok := catalogTables[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown entity kind %q", kind)
+	}
+	rows, err := c.db.QueryContext(ctx, `SELECT l.`+t.joinColumn+`, l.game_id FROM `+t.joinTable+` l
+		WHERE l.`+t.joinColumn+` = ANY($1) ORDER BY l.game_id`, pq.Array(entityIDs))
+	if err != nil {
+		return nil, fmt.Errorf("could not read games of %s: %v", kind, err)
+	}
+	defer rows.Close()
+
+	games := make(map[int][]int, len(entityIDs))
+	for rows.Next() {
+		var entityID, gameID int
+		if err := rows.Scan(&entityID, &gameID); err != nil {
+			return nil, err
+		}
+		games[entityID] = append(games[entityID], gameID)
+	}
+	return games, rows.Err()
+}
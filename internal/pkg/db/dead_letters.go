@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gamenet/internal/pkg/wiki"
+	"github.com/lib/pq"
+	"net"
+	"strings"
+	"time"
+)
+
+// Pipeline stages a dead letter can come from.
+const (
+	StageExtract = "extract"
+	StageStore   = "store"
+	StageGraph   = "graph"
+)
+
+// DeadLetter is a page that failed a pipeline stage, with what is needed to replay it:
+// the fetched page for extraction failures, the extracted game for storage and graph failures.
+type DeadLetter struct {
+	ID            int64
+	PageID        int
+	Title         string
+	Stage         string
+	ErrorClass    string // Coarse category of the failure, e.g. ner_timeout or postgres_unique_violation
+	Error         string
+	Attempts      int
+	RunID         sql.NullInt64 // Ingestion run the latest failure happened in
+	FirstFailedAt time.Time
+	LastFailedAt  time.Time
+
+	Page *wiki.Page       // Set for extract failures
+	Game *wiki.GameRecord // Set for store and graph failures
+}
+
+// DeadLetterFilter selects dead letters. Zero fields match everything.
+type DeadLetterFilter struct {
+	Stage      string
+	ErrorClass string
+	IDs        []int64
+	Limit      int
+}
+
+// deadLetterPayload is the JSON stored in dead_letters.payload.
+type deadLetterPayload struct {
+	Page     *wiki.Page       `json:"page,omitempty"`
+	Wikitext string           `json:"wikitext,omitempty"` // Page.Wikitext is not serialized with the page
+	Aliases  []string         `json:"aliases,omitempty"`  // Nor are Page.Aliases
+	Game     *wiki.GameRecord `json:"game,omitempty"`
+}
+
+// RecordDeadLetter stores a page that failed a stage. item is the wiki.Page or
+// wiki.GameRecord the stage was processing. A page that already failed the same stage
+// has its attempt count incremented and its error and payload replaced.
+func RecordDeadLetter(ctx context.Context, db *sql.DB, stage string, item interface{}, cause error, runID int64) error {
+	var pageID int
+	var title string
+	var payload deadLetterPayload
+	switch item := item.(type) {
+	case wiki.Page:
+		pageID, title = item.PageID, item.Title
+		payload.Page, payload.Wikitext, payload.Aliases = &item, item.Wikitext, item.Aliases
+	case wiki.GameRecord:
+		pageID, title = item.PageID, item.Title
+		payload.Game = &item
+	default:
+		return fmt.Errorf("cannot record dead letter for %T", item)
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	run := sql.NullInt64{Int64: runID, Valid: runID != 0}
+
+	_, err = db.ExecContext(ctx, `INSERT INTO dead_letters (page_id, title, stage, error_class, error, payload, run_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (page_id, stage) DO UPDATE SET
+			title = EXCLUDED.title,
+			error_class = EXCLUDED.error_class,
+			error = EXCLUDED.error,
+			payload = EXCLUDED.payload,
+			run_id = EXCLUDED.run_id,
+			attempts = dead_letters.attempts + 1,
+			last_failed_at = now()`,
+		pageID, title, stage, ErrorClass(cause), cause.Error(), encoded, run)
+	if err != nil {
+		return fmt.Errorf("could not record dead letter for page %d: %v", pageID, err)
+	}
+	return nil
+}
+
+// ResolveDeadLetters removes the dead letters of a page once it made it through the
+// given stage. An empty stage removes the letters of every stage.
+func ResolveDeadLetters(ctx context.Context, db *sql.DB, pageID int, stage string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM dead_letters WHERE page_id = $1 AND ($2 = '' OR stage = $2)`, pageID, stage)
+	if err != nil {
+		return fmt.Errorf("could not resolve dead letters of page %d: %v", pageID, err)
+	}
+	return nil
+}
+
+// ListDeadLetters returns the dead letters matching filter, oldest failure first.
+func ListDeadLetters(ctx context.Context, db *sql.DB, filter DeadLetterFilter) ([]DeadLetter, error) {
+	where, args := filter.where()
+	query := `SELECT id, page_id, title, stage, error_class, error, attempts, run_id, first_failed_at, last_failed_at, payload
+		FROM dead_letters` + where + ` ORDER BY last_failed_at, id`
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not list dead letters: %v", err)
+	}
+	defer rows.Close()
+
+	var letters []DeadLetter
+	for rows.Next() {
+		var letter DeadLetter
+		var encoded []byte
+		err := rows.Scan(&letter.ID, &letter.PageID, &letter.Title, &letter.Stage, &letter.ErrorClass, &letter.Error,
+			&letter.Attempts, &letter.RunID, &letter.FirstFailedAt, &letter.LastFailedAt, &encoded)
+		if err != nil {
+			return nil, fmt.Errorf("could not list dead letters: %v", err)
+		}
+
+		var payload deadLetterPayload
+		if err := json.Unmarshal(encoded, &payload); err != nil {
+			return nil, fmt.Errorf("could not decode dead letter %d: %v", letter.ID, err)
+		}
+		if payload.Page != nil {
+			payload.Page.Wikitext, payload.Page.Aliases = payload.Wikitext, payload.Aliases
+		}
+		letter.Page, letter.Game = payload.Page, payload.Game
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+// PurgeDeadLetters deletes the dead letters matching filter and returns how many were deleted.
+func PurgeDeadLetters(ctx context.Context, db *sql.DB, filter DeadLetterFilter) (int64, error) {
+	where, args := filter.where()
+	result, err := db.ExecContext(ctx, `DELETE FROM dead_letters`+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("could not purge dead letters: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// where builds the WHERE clause for the filter's non-zero fields.
+func (f DeadLetterFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if f.Stage != "" {
+		args = append(args, f.Stage)
+		conditions = append(conditions, fmt.Sprintf("stage = $%d", len(args)))
+	}
+	if f.ErrorClass != "" {
+		args = append(args, f.ErrorClass)
+		conditions = append(conditions, fmt.Sprintf("error_class = $%d", len(args)))
+	}
+	if len(f.IDs) > 0 {
+		args = append(args, pq.Array(f.IDs))
+		conditions = append(conditions, fmt.Sprintf("id = ANY($%d)", len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// ErrorClass sorts an error into a coarse class so dead letters can be grouped and
+// retried selectively, e.g. every NER timeout after the pool has been resized.
+func ErrorClass(err error) string {
+	var pqErr *pq.Error
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, wiki.ErrNERTimeout):
+		return "ner_timeout"
+	case errors.Is(err, wiki.ErrNERWorkerExited):
+		return "ner_crash"
+	case errors.Is(err, wiki.ErrNERPoolClosed):
+		return "ner_closed"
+	case errors.Is(err, wiki.ErrEmptyTitle):
+		return "invalid_record"
+	case errors.As(err, &pqErr):
+		return "postgres_" + pqErr.Code.Name()
+	case errors.As(err, &netErr):
+		return "network"
+	case errors.Is(err, wiki.ErrNERFailed):
+		return "ner_error"
+	}
+	return "unknown"
+}
@@ -0,0 +1,277 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gamenet/internal/pkg/wiki"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"strings"
+	"sync"
+)
+
+// DefaultGraphBatchSize is how many games GraphWriter buffers before writing them to Neo4j.
+const DefaultGraphBatchSize = 100
+
+// GraphRelation describes how entities with a given label are stored in the graph.
+type GraphRelation struct {
+	Node         string // Label of the entity node, e.g. Developer
+	Relationship string // Type of the relationship from the Game node, e.g. DEVELOPED_BY
+}
+
+// GraphRelations maps entity labels to the graph nodes and relationships they become.
+// Entities with other labels are not written to Neo4j.
+var GraphRelations = map[string]GraphRelation{
+	"Developer": {"Developer", "DEVELOPED_BY"},
+	"Publisher": {"Publisher", "PUBLISHED_BY"},
+	"Platform":  {"Platform", "RUNS_ON"},
+	"Genre":     {"Genre", "HAS_GENRE"},
+	"Series":    {"Series", "IN_SERIES"},
+}
+
+// graphLabelOrder fixes the order relationships are written in.
+var graphLabelOrder = []string{"Developer", "Publisher", "Platform", "Genre", "Series"}
+
+// GraphGame is a game and its entities as written to Neo4j. Games are identified
+// by their Wikipedia page ID, like in PostgreSQL.
+type GraphGame struct {
+	PageID   int
+	Title    string
+	Entities []wiki.Entity
+}
+
+// GraphBatchError is returned by GraphWriter when a batch could not be written, with
+// every game of the batch: none of them made it to Neo4j.
+type GraphBatchError struct {
+	Games []GraphGame
+	Err   error
+}
+
+func (e *GraphBatchError) Error() string {
+	return fmt.Sprintf("%v (batch of %d games)", e.Err, len(e.Games))
+}
+
+func (e *GraphBatchError) Unwrap() error {
+	return e.Err
+}
+
+// GraphWriter writes games and their relationships to Neo4j in batches.
+type GraphWriter struct {
+	Driver    neo4j.Driver
+	BatchSize int
+
+	mu      sync.Mutex
+	pending []GraphGame
+}
+
+// NewGraphWriter returns a GraphWriter using the given driver.
+func NewGraphWriter(driver neo4j.Driver) *GraphWriter {
+	return &GraphWriter{Driver: driver, BatchSize: DefaultGraphBatchSize}
+}
+
+// EnsureGraphSchema creates the uniqueness constraints MERGE relies on: one Game
+// node per page ID and one entity node per name and label, and indexes the Wikipedia
+// page entity nodes are looked up by.
+func (w *GraphWriter) EnsureGraphSchema() error {
+	session := w.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	statements := []string{
+		`CREATE CONSTRAINT game_page_id IF NOT EXISTS FOR (g:Game) REQUIRE g.page_id IS UNIQUE`,
+	}
+	for _, label := range graphLabelOrder {
+		node := GraphRelations[label].Node
+		statements = append(statements, fmt.Sprintf(
+			"CREATE CONSTRAINT %s_name IF NOT EXISTS FOR (n:%s) REQUIRE n.name IS UNIQUE",
+			strings.ToLower(node), node), fmt.Sprintf(
+			"CREATE INDEX %s_wiki_title IF NOT EXISTS FOR (n:%s) ON (n.wiki_title)",
+			strings.ToLower(node), node))
+	}
+
+	for _, statement := range statements {
+		if _, err := session.Run(statement, nil); err != nil {
+			return fmt.Errorf("failed to create Neo4j constraint: %v", err)
+		}
+	}
+	return nil
+}
+
+// Write buffers a game and writes the buffer to Neo4j once it holds BatchSize games.
+// When that fails, the returned *GraphBatchError holds the whole batch.
+func (w *GraphWriter) Write(game GraphGame) error {
+	w.mu.Lock()
+	w.pending = append(w.pending, game)
+	if len(w.pending) < w.batchSize() {
+		w.mu.Unlock()
+		return nil
+	}
+	batch := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	return w.writeBatch(batch)
+}
+
+// Flush writes any buffered games to Neo4j. When that fails, the returned
+// *GraphBatchError holds the games that were buffered.
+func (w *GraphWriter) Flush() error {
+	w.mu.Lock()
+	batch := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	return w.writeBatch(batch)
+}
+
+// writeBatch writes a batch taken from the buffer, which is dropped from it either way.
+func (w *GraphWriter) writeBatch(batch []GraphGame) error {
+	if err := w.WriteGames(batch); err != nil {
+		return &GraphBatchError{Games: batch, Err: err}
+	}
+	return nil
+}
+
+// WriteGames MERGEs the Game nodes, their entity nodes and the relationships between
+// them in a single transaction. Each game's previous relationships are replaced,
+// so the graph mirrors the entity set stored in PostgreSQL. Games without a page ID
+// are skipped.
+//
+// Entities must carry the names and links PostgreSQL stored them under (see
+// wiki.UpsertGameEntities). Like its row, an entity node is the node of its
+// Wikipedia page when it has one, otherwise the node with its name.
+func (w *GraphWriter) WriteGames(games []GraphGame) error {
+	var gameRows []map[string]interface{}
+	relationRows := make(map[string][]map[string]interface{})
+
+	for _, game := range games {
+		if game.PageID == 0 {
+			continue
+		}
+		gameRows = append(gameRows, map[string]interface{}{
+			"page_id": int64(game.PageID),
+			"title":   game.Title,
+		})
+
+		seen := make(map[[2]string]bool)
+		for _, entity := range game.Entities {
+			entity.Text = strings.TrimSpace(entity.Text)
+			key := [2]string{entity.Label, entity.Text}
+			if _, ok := GraphRelations[entity.Label]; !ok || entity.Text == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			relationRows[entity.Label] = append(relationRows[entity.Label], map[string]interface{}{
+				"page_id":    int64(game.PageID),
+				"name":       entity.Text,
+				"link":       entity.Link,
+				"confidence": entity.Confidence,
+				"source":     entity.Source,
+			})
+		}
+	}
+	if len(gameRows) == 0 {
+		return nil
+	}
+
+	session := w.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		// Create or update the games and drop their previous relationships
+		query := `UNWIND $games AS game
+			MERGE (g:Game {page_id: game.page_id})
+			SET g.title = game.title
+			WITH g
+			OPTIONAL MATCH (g)-[r:` + graphRelationshipTypes() + `]->()
+			DELETE r`
+		if _, err := tx.Run(query, map[string]interface{}{"games": gameRows}); err != nil {
+			return nil, err
+		}
+
+		// Labels and relationship types cannot be parameters, so write one statement per relation
+		for _, label := range graphLabelOrder {
+			rows := relationRows[label]
+			if len(rows) == 0 {
+				continue
+			}
+			relation := GraphRelations[label]
+			query := `UNWIND $rows AS row
+				MATCH (g:Game {page_id: row.page_id})
+				OPTIONAL MATCH (linked:` + relation.Node + ` {wiki_title: row.link})
+				FOREACH (_ IN CASE WHEN linked IS NULL THEN [1] ELSE [] END |
+					MERGE (e:` + relation.Node + ` {name: row.name})
+					SET e.wiki_title = CASE row.link WHEN '' THEN e.wiki_title ELSE row.link END
+					MERGE (g)-[r:` + relation.Relationship + `]->(e)
+					SET r.confidence = row.confidence, r.source = row.source)
+				FOREACH (_ IN CASE WHEN linked IS NULL THEN [] ELSE [1] END |
+					SET linked.name = row.name
+					MERGE (g)-[r:` + relation.Relationship + `]->(linked)
+					SET r.confidence = row.confidence, r.source = row.source)`
+			if _, err := tx.Run(query, map[string]interface{}{"rows": rows}); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not write games to Neo4j: %v", err)
+	}
+	return nil
+}
+
+// StoredGraphGame reads the game PostgreSQL stores for a page, with the entities
+// GraphWriter writes, e.g. to write it again after a failed batch. It returns
+// sql.ErrNoRows when no game has that page ID anymore.
+func StoredGraphGame(ctx context.Context, pg *sql.DB, pageID int) (GraphGame, error) {
+	game := GraphGame{PageID: pageID}
+	var gameID int
+	err := pg.QueryRowContext(ctx, `SELECT id, title FROM Games WHERE page_id = $1`, pageID).Scan(&gameID, &game.Title)
+	if err != nil {
+		return game, err
+	}
+	game.Entities, err = wiki.GameEntities(ctx, pg, gameID, 0)
+	return game, err
+}
+
+func (w *GraphWriter) batchSize() int {
+	if w.BatchSize <= 0 {
+		return DefaultGraphBatchSize
+	}
+	return w.BatchSize
+}
+
+// graphRelationshipTypes returns the relationship types GraphWriter manages, joined for a Cypher pattern.
+func graphRelationshipTypes() string {
+	types := make([]string, 0, len(graphLabelOrder))
+	for _, label := range graphLabelOrder {
+		types = append(types, GraphRelations[label].Relationship)
+	}
+	return strings.Join(types, "|")
+}
+
+// DeleteGames removes the Game nodes with the given page IDs and their relationships.
+// Entity nodes are kept, even if no other game refers to them.
+func (w *GraphWriter) DeleteGames(pageIDs []int) error {
+	if len(pageIDs) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(pageIDs))
+	for i, id := range pageIDs {
+		ids[i] = int64(id)
+	}
+
+	session := w.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		_, err := tx.Run(`MATCH (g:Game) WHERE g.page_id IN $ids DETACH DELETE g`, map[string]interface{}{"ids": ids})
+		return nil, err
+	})
+	if err != nil {
+		return fmt.Errorf("could not delete games from Neo4j: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,458 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"sort"
+	"strings"
+	"time"
+)
+
+// syncBatchSize is how many rows each reconciling Cypher statement handles.
+const syncBatchSize = 500
+
+// GraphSyncOverlap is how far before opts.Since an incremental sync starts comparing.
+// updated_at is the start time of the writing transaction, so a game written by a
+// transaction that began before the last sync read PostgreSQL, but committed after it,
+// is older than that sync's SyncedAt; the overlap picks it up on the next run.
+const GraphSyncOverlap = 5 * time.Minute
+
+// syncedRelations maps the PostgreSQL join tables to the graph labels they mirror.
+var syncedRelations = []struct {
+	Label string
+	Query string
+}{
+	{"Developer", `SELECT g.page_id, d.name FROM GameDevelopers gd
+		JOIN Games g ON g.id = gd.game_id JOIN Developers d ON d.id = gd.developer_id`},
+	{"Publisher", `SELECT g.page_id, pu.name FROM GamePublishers gpu
+		JOIN Games g ON g.id = gpu.game_id JOIN Publishers pu ON pu.id = gpu.publisher_id`},
+	{"Platform", `SELECT g.page_id, p.name FROM GamePlatforms gp
+		JOIN Games g ON g.id = gp.game_id JOIN Platforms p ON p.id = gp.platform_id`},
+	{"Genre", `SELECT g.page_id, ge.name FROM GameGenres gg
+		JOIN Games g ON g.id = gg.game_id JOIN Genres ge ON ge.id = gg.genre_id`},
+	{"Series", `SELECT g.page_id, s.name FROM GameSeries gs
+		JOIN Games g ON g.id = gs.game_id JOIN Series s ON s.id = gs.series_id`},
+}
+
+// GraphSyncOptions configures SyncGraph.
+type GraphSyncOptions struct {
+	Since  time.Time // Only reconcile games updated after Since; zero means a full rebuild
+	DryRun bool      // Compute the diff without changing Neo4j
+}
+
+// GraphSyncReport summarizes the changes SyncGraph made (or would make) to Neo4j.
+type GraphSyncReport struct {
+	Full           bool
+	Since          time.Time // Lower bound of an incremental sync
+	SyncedAt       time.Time // PostgreSQL time the sync read from; the next incremental sync starts here
+	GamesScanned   int       // Games read from PostgreSQL
+	GamesSkipped   int       // Games without a page ID, which cannot be written to the graph
+	NodesCreated   int
+	NodesUpdated   int // Game nodes whose title changed
+	NodesDeleted   int // Game nodes without a PostgreSQL row, plus orphaned entity nodes on a full rebuild
+	EdgesCreated   int
+	EdgesDeleted   int
+	EntitiesByType map[string]int // Relationships created and deleted per label
+}
+
+// String formats the report as a short human-readable summary.
+func (r GraphSyncReport) String() string {
+	mode := "full"
+	if !r.Full {
+		mode = "incremental since " + r.Since.Format(time.RFC3339)
+	}
+	summary := fmt.Sprintf("Graph sync (%s): scanned %d games, skipped %d without page ID\n", mode, r.GamesScanned, r.GamesSkipped)
+	summary += fmt.Sprintf("  nodes: +%d ~%d -%d\n", r.NodesCreated, r.NodesUpdated, r.NodesDeleted)
+	summary += fmt.Sprintf("  edges: +%d -%d", r.EdgesCreated, r.EdgesDeleted)
+
+	labels := make([]string, 0, len(r.EntitiesByType))
+	for label := range r.EntitiesByType {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		summary += fmt.Sprintf("\n    %s: %d changed", label, r.EntitiesByType[label])
+	}
+	return summary
+}
+
+// graphEdge is a relationship from a Game node to an entity node.
+type graphEdge struct {
+	PageID int64
+	Label  string
+	Name   string
+}
+
+// syncState is one side (PostgreSQL or Neo4j) of a sync.
+type syncState struct {
+	titles map[int64]string
+	edges  map[graphEdge]bool
+}
+
+// SyncGraph reconciles Neo4j with the games and relationships stored in PostgreSQL,
+// which is the source of truth. Missing Game and entity nodes and relationships are
+// created, stale ones deleted, and changed titles updated.
+//
+// A full sync compares every game. An incremental sync (opts.Since set) only compares
+// games whose updated_at is after opts.Since minus GraphSyncOverlap; Game nodes whose
+// row was deleted are removed in both modes.
+func SyncGraph(ctx context.Context, pg *sql.DB, driver neo4j.Driver, opts GraphSyncOptions) (GraphSyncReport, error) {
+	report := GraphSyncReport{Full: opts.Since.IsZero(), Since: opts.Since, EntitiesByType: make(map[string]int)}
+
+	// Read the clock and every row from one snapshot, so rows committed later are newer than SyncedAt
+	tx, err := pg.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return report, fmt.Errorf("could not start PostgreSQL transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if err := tx.QueryRowContext(ctx, `SELECT now()`).Scan(&report.SyncedAt); err != nil {
+		return report, fmt.Errorf("could not read PostgreSQL time: %v", err)
+	}
+
+	since := opts.Since
+	if !since.IsZero() {
+		since = since.Add(-GraphSyncOverlap)
+	}
+	want, allIDs, err := readRelationalGraph(ctx, tx, since, &report)
+	if err != nil {
+		return report, err
+	}
+	if err := tx.Commit(); err != nil {
+		return report, fmt.Errorf("could not read PostgreSQL snapshot: %v", err)
+	}
+
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	var scope []int64 // Page IDs to compare; nil compares every Game node
+	if !report.Full {
+		scope = make([]int64, 0, len(want.titles))
+		for pageID := range want.titles {
+			scope = append(scope, pageID)
+		}
+	}
+	have, graphIDs, err := readGraph(session, scope)
+	if err != nil {
+		return report, err
+	}
+
+	// Diff the two sides
+	var createGames, updateGames []map[string]interface{}
+	for pageID, title := range want.titles {
+		current, ok := have.titles[pageID]
+		row := map[string]interface{}{"page_id": pageID, "title": title}
+		switch {
+		case !ok:
+			createGames = append(createGames, row)
+		case current != title:
+			updateGames = append(updateGames, row)
+		}
+	}
+	var deleteGames []interface{}
+	for _, pageID := range graphIDs {
+		if !allIDs[pageID] {
+			deleteGames = append(deleteGames, pageID)
+		}
+	}
+
+	createEdges := make(map[string][]map[string]interface{})
+	deleteEdges := make(map[string][]map[string]interface{})
+	for edge := range want.edges {
+		if !have.edges[edge] {
+			createEdges[edge.Label] = append(createEdges[edge.Label], edgeRow(edge))
+			report.EntitiesByType[edge.Label]++
+		}
+	}
+	for edge := range have.edges {
+		// Relationships of deleted games disappear with the node
+		if !want.edges[edge] && allIDs[edge.PageID] {
+			deleteEdges[edge.Label] = append(deleteEdges[edge.Label], edgeRow(edge))
+			report.EntitiesByType[edge.Label]++
+		}
+	}
+
+	report.NodesCreated = len(createGames)
+	report.NodesUpdated = len(updateGames)
+	report.NodesDeleted = len(deleteGames)
+	for _, rows := range createEdges {
+		report.EdgesCreated += len(rows)
+	}
+	for _, rows := range deleteEdges {
+		report.EdgesDeleted += len(rows)
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	// Apply the diff in batches
+	gameQuery := `UNWIND $rows AS row MERGE (g:Game {page_id: row.page_id}) SET g.title = row.title`
+	if err := runBatches(session, gameQuery, append(createGames, updateGames...)); err != nil {
+		return report, fmt.Errorf("could not write Game nodes: %v", err)
+	}
+	if err := runBatches(session, `UNWIND $rows AS page_id MATCH (g:Game {page_id: page_id}) DETACH DELETE g`, deleteGames); err != nil {
+		return report, fmt.Errorf("could not delete Game nodes: %v", err)
+	}
+	for _, relation := range syncedRelations {
+		graphRelation := GraphRelations[relation.Label]
+
+		// Count the entity nodes that do not exist yet before MERGE creates them
+		created, err := countMissingEntities(session, graphRelation.Node, createEdges[relation.Label])
+		if err != nil {
+			return report, err
+		}
+		report.NodesCreated += created
+
+		query := `UNWIND $rows AS row
+			MATCH (g:Game {page_id: row.page_id})
+			MERGE (e:` + graphRelation.Node + ` {name: row.name})
+			MERGE (g)-[:` + graphRelation.Relationship + `]->(e)`
+		if err := runBatches(session, query, createEdges[relation.Label]); err != nil {
+			return report, fmt.Errorf("could not create %s relationships: %v", graphRelation.Relationship, err)
+		}
+
+		query = `UNWIND $rows AS row
+			MATCH (:Game {page_id: row.page_id})-[r:` + graphRelation.Relationship + `]->(:` + graphRelation.Node + ` {name: row.name})
+			DELETE r`
+		if err := runBatches(session, query, deleteEdges[relation.Label]); err != nil {
+			return report, fmt.Errorf("could not delete %s relationships: %v", graphRelation.Relationship, err)
+		}
+	}
+
+	// A full rebuild also drops entity nodes no game points to anymore
+	if report.Full {
+		for _, relation := range syncedRelations {
+			node := GraphRelations[relation.Label].Node
+			deleted, err := runCount(session, `MATCH (e:`+node+`) WHERE NOT (e)--() DELETE e RETURN count(e)`, nil)
+			if err != nil {
+				return report, fmt.Errorf("could not delete orphaned %s nodes: %v", node, err)
+			}
+			report.NodesDeleted += int(deleted)
+		}
+	}
+	return report, nil
+}
+
+// readRelationalGraph reads the games and relationships SyncGraph should compare from
+// PostgreSQL, along with the page IDs of every game.
+func readRelationalGraph(ctx context.Context, pg *sql.Tx, since time.Time, report *GraphSyncReport) (syncState, map[int64]bool, error) {
+	state := syncState{titles: make(map[int64]string), edges: make(map[graphEdge]bool)}
+	allIDs := make(map[int64]bool)
+
+	filter := ""
+	args := []interface{}{}
+	if !since.IsZero() {
+		filter = " AND g.updated_at > $1"
+		args = append(args, since)
+	}
+
+	rows, err := pg.QueryContext(ctx, `SELECT g.page_id, g.title, g.updated_at > $1 FROM Games g`,
+		sinceOrEpoch(since))
+	if err != nil {
+		return state, nil, fmt.Errorf("could not read games: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pageID sql.NullInt64
+		var title string
+		var changed bool
+		if err := rows.Scan(&pageID, &title, &changed); err != nil {
+			return state, nil, fmt.Errorf("could not read games: %v", err)
+		}
+		if !pageID.Valid {
+			if changed {
+				report.GamesScanned++
+				report.GamesSkipped++
+			}
+			continue
+		}
+		allIDs[pageID.Int64] = true
+		if changed {
+			report.GamesScanned++
+			state.titles[pageID.Int64] = title
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return state, nil, fmt.Errorf("could not read games: %v", err)
+	}
+
+	for _, relation := range syncedRelations {
+		rows, err := pg.QueryContext(ctx, relation.Query+" WHERE g.page_id IS NOT NULL"+filter, args...)
+		if err != nil {
+			return state, nil, fmt.Errorf("could not read %s relationships: %v", relation.Label, err)
+		}
+		for rows.Next() {
+			edge := graphEdge{Label: relation.Label}
+			if err := rows.Scan(&edge.PageID, &edge.Name); err != nil {
+				rows.Close()
+				return state, nil, fmt.Errorf("could not read %s relationships: %v", relation.Label, err)
+			}
+			state.edges[edge] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return state, nil, fmt.Errorf("could not read %s relationships: %v", relation.Label, err)
+		}
+	}
+	return state, allIDs, nil
+}
+
+// readGraph reads the Game nodes and synced relationships from Neo4j. When scope is
+// not nil, titles and relationships are only read for those page IDs. The page IDs
+// of all Game nodes are returned as well.
+func readGraph(session neo4j.Session, scope []int64) (syncState, []int64, error) {
+	state := syncState{titles: make(map[int64]string), edges: make(map[graphEdge]bool)}
+
+	result, err := session.Run(`MATCH (g:Game) WHERE g.page_id IS NOT NULL RETURN g.page_id, g.title`, nil)
+	if err != nil {
+		return state, nil, fmt.Errorf("could not read Game nodes: %v", err)
+	}
+	inScope := make(map[int64]bool, len(scope))
+	for _, pageID := range scope {
+		inScope[pageID] = true
+	}
+	var allIDs []int64
+	for result.Next() {
+		values := result.Record().Values
+		pageID, _ := values[0].(int64)
+		title, _ := values[1].(string)
+		allIDs = append(allIDs, pageID)
+		if scope == nil || inScope[pageID] {
+			state.titles[pageID] = title
+		}
+	}
+	if err := result.Err(); err != nil {
+		return state, nil, fmt.Errorf("could not read Game nodes: %v", err)
+	}
+
+	nodeLabels := make(map[string]string, len(syncedRelations)) // Relationship type -> entity label
+	types := make([]string, 0, len(syncedRelations))
+	for _, relation := range syncedRelations {
+		graphRelation := GraphRelations[relation.Label]
+		nodeLabels[graphRelation.Relationship] = relation.Label
+		types = append(types, graphRelation.Relationship)
+	}
+
+	query := `MATCH (g:Game)-[r:` + strings.Join(types, "|") + `]->(e) WHERE g.page_id IS NOT NULL`
+	params := map[string]interface{}{}
+	if scope != nil {
+		query += ` AND g.page_id IN $scope`
+		params["scope"] = scope
+	}
+	result, err = session.Run(query+` RETURN g.page_id, type(r), e.name`, params)
+	if err != nil {
+		return state, nil, fmt.Errorf("could not read relationships: %v", err)
+	}
+	for result.Next() {
+		values := result.Record().Values
+		pageID, _ := values[0].(int64)
+		relType, _ := values[1].(string)
+		name, _ := values[2].(string)
+		state.edges[graphEdge{PageID: pageID, Label: nodeLabels[relType], Name: name}] = true
+	}
+	if err := result.Err(); err != nil {
+		return state, nil, fmt.Errorf("could not read relationships: %v", err)
+	}
+	return state, allIDs, nil
+}
+
+// countMissingEntities counts the distinct entity names in rows that have no node yet.
+func countMissingEntities(session neo4j.Session, node string, rows []map[string]interface{}) (int, error) {
+	seen := make(map[string]bool)
+	var names []interface{}
+	for _, row := range rows {
+		name := row["name"].(string)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return 0, nil
+	}
+
+	existing, err := runCount(session, `MATCH (e:`+node+`) WHERE e.name IN $names RETURN count(e)`,
+		map[string]interface{}{"names": names})
+	if err != nil {
+		return 0, fmt.Errorf("could not count %s nodes: %v", node, err)
+	}
+	return len(names) - int(existing), nil
+}
+
+// runBatches runs an UNWIND $rows query over rows, syncBatchSize rows per transaction.
+func runBatches[T any](session neo4j.Session, query string, rows []T) error {
+	for start := 0; start < len(rows); start += syncBatchSize {
+		end := min(start+syncBatchSize, len(rows))
+		batch := rows[start:end]
+		_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			_, err := tx.Run(query, map[string]interface{}{"rows": batch})
+			return nil, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCount runs a query returning a single count.
+func runCount(session neo4j.Session, query string, params map[string]interface{}) (int64, error) {
+	result, err := session.Run(query, params)
+	if err != nil {
+		return 0, err
+	}
+	record, err := result.Single()
+	if err != nil {
+		return 0, err
+	}
+	count, _ := record.Values[0].(int64)
+	return count, nil
+}
+
+// edgeRow converts an edge to the parameter map used by the relationship queries.
+func edgeRow(edge graphEdge) map[string]interface{} {
+	return map[string]interface{}{"page_id": edge.PageID, "name": edge.Name}
+}
+
+// sinceOrEpoch returns since, or the Unix epoch when since is zero, so every game counts as changed.
+func sinceOrEpoch(since time.Time) time.Time {
+	if since.IsZero() {
+		return time.Unix(0, 0)
+	}
+	return since
+}
+
+// LastGraphSync returns when the last completed sync read PostgreSQL, or the zero
+// time if the graph has never been synced.
+func LastGraphSync(driver neo4j.Driver) (time.Time, error) {
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	result, err := session.Run(`MATCH (s:GraphSync {source: 'postgres'}) RETURN s.synced_at`, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not read last graph sync: %v", err)
+	}
+	if !result.Next() {
+		return time.Time{}, result.Err()
+	}
+	value, _ := result.Record().Values[0].(string)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// RecordGraphSync stores the time a sync read PostgreSQL, for the next incremental sync.
+func RecordGraphSync(driver neo4j.Driver, syncedAt time.Time) error {
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	_, err := session.Run(`MERGE (s:GraphSync {source: 'postgres'}) SET s.synced_at = $synced_at`,
+		map[string]interface{}{"synced_at": syncedAt.UTC().Format(time.RFC3339Nano)})
+	if err != nil {
+		return fmt.Errorf("could not record graph sync: %v", err)
+	}
+	return nil
+}
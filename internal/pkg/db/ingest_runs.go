@@ -0,0 +1,296 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gamenet/internal/pkg/wiki"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Statuses of an ingestion run.
+const (
+	RunRunning     = "running"
+	RunCompleted   = "completed"
+	RunFailed      = "failed"
+	RunInterrupted = "interrupted"
+)
+
+// States of a page within an ingestion run.
+const (
+	PageFetched   = "fetched"
+	PageExtracted = "extracted"
+	PageStored    = "stored"
+	PageFailed    = "failed"
+)
+
+// ErrRunNotFound is returned when resuming a run that does not exist.
+var ErrRunNotFound = errors.New("ingestion run not found")
+
+// ErrRunInProgress is returned when resuming a run another process is working on.
+var ErrRunInProgress = errors.New("ingestion run is in progress in another process")
+
+// IngestConfig is the configuration a run was started with. Resuming a run reuses
+// it, so the resumed crawl covers the same pages as the original one.
+type IngestConfig struct {
+	Category      string `json:"category"`
+	APIURL        string `json:"api_url,omitempty"`
+	CategoryDepth int    `json:"category_depth"`
+	Infobox       bool   `json:"infobox"`
+	Extractor     string `json:"extractor,omitempty"`
+	Dump          string `json:"dump,omitempty"` // XML dump read instead of the API, if any
+}
+
+// IngestRun is a single crawl of a Wikipedia category, recorded in PostgreSQL
+// together with the state of every page it reached.
+//
+// The process working on a run holds an advisory lock keyed on its ID until Close,
+// so a run can only be resumed once nobody is working on it anymore. PostgreSQL
+// releases the lock of a process that crashed, leaving its run 'running'.
+type IngestRun struct {
+	ID         int64
+	StartedAt  time.Time
+	FinishedAt sql.NullTime
+	Config     IngestConfig
+	Status     string
+
+	db   *sql.DB
+	lock *sql.Conn // Session holding the run's advisory lock
+}
+
+// StartIngestRun records a new run with the given configuration.
+func StartIngestRun(ctx context.Context, db *sql.DB, config IngestConfig) (*IngestRun, error) {
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &IngestRun{Config: config, Status: RunRunning, db: db}
+	err = db.QueryRowContext(ctx,
+		`INSERT INTO ingest_runs (config) VALUES ($1) RETURNING id, started_at`, encoded).Scan(&run.ID, &run.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not start ingestion run: %v", err)
+	}
+	if run.lock, err = lockRun(ctx, db, run.ID); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// ResumeIngestRun marks a previous run as running again and returns it. Completed
+// runs and runs another process is working on cannot be resumed.
+func ResumeIngestRun(ctx context.Context, db *sql.DB, id int64) (*IngestRun, error) {
+	lock, err := lockRun(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	run := &IngestRun{ID: id, db: db, lock: lock}
+	if err := run.resume(ctx); err != nil {
+		run.Close()
+		return nil, err
+	}
+	return run, nil
+}
+
+// resume loads the run and marks it as running again.
+func (r *IngestRun) resume(ctx context.Context) error {
+	var config []byte
+	err := r.db.QueryRowContext(ctx,
+		`SELECT started_at, finished_at, config, status FROM ingest_runs WHERE id = $1`, r.ID,
+	).Scan(&r.StartedAt, &r.FinishedAt, &config, &r.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrRunNotFound, r.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not load ingestion run %d: %v", r.ID, err)
+	}
+	if r.Status == RunCompleted {
+		return fmt.Errorf("ingestion run %d has already completed", r.ID)
+	}
+	if err := json.Unmarshal(config, &r.Config); err != nil {
+		return fmt.Errorf("could not decode configuration of run %d: %v", r.ID, err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `UPDATE ingest_runs SET status = $2, finished_at = NULL WHERE id = $1`, r.ID, RunRunning)
+	if err != nil {
+		return fmt.Errorf("could not resume ingestion run %d: %v", r.ID, err)
+	}
+	r.Status = RunRunning
+	r.FinishedAt = sql.NullTime{}
+	return nil
+}
+
+// lockRun takes the advisory lock of run id on a dedicated connection, since advisory
+// locks belong to a session, and returns that connection. Run IDs stay far below
+// migrationLockKey, so the two locks cannot collide.
+func lockRun(ctx context.Context, db *sql.DB, id int64) (*sql.Conn, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not lock ingestion run %d: %v", id, err)
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, id).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not lock ingestion run %d: %v", id, err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %d", ErrRunInProgress, id)
+	}
+	return conn, nil
+}
+
+// Close releases the run's advisory lock, so it can be resumed again.
+func (r *IngestRun) Close() error {
+	if r.lock == nil {
+		return nil
+	}
+	_, err := r.lock.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, r.ID)
+	r.lock.Close()
+	r.lock = nil
+	if err != nil {
+		return fmt.Errorf("could not unlock ingestion run %d: %v", r.ID, err)
+	}
+	return nil
+}
+
+// StoredPages returns the IDs of the pages this run has already stored.
+func (r *IngestRun) StoredPages(ctx context.Context) (map[int]bool, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT page_id FROM ingest_pages WHERE run_id = $1 AND state = $2`, r.ID, PageStored)
+	if err != nil {
+		return nil, fmt.Errorf("could not read stored pages of run %d: %v", r.ID, err)
+	}
+	defer rows.Close()
+
+	stored := make(map[int]bool)
+	for rows.Next() {
+		var pageID int
+		if err := rows.Scan(&pageID); err != nil {
+			return nil, err
+		}
+		stored[pageID] = true
+	}
+	return stored, rows.Err()
+}
+
+// MarkFetched records that a batch of pages has been fetched from Wikipedia.
+// Pages that failed in an earlier attempt start over.
+func (r *IngestRun) MarkFetched(ctx context.Context, pages []wiki.Page) error {
+	if len(pages) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(pages))
+	titles := make([]string, len(pages))
+	for i, page := range pages {
+		ids[i] = int64(page.PageID)
+		titles[i] = page.Title
+	}
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO ingest_pages (run_id, page_id, title, state)
+		SELECT $1, page.id, page.title, $4 FROM unnest($2::bigint[], $3::text[]) AS page(id, title)
+		ON CONFLICT (run_id, page_id) DO UPDATE SET state = EXCLUDED.state, error = NULL, updated_at = now()`,
+		r.ID, pq.Array(ids), pq.Array(titles), PageFetched)
+	if err != nil {
+		return fmt.Errorf("could not record fetched pages of run %d: %v", r.ID, err)
+	}
+	return nil
+}
+
+// MarkPage records the state a page has reached. cause is stored as the page's
+// error when the state is PageFailed.
+func (r *IngestRun) MarkPage(ctx context.Context, pageID int, title, state string, cause error) error {
+	var message sql.NullString
+	if cause != nil {
+		message = sql.NullString{String: cause.Error(), Valid: true}
+	}
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO ingest_pages (run_id, page_id, title, state, error)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (run_id, page_id) DO UPDATE SET state = EXCLUDED.state, error = EXCLUDED.error, updated_at = now()`,
+		r.ID, pageID, title, state, message)
+	if err != nil {
+		return fmt.Errorf("could not record state of page %d in run %d: %v", pageID, r.ID, err)
+	}
+	return nil
+}
+
+// PageCounts returns how many pages of the run are in each state.
+func (r *IngestRun) PageCounts(ctx context.Context) (map[string]int, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT state, count(*) FROM ingest_pages WHERE run_id = $1 GROUP BY state`, r.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not count pages of run %d: %v", r.ID, err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var state string
+		var count int
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, err
+		}
+		counts[state] = count
+	}
+	return counts, rows.Err()
+}
+
+// Finish records the final status of the run.
+func (r *IngestRun) Finish(ctx context.Context, status string) error {
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE ingest_runs SET status = $2, finished_at = now() WHERE id = $1 RETURNING finished_at`,
+		r.ID, status).Scan(&r.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("could not finish ingestion run %d: %v", r.ID, err)
+	}
+	r.Status = status
+	return nil
+}
+
+// AddLabelCounts adds counts to the label report of the run, which accumulates over
+// resumptions.
+func (r *IngestRun) AddLabelCounts(ctx context.Context, counts wiki.LabelCounts) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var stored []byte
+	err = tx.QueryRowContext(ctx, `SELECT label_report FROM ingest_runs WHERE id = $1 FOR UPDATE`, r.ID).Scan(&stored)
+	if err != nil {
+		return fmt.Errorf("could not read label report of run %d: %v", r.ID, err)
+	}
+	var total wiki.LabelCounts
+	if err := json.Unmarshal(stored, &total); err != nil {
+		return fmt.Errorf("could not decode label report of run %d: %v", r.ID, err)
+	}
+	total.Add(counts)
+
+	encoded, err := json.Marshal(total)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE ingest_runs SET label_report = $2 WHERE id = $1`, r.ID, encoded); err != nil {
+		return fmt.Errorf("could not record label report of run %d: %v", r.ID, err)
+	}
+	return tx.Commit()
+}
+
+// LabelCounts returns the label report of the run.
+func (r *IngestRun) LabelCounts(ctx context.Context) (wiki.LabelCounts, error) {
+	var counts wiki.LabelCounts
+	var stored []byte
+	err := r.db.QueryRowContext(ctx, `SELECT label_report FROM ingest_runs WHERE id = $1`, r.ID).Scan(&stored)
+	if err != nil {
+		return counts, fmt.Errorf("could not read label report of run %d: %v", r.ID, err)
+	}
+	if err := json.Unmarshal(stored, &counts); err != nil {
+		return counts, fmt.Errorf("could not decode label report of run %d: %v", r.ID, err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS GamePlatforms;
+DROP TABLE IF EXISTS GameGenres;
+DROP TABLE IF EXISTS GameDevelopers;
+DROP TABLE IF EXISTS Platforms;
+DROP TABLE IF EXISTS Genres;
+DROP TABLE IF EXISTS Developers;
+DROP TABLE IF EXISTS Games;
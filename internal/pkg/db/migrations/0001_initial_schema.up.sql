@@ -0,0 +1,40 @@
+-- Baseline schema. IF NOT EXISTS lets databases created from the old init.sql adopt it.
+CREATE TABLE IF NOT EXISTS Games (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    summary TEXT,
+    release_date VARCHAR(255)
+);
+
+CREATE TABLE IF NOT EXISTS Developers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Genres (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Platforms (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS GameDevelopers (
+    game_id INTEGER REFERENCES Games(id),
+    developer_id INTEGER REFERENCES Developers(id),
+    PRIMARY KEY (game_id, developer_id)
+);
+
+CREATE TABLE IF NOT EXISTS GameGenres (
+    game_id INTEGER REFERENCES Games(id),
+    genre_id INTEGER REFERENCES Genres(id),
+    PRIMARY KEY (game_id, genre_id)
+);
+
+CREATE TABLE IF NOT EXISTS GamePlatforms (
+    game_id INTEGER REFERENCES Games(id),
+    platform_id INTEGER REFERENCES Platforms(id),
+    PRIMARY KEY (game_id, platform_id)
+);
@@ -0,0 +1,28 @@
+DROP INDEX IF EXISTS gameplatforms_platform_id_idx;
+DROP INDEX IF EXISTS gamegenres_genre_id_idx;
+DROP INDEX IF EXISTS gamedevelopers_developer_id_idx;
+
+ALTER TABLE GamePlatforms
+    DROP CONSTRAINT gameplatforms_game_id_fkey,
+    DROP CONSTRAINT gameplatforms_platform_id_fkey,
+    ADD CONSTRAINT gameplatforms_game_id_fkey FOREIGN KEY (game_id) REFERENCES Games(id),
+    ADD CONSTRAINT gameplatforms_platform_id_fkey FOREIGN KEY (platform_id) REFERENCES Platforms(id);
+
+ALTER TABLE GameGenres
+    DROP CONSTRAINT gamegenres_game_id_fkey,
+    DROP CONSTRAINT gamegenres_genre_id_fkey,
+    ADD CONSTRAINT gamegenres_game_id_fkey FOREIGN KEY (game_id) REFERENCES Games(id),
+    ADD CONSTRAINT gamegenres_genre_id_fkey FOREIGN KEY (genre_id) REFERENCES Genres(id);
+
+ALTER TABLE GameDevelopers
+    DROP CONSTRAINT gamedevelopers_game_id_fkey,
+    DROP CONSTRAINT gamedevelopers_developer_id_fkey,
+    ADD CONSTRAINT gamedevelopers_game_id_fkey FOREIGN KEY (game_id) REFERENCES Games(id),
+    ADD CONSTRAINT gamedevelopers_developer_id_fkey FOREIGN KEY (developer_id) REFERENCES Developers(id);
+
+ALTER TABLE Genres DROP CONSTRAINT genres_name_key;
+ALTER TABLE Platforms DROP CONSTRAINT platforms_name_key;
+ALTER TABLE Developers DROP CONSTRAINT developers_name_key;
+
+ALTER TABLE Games DROP CONSTRAINT games_title_not_empty;
+ALTER TABLE Games DROP CONSTRAINT games_title_key;
@@ -0,0 +1,87 @@
+-- Databases filled by the old insert path hold the same game or entity several times.
+-- Keep the lowest ID of each title or name, move the relationships of the other rows
+-- onto it and delete the other rows, so the unique constraints below can be added.
+CREATE TEMPORARY TABLE developer_merges ON COMMIT DROP AS
+    SELECT id, min(id) OVER (PARTITION BY name) AS keep_id FROM Developers;
+INSERT INTO GameDevelopers (game_id, developer_id)
+    SELECT gd.game_id, m.keep_id FROM GameDevelopers gd JOIN developer_merges m ON m.id = gd.developer_id
+    WHERE m.id <> m.keep_id
+    ON CONFLICT DO NOTHING;
+DELETE FROM GameDevelopers gd USING developer_merges m WHERE m.id = gd.developer_id AND m.id <> m.keep_id;
+DELETE FROM Developers d USING developer_merges m WHERE m.id = d.id AND m.id <> m.keep_id;
+
+CREATE TEMPORARY TABLE platform_merges ON COMMIT DROP AS
+    SELECT id, min(id) OVER (PARTITION BY name) AS keep_id FROM Platforms;
+INSERT INTO GamePlatforms (game_id, platform_id)
+    SELECT gp.game_id, m.keep_id FROM GamePlatforms gp JOIN platform_merges m ON m.id = gp.platform_id
+    WHERE m.id <> m.keep_id
+    ON CONFLICT DO NOTHING;
+DELETE FROM GamePlatforms gp USING platform_merges m WHERE m.id = gp.platform_id AND m.id <> m.keep_id;
+DELETE FROM Platforms p USING platform_merges m WHERE m.id = p.id AND m.id <> m.keep_id;
+
+CREATE TEMPORARY TABLE genre_merges ON COMMIT DROP AS
+    SELECT id, min(id) OVER (PARTITION BY name) AS keep_id FROM Genres;
+INSERT INTO GameGenres (game_id, genre_id)
+    SELECT gg.game_id, m.keep_id FROM GameGenres gg JOIN genre_merges m ON m.id = gg.genre_id
+    WHERE m.id <> m.keep_id
+    ON CONFLICT DO NOTHING;
+DELETE FROM GameGenres gg USING genre_merges m WHERE m.id = gg.genre_id AND m.id <> m.keep_id;
+DELETE FROM Genres g USING genre_merges m WHERE m.id = g.id AND m.id <> m.keep_id;
+
+CREATE TEMPORARY TABLE game_merges ON COMMIT DROP AS
+    SELECT id, min(id) OVER (PARTITION BY title) AS keep_id FROM Games;
+INSERT INTO GameDevelopers (game_id, developer_id)
+    SELECT m.keep_id, gd.developer_id FROM GameDevelopers gd JOIN game_merges m ON m.id = gd.game_id
+    WHERE m.id <> m.keep_id
+    ON CONFLICT DO NOTHING;
+INSERT INTO GamePlatforms (game_id, platform_id)
+    SELECT m.keep_id, gp.platform_id FROM GamePlatforms gp JOIN game_merges m ON m.id = gp.game_id
+    WHERE m.id <> m.keep_id
+    ON CONFLICT DO NOTHING;
+INSERT INTO GameGenres (game_id, genre_id)
+    SELECT m.keep_id, gg.genre_id FROM GameGenres gg JOIN game_merges m ON m.id = gg.game_id
+    WHERE m.id <> m.keep_id
+    ON CONFLICT DO NOTHING;
+DELETE FROM GameDevelopers gd USING game_merges m WHERE m.id = gd.game_id AND m.id <> m.keep_id;
+DELETE FROM GamePlatforms gp USING game_merges m WHERE m.id = gp.game_id AND m.id <> m.keep_id;
+DELETE FROM GameGenres gg USING game_merges m WHERE m.id = gg.game_id AND m.id <> m.keep_id;
+DELETE FROM Games g USING game_merges m WHERE m.id = g.id AND m.id <> m.keep_id;
+
+-- Games are identified by title and must have one.
+ALTER TABLE Games ADD CONSTRAINT games_title_key UNIQUE (title);
+ALTER TABLE Games ADD CONSTRAINT games_title_not_empty CHECK (title <> '');
+
+-- Lookup tables hold each name once, so inserts can rely on ON CONFLICT (name).
+ALTER TABLE Developers ADD CONSTRAINT developers_name_key UNIQUE (name);
+ALTER TABLE Platforms ADD CONSTRAINT platforms_name_key UNIQUE (name);
+ALTER TABLE Genres ADD CONSTRAINT genres_name_key UNIQUE (name);
+
+-- Deleting a game or an entity removes its relationships with it.
+ALTER TABLE GameDevelopers
+    ALTER COLUMN game_id SET NOT NULL,
+    ALTER COLUMN developer_id SET NOT NULL,
+    DROP CONSTRAINT gamedevelopers_game_id_fkey,
+    DROP CONSTRAINT gamedevelopers_developer_id_fkey,
+    ADD CONSTRAINT gamedevelopers_game_id_fkey FOREIGN KEY (game_id) REFERENCES Games(id) ON DELETE CASCADE,
+    ADD CONSTRAINT gamedevelopers_developer_id_fkey FOREIGN KEY (developer_id) REFERENCES Developers(id) ON DELETE CASCADE;
+
+ALTER TABLE GameGenres
+    ALTER COLUMN game_id SET NOT NULL,
+    ALTER COLUMN genre_id SET NOT NULL,
+    DROP CONSTRAINT gamegenres_game_id_fkey,
+    DROP CONSTRAINT gamegenres_genre_id_fkey,
+    ADD CONSTRAINT gamegenres_game_id_fkey FOREIGN KEY (game_id) REFERENCES Games(id) ON DELETE CASCADE,
+    ADD CONSTRAINT gamegenres_genre_id_fkey FOREIGN KEY (genre_id) REFERENCES Genres(id) ON DELETE CASCADE;
+
+ALTER TABLE GamePlatforms
+    ALTER COLUMN game_id SET NOT NULL,
+    ALTER COLUMN platform_id SET NOT NULL,
+    DROP CONSTRAINT gameplatforms_game_id_fkey,
+    DROP CONSTRAINT gameplatforms_platform_id_fkey,
+    ADD CONSTRAINT gameplatforms_game_id_fkey FOREIGN KEY (game_id) REFERENCES Games(id) ON DELETE CASCADE,
+    ADD CONSTRAINT gameplatforms_platform_id_fkey FOREIGN KEY (platform_id) REFERENCES Platforms(id) ON DELETE CASCADE;
+
+-- Find a game's relationships starting from the entity side.
+CREATE INDEX gamedevelopers_developer_id_idx ON GameDevelopers (developer_id);
+CREATE INDEX gamegenres_genre_id_idx ON GameGenres (genre_id);
+CREATE INDEX gameplatforms_platform_id_idx ON GamePlatforms (platform_id);
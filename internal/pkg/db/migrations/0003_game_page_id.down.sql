@@ -0,0 +1,6 @@
+-- Fails while two pages share a title.
+DROP INDEX games_title_key;
+ALTER TABLE Games ADD CONSTRAINT games_title_key UNIQUE (title);
+
+ALTER TABLE Games DROP CONSTRAINT games_page_id_key;
+ALTER TABLE Games DROP COLUMN page_id;
@@ -0,0 +1,9 @@
+-- Games ingested from Wikipedia are identified by their stable page ID.
+-- Rows inserted without one (NULL) do not conflict with each other.
+ALTER TABLE Games ADD COLUMN page_id BIGINT;
+ALTER TABLE Games ADD CONSTRAINT games_page_id_key UNIQUE (page_id);
+
+-- Pages can swap or take over each other's titles, so only games stored without a
+-- page ID are still identified by their title.
+ALTER TABLE Games DROP CONSTRAINT games_title_key;
+CREATE UNIQUE INDEX games_title_key ON Games (title) WHERE page_id IS NULL;
@@ -0,0 +1,4 @@
+DROP TRIGGER games_touch_updated_at ON Games;
+DROP FUNCTION games_touch_updated_at();
+DROP INDEX IF EXISTS games_updated_at_idx;
+ALTER TABLE Games DROP COLUMN updated_at;
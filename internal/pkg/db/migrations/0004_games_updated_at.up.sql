@@ -0,0 +1,15 @@
+-- Track when each game last changed so the graph can be synced incrementally.
+ALTER TABLE Games ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX games_updated_at_idx ON Games (updated_at);
+
+CREATE FUNCTION games_touch_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Every upsert rewrites the game row, so relationship changes also bump updated_at.
+CREATE TRIGGER games_touch_updated_at
+    BEFORE UPDATE ON Games
+    FOR EACH ROW EXECUTE FUNCTION games_touch_updated_at();
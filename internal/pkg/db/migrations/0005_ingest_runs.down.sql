@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ingest_pages;
+DROP TABLE IF EXISTS ingest_runs;
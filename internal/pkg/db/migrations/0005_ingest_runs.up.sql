@@ -0,0 +1,22 @@
+-- Ingestion runs and the progress of every page within them, so an interrupted
+-- crawl can be resumed without re-processing stored pages.
+CREATE TABLE ingest_runs (
+    id          BIGSERIAL PRIMARY KEY,
+    started_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ,
+    config      JSONB NOT NULL DEFAULT '{}',
+    status      TEXT NOT NULL DEFAULT 'running'
+        CONSTRAINT ingest_runs_status_check CHECK (status IN ('running', 'completed', 'failed', 'interrupted'))
+);
+
+CREATE TABLE ingest_pages (
+    run_id     BIGINT NOT NULL REFERENCES ingest_runs(id) ON DELETE CASCADE,
+    page_id    BIGINT NOT NULL,
+    title      TEXT NOT NULL,
+    state      TEXT NOT NULL
+        CONSTRAINT ingest_pages_state_check CHECK (state IN ('fetched', 'extracted', 'stored', 'failed')),
+    error      TEXT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (run_id, page_id)
+);
+CREATE INDEX ingest_pages_run_state_idx ON ingest_pages (run_id, state);
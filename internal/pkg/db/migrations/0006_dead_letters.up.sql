@@ -0,0 +1,18 @@
+-- Pages that failed extraction or storage, kept with the data needed to replay them.
+-- A page has at most one dead letter per stage; repeated failures bump attempts.
+CREATE TABLE dead_letters (
+    id             BIGSERIAL PRIMARY KEY,
+    page_id        BIGINT NOT NULL,
+    title          TEXT NOT NULL,
+    stage          TEXT NOT NULL
+        CONSTRAINT dead_letters_stage_check CHECK (stage IN ('extract', 'store')),
+    error_class    TEXT NOT NULL,
+    error          TEXT NOT NULL,
+    payload        JSONB NOT NULL,
+    attempts       INTEGER NOT NULL DEFAULT 1,
+    run_id         BIGINT REFERENCES ingest_runs(id) ON DELETE SET NULL,
+    first_failed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_failed_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT dead_letters_page_stage_key UNIQUE (page_id, stage)
+);
+CREATE INDEX dead_letters_error_class_idx ON dead_letters (error_class);
@@ -0,0 +1 @@
+ALTER TABLE Games DROP COLUMN revision_id;
@@ -0,0 +1,3 @@
+-- The Wikipedia revision a game was last extracted from, so refreshes only
+-- re-extract pages edited since. NULL when the revision is unknown.
+ALTER TABLE Games ADD COLUMN revision_id BIGINT;
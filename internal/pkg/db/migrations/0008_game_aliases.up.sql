@@ -0,0 +1,11 @@
+-- Every title a game is known by: its current title, the titles it had before a
+-- page move and the redirects pointing at its page, normalized like Wikipedia
+-- titles. Lookups by any of them resolve to the same game.
+CREATE TABLE GameAliases (
+    alias VARCHAR(255) PRIMARY KEY,
+    game_id INTEGER NOT NULL REFERENCES Games(id) ON DELETE CASCADE
+);
+CREATE INDEX gamealiases_game_id_idx ON GameAliases (game_id);
+
+-- Games stored so far are known by their title.
+INSERT INTO GameAliases (alias, game_id) SELECT title, id FROM Games ON CONFLICT DO NOTHING;
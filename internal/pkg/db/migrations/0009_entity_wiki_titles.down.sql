@@ -0,0 +1,3 @@
+ALTER TABLE Genres DROP COLUMN wiki_title;
+ALTER TABLE Platforms DROP COLUMN wiki_title;
+ALTER TABLE Developers DROP COLUMN wiki_title;
@@ -0,0 +1,8 @@
+-- The Wikipedia page each developer, platform and genre links to, when an infobox
+-- links it. Names linking to the same page are the same entity, stored once.
+ALTER TABLE Developers ADD COLUMN wiki_title VARCHAR(255);
+ALTER TABLE Developers ADD CONSTRAINT developers_wiki_title_key UNIQUE (wiki_title);
+ALTER TABLE Platforms ADD COLUMN wiki_title VARCHAR(255);
+ALTER TABLE Platforms ADD CONSTRAINT platforms_wiki_title_key UNIQUE (wiki_title);
+ALTER TABLE Genres ADD COLUMN wiki_title VARCHAR(255);
+ALTER TABLE Genres ADD CONSTRAINT genres_wiki_title_key UNIQUE (wiki_title);
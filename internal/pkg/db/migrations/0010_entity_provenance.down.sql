@@ -0,0 +1,20 @@
+ALTER TABLE GameGenres
+    DROP COLUMN revision_id,
+    DROP COLUMN source,
+    DROP COLUMN end_offset,
+    DROP COLUMN start_offset,
+    DROP COLUMN confidence;
+
+ALTER TABLE GamePlatforms
+    DROP COLUMN revision_id,
+    DROP COLUMN source,
+    DROP COLUMN end_offset,
+    DROP COLUMN start_offset,
+    DROP COLUMN confidence;
+
+ALTER TABLE GameDevelopers
+    DROP COLUMN revision_id,
+    DROP COLUMN source,
+    DROP COLUMN end_offset,
+    DROP COLUMN start_offset,
+    DROP COLUMN confidence;
@@ -0,0 +1,28 @@
+-- How each game-entity link was extracted: the extractor's confidence, the
+-- character offsets of the mention in the game's summary, the extractor that
+-- found it and the Wikipedia revision it was read from.
+ALTER TABLE GameDevelopers
+    ADD COLUMN confidence REAL,
+    ADD COLUMN start_offset INTEGER,
+    ADD COLUMN end_offset INTEGER,
+    ADD COLUMN source VARCHAR(255),
+    ADD COLUMN revision_id BIGINT;
+
+ALTER TABLE GamePlatforms
+    ADD COLUMN confidence REAL,
+    ADD COLUMN start_offset INTEGER,
+    ADD COLUMN end_offset INTEGER,
+    ADD COLUMN source VARCHAR(255),
+    ADD COLUMN revision_id BIGINT;
+
+ALTER TABLE GameGenres
+    ADD COLUMN confidence REAL,
+    ADD COLUMN start_offset INTEGER,
+    ADD COLUMN end_offset INTEGER,
+    ADD COLUMN source VARCHAR(255),
+    ADD COLUMN revision_id BIGINT;
+
+-- Find low-confidence links to review.
+CREATE INDEX gamedevelopers_confidence_idx ON GameDevelopers (confidence);
+CREATE INDEX gameplatforms_confidence_idx ON GamePlatforms (confidence);
+CREATE INDEX gamegenres_confidence_idx ON GameGenres (confidence);
@@ -0,0 +1,3 @@
+-- How each run's extracted labels were mapped to GameNet types and which entities
+-- were dropped, left unmapped or not stored.
+ALTER TABLE ingest_runs ADD COLUMN label_report JSONB NOT NULL DEFAULT '{}';
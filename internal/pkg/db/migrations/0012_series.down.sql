@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS GameSeries;
+DROP TABLE IF EXISTS Series;
@@ -0,0 +1,23 @@
+-- The franchises games belong to, from the series field of their infobox, linked
+-- like developers, platforms and genres.
+CREATE TABLE Series (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    wiki_title VARCHAR(255),
+    CONSTRAINT series_name_key UNIQUE (name),
+    CONSTRAINT series_wiki_title_key UNIQUE (wiki_title)
+);
+
+CREATE TABLE GameSeries (
+    game_id INTEGER NOT NULL REFERENCES Games(id) ON DELETE CASCADE,
+    series_id INTEGER NOT NULL REFERENCES Series(id) ON DELETE CASCADE,
+    confidence REAL,
+    start_offset INTEGER,
+    end_offset INTEGER,
+    source VARCHAR(255),
+    revision_id BIGINT,
+    PRIMARY KEY (game_id, series_id)
+);
+
+CREATE INDEX gameseries_series_id_idx ON GameSeries (series_id);
+CREATE INDEX gameseries_confidence_idx ON GameSeries (confidence);
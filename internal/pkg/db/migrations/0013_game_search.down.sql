@@ -0,0 +1,8 @@
+-- pg_trgm is left installed; other schemas in the database may use it.
+DROP INDEX IF EXISTS series_name_trgm_idx;
+DROP INDEX IF EXISTS genres_name_trgm_idx;
+DROP INDEX IF EXISTS platforms_name_trgm_idx;
+DROP INDEX IF EXISTS developers_name_trgm_idx;
+DROP INDEX IF EXISTS games_title_trgm_idx;
+DROP INDEX IF EXISTS games_search_idx;
+ALTER TABLE Games DROP COLUMN IF EXISTS search;
@@ -0,0 +1,16 @@
+-- Full-text search over games: a weighted document of the title (A) and the
+-- summary (B), kept current by PostgreSQL itself.
+ALTER TABLE Games ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(summary, '')), 'B')
+) STORED;
+CREATE INDEX games_search_idx ON Games USING GIN (search);
+
+-- Trigram indexes find misspelled titles and serve autocomplete over titles and
+-- entity names, including LIKE/ILIKE patterns.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX games_title_trgm_idx ON Games USING GIN (title gin_trgm_ops);
+CREATE INDEX developers_name_trgm_idx ON Developers USING GIN (name gin_trgm_ops);
+CREATE INDEX platforms_name_trgm_idx ON Platforms USING GIN (name gin_trgm_ops);
+CREATE INDEX genres_name_trgm_idx ON Genres USING GIN (name gin_trgm_ops);
+CREATE INDEX series_name_trgm_idx ON Series USING GIN (name gin_trgm_ops);
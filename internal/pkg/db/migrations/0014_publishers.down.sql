@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS GamePublishers;
+DROP TABLE IF EXISTS Publishers;
@@ -0,0 +1,24 @@
+-- The companies that published each game, from "published by" mentions and the
+-- publisher field of infoboxes, linked like developers.
+CREATE TABLE Publishers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    wiki_title VARCHAR(255),
+    CONSTRAINT publishers_name_key UNIQUE (name),
+    CONSTRAINT publishers_wiki_title_key UNIQUE (wiki_title)
+);
+
+CREATE TABLE GamePublishers (
+    game_id INTEGER NOT NULL REFERENCES Games(id) ON DELETE CASCADE,
+    publisher_id INTEGER NOT NULL REFERENCES Publishers(id) ON DELETE CASCADE,
+    confidence REAL,
+    start_offset INTEGER,
+    end_offset INTEGER,
+    source VARCHAR(255),
+    revision_id BIGINT,
+    PRIMARY KEY (game_id, publisher_id)
+);
+
+CREATE INDEX gamepublishers_publisher_id_idx ON GamePublishers (publisher_id);
+CREATE INDEX gamepublishers_confidence_idx ON GamePublishers (confidence);
+CREATE INDEX publishers_name_trgm_idx ON Publishers USING GIN (name gin_trgm_ops);
@@ -0,0 +1,3 @@
+DELETE FROM dead_letters WHERE stage = 'graph';
+ALTER TABLE dead_letters DROP CONSTRAINT dead_letters_stage_check;
+ALTER TABLE dead_letters ADD CONSTRAINT dead_letters_stage_check CHECK (stage IN ('extract', 'store'));
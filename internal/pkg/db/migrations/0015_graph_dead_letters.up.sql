@@ -0,0 +1,3 @@
+-- Games whose batch could not be written to Neo4j are kept as dead letters too.
+ALTER TABLE dead_letters DROP CONSTRAINT dead_letters_stage_check;
+ALTER TABLE dead_letters ADD CONSTRAINT dead_letters_stage_check CHECK (stage IN ('extract', 'store', 'graph'));
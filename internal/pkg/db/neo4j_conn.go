@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"log"
@@ -66,6 +67,24 @@ func StoreInNeo4j(session neo4j.Session, title, description string) error {
 	return nil
 }
 
+// PingNeo4j verifies that the Neo4j driver initialized by InitNeo4j can still reach the server.
+func PingNeo4j(ctx context.Context) error {
+	if Neo4jDriver == nil {
+		return fmt.Errorf("Neo4j is not initialized")
+	}
+
+	// The v4 driver has no context support, so give up waiting once ctx expires
+	result := make(chan error, 1)
+	go func() { result <- Neo4jDriver.VerifyConnectivity() }()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // CloseNeo4j closes the Neo4j driver connection when it's no longer needed
 func CloseNeo4j() error {
 	if Neo4jDriver != nil {
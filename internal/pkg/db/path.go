@@ -0,0 +1,436 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"strconv"
+	"strings"
+)
+
+// Default and maximum number of relationships on a path returned by ShortestPath.
+const (
+	DefaultPathHops = 6
+	MaxPathHops     = 10
+)
+
+// maxPathNodes bounds how many nodes the PostgreSQL search visits, since a popular
+// platform or genre links thousands of games.
+const maxPathNodes = 100000
+
+// ErrNoPath is returned when two nodes are not connected within the allowed hops.
+var ErrNoPath = errors.New("no connection path")
+
+// ErrInvalidPathOptions is returned when PathOptions name an unknown relationship type
+// or allow too many hops.
+var ErrInvalidPathOptions = errors.New("invalid path options")
+
+// PathNode is a game or entity on a connection path, identified like in the REST API
+// by its kind ("games", "developers", "publishers", "platforms", "genres" or "series") and ID.
+type PathNode struct {
+	Kind string `json:"kind"`
+	ID   int    `json:"id,omitempty"` // 0 when the node is in Neo4j but not in PostgreSQL
+	Name string `json:"name"`
+}
+
+// Ref returns the node's reference, e.g. "games/12".
+func (n PathNode) Ref() string {
+	return n.Kind + "/" + strconv.Itoa(n.ID)
+}
+
+// ParsePathNode parses a reference like "games/12" or "developers/3".
+func ParsePathNode(ref string) (PathNode, error) {
+	kind, id, ok := strings.Cut(ref, "/")
+	n, err := strconv.Atoi(id)
+	if !ok || err != nil || n <= 0 {
+		return PathNode{}, fmt.Errorf("invalid node %q, expected kind/id", ref)
+	}
+	if _, ok := pathRelationships[kind]; !ok && kind != "games" {
+		return PathNode{}, fmt.Errorf("invalid node %q, unknown kind %s", ref, kind)
+	}
+	return PathNode{Kind: kind, ID: n}, nil
+}
+
+// Path is a chain of games and entities, Relationships[i] linking Nodes[i] and Nodes[i+1].
+type Path struct {
+	Nodes         []PathNode `json:"nodes"`
+	Relationships []string   `json:"relationships"`
+}
+
+// PathOptions configures ShortestPath.
+type PathOptions struct {
+	Relationships []string // Relationship types a path may follow, e.g. DEVELOPED_BY; all of PathRelationships when empty
+	MaxHops       int      // Maximum number of relationships; DefaultPathHops when 0, at most MaxPathHops
+}
+
+// pathRelationships maps the entity kinds to the relationship types linking games to them.
+var pathRelationships = func() map[string]string {
+	relationships := make(map[string]string, len(graphKinds))
+	for _, k := range graphKinds {
+		relationships[k.kind] = GraphRelations[k.label].Relationship
+	}
+	return relationships
+}()
+
+// PathRelationships returns the relationship types paths can follow.
+func PathRelationships() []string {
+	types := make([]string, 0, len(graphKinds))
+	for _, k := range graphKinds {
+		types = append(types, pathRelationships[k.kind])
+	}
+	return types
+}
+
+// pathKinds returns the entity kinds whose relationship types opts allows, in order.
+func (o PathOptions) pathKinds() []string {
+	var kinds []string
+	for _, k := range graphKinds {
+		relationship := pathRelationships[k.kind]
+		if len(o.Relationships) == 0 {
+			kinds = append(kinds, k.kind)
+			continue
+		}
+		for _, allowed := range o.Relationships {
+			if allowed == relationship {
+				kinds = append(kinds, k.kind)
+				break
+			}
+		}
+	}
+	return kinds
+}
+
+// normalize fills in the defaults of o and checks its relationship types and hops.
+func (o PathOptions) normalize() (PathOptions, error) {
+	known := PathRelationships()
+	for _, relationship := range o.Relationships {
+		if !containsString(known, relationship) {
+			return o, fmt.Errorf("%w: unknown relationship type %q (expected one of %s)", ErrInvalidPathOptions, relationship, strings.Join(known, ", "))
+		}
+	}
+	if len(o.Relationships) == 0 {
+		o.Relationships = known
+	}
+	if o.MaxHops == 0 {
+		o.MaxHops = DefaultPathHops
+	}
+	if o.MaxHops < 0 || o.MaxHops > MaxPathHops {
+		return o, fmt.Errorf("%w: max hops must be between 1 and %d", ErrInvalidPathOptions, MaxPathHops)
+	}
+	return o, nil
+}
+
+// ShortestPath returns a shortest path between two games or entities, found by a
+// breadth-first search over the PostgreSQL join tables that loads each level of the
+// search in one query per entity kind. It returns ErrNotFound when a node does not
+// exist and ErrNoPath when they are not connected within opts.MaxHops.
+func (c *Catalog) ShortestPath(ctx context.Context, from, to PathNode, opts PathOptions) (Path, error) {
+	opts, err := opts.normalize()
+	if err != nil {
+		return Path{}, err
+	}
+	if from, _, err = c.resolvePathNode(ctx, from); err != nil {
+		return Path{}, err
+	}
+	if to, _, err = c.resolvePathNode(ctx, to); err != nil {
+		return Path{}, err
+	}
+
+	type step struct {
+		prev         PathNode
+		relationship string
+	}
+	key := func(n PathNode) PathNode { return PathNode{Kind: n.Kind, ID: n.ID} }
+	steps := map[PathNode]step{key(from): {}}
+	names := map[PathNode]string{key(from): from.Name, key(to): to.Name}
+	frontier := []PathNode{key(from)}
+	found := key(from) == key(to)
+
+	kinds := opts.pathKinds()
+	for hop := 0; hop < opts.MaxHops && !found && len(frontier) > 0; hop++ {
+		var next []PathNode
+		visit := func(node, prev PathNode, relationship string) {
+			if _, ok := steps[node]; ok || found {
+				return
+			}
+			steps[node] = step{prev: prev, relationship: relationship}
+			next = append(next, node)
+			found = node == key(to)
+		}
+
+		// Games lead to their entities and entities to their games
+		var games []int
+		entities := make(map[string][]int)
+		for _, node := range frontier {
+			if node.Kind == "games" {
+				games = append(games, node.ID)
+			} else {
+				entities[node.Kind] = append(entities[node.Kind], node.ID)
+			}
+		}
+		for _, kind := range kinds {
+			if found {
+				break
+			}
+			relationship := pathRelationships[kind]
+			if len(games) > 0 {
+				links, err := c.EntitiesOfGames(ctx, kind, games)
+				if err != nil {
+					return Path{}, err
+				}
+				for _, game := range games {
+					for _, link := range links[game] {
+						node := PathNode{Kind: kind, ID: link.ID}
+						names[node] = link.Name
+						visit(node, PathNode{Kind: "games", ID: game}, relationship)
+					}
+				}
+			}
+			if ids := entities[kind]; len(ids) > 0 {
+				gameIDs, err := c.GamesOfEntities(ctx, kind, ids)
+				if err != nil {
+					return Path{}, err
+				}
+				for _, id := range ids {
+					for _, game := range gameIDs[id] {
+						visit(PathNode{Kind: "games", ID: game}, PathNode{Kind: kind, ID: id}, relationship)
+					}
+				}
+			}
+		}
+
+		if len(steps) > maxPathNodes {
+			return Path{}, fmt.Errorf("%w within %d hops: gave up after visiting %d nodes", ErrNoPath, hop+1, len(steps))
+		}
+		frontier = next
+	}
+	if !found {
+		return Path{}, fmt.Errorf("%w within %d hops", ErrNoPath, opts.MaxHops)
+	}
+
+	// Walk back from the target
+	var path Path
+	for node := key(to); ; {
+		path.Nodes = append(path.Nodes, node)
+		s := steps[node]
+		if s.relationship == "" {
+			break
+		}
+		path.Relationships = append(path.Relationships, s.relationship)
+		node = s.prev
+	}
+	reversePath(&path)
+
+	var gameIDs []int
+	for _, node := range path.Nodes {
+		if node.Kind == "games" {
+			gameIDs = append(gameIDs, node.ID)
+		}
+	}
+	games, err := c.gameSummaries(ctx, "id", gameIDs)
+	if err != nil {
+		return Path{}, err
+	}
+	for i, node := range path.Nodes {
+		if node.Kind == "games" {
+			path.Nodes[i].Name = games[node.ID].Title
+		} else {
+			path.Nodes[i].Name = names[node]
+		}
+	}
+	return path, nil
+}
+
+// resolvePathNode checks that a node exists and fills in its name. For games it also
+// returns the page ID, which identifies them in Neo4j.
+func (c *Catalog) resolvePathNode(ctx context.Context, node PathNode) (PathNode, int, error) {
+	if node.Kind != "games" {
+		entity, err := c.Entity(ctx, node.Kind, node.ID)
+		if err != nil {
+			return node, 0, err
+		}
+		node.Name = entity.Name
+		return node, 0, nil
+	}
+
+	games, err := c.gameSummaries(ctx, "id", []int{node.ID})
+	if err != nil {
+		return node, 0, err
+	}
+	game, ok := games[node.ID]
+	if !ok {
+		return node, 0, fmt.Errorf("game %d: %w", node.ID, ErrNotFound)
+	}
+	node.Name = game.Title
+	return node, game.PageID, nil
+}
+
+// entityIDsByName returns the IDs of the entities of one kind with the given names.
+func (c *Catalog) entityIDsByName(ctx context.Context, kind string, names []string) (map[string]int, error) {
+	t := catalogTables[kind]
+	rows, err := c.db.QueryContext(ctx, `SELECT id, name FROM `+t.table+` WHERE name = ANY($1)`, pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", kind, err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int, len(names))
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		ids[name] = id
+	}
+	return ids, rows.Err()
+}
+
+// GraphPathFinder finds shortest paths with Cypher over the Neo4j graph. Nodes are
+// given and returned with their PostgreSQL IDs, which Catalog translates to the page
+// IDs and names that identify them in the graph.
+type GraphPathFinder struct {
+	Driver  neo4j.Driver
+	Catalog *Catalog
+}
+
+// NewGraphPathFinder returns a path finder querying driver and resolving nodes with catalog.
+func NewGraphPathFinder(driver neo4j.Driver, catalog *Catalog) *GraphPathFinder {
+	return &GraphPathFinder{Driver: driver, Catalog: catalog}
+}
+
+// ShortestPath returns a shortest path between two games or entities found by Neo4j's
+// shortestPath, with the errors of Catalog.ShortestPath. Games without a page ID are
+// not in the graph, so paths from or to them are searched in PostgreSQL instead.
+func (f *GraphPathFinder) ShortestPath(ctx context.Context, from, to PathNode, opts PathOptions) (Path, error) {
+	opts, err := opts.normalize()
+	if err != nil {
+		return Path{}, err
+	}
+	from, fromPageID, err := f.Catalog.resolvePathNode(ctx, from)
+	if err != nil {
+		return Path{}, err
+	}
+	to, toPageID, err := f.Catalog.resolvePathNode(ctx, to)
+	if err != nil {
+		return Path{}, err
+	}
+	if (from.Kind == "games" && fromPageID == 0) || (to.Kind == "games" && toPageID == 0) {
+		return f.Catalog.ShortestPath(ctx, from, to, opts)
+	}
+	if from.Kind == to.Kind && from.ID == to.ID {
+		return Path{Nodes: []PathNode{from}}, nil
+	}
+
+	fromPattern, fromValue := graphNodePattern(from, fromPageID, "from")
+	toPattern, toValue := graphNodePattern(to, toPageID, "to")
+	// Relationship types and hop counts cannot be parameters; both were validated
+	query := `MATCH (a` + fromPattern + `), (b` + toPattern + `)
+		MATCH p = shortestPath((a)-[:` + strings.Join(opts.Relationships, "|") + `*..` + strconv.Itoa(opts.MaxHops) + `]-(b))
+		RETURN [n IN nodes(p) | [labels(n)[0], coalesce(n.page_id, 0), coalesce(n.title, n.name)]], [r IN relationships(p) | type(r)]`
+
+	session := f.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	result, err := session.Run(query, map[string]interface{}{"from": fromValue, "to": toValue})
+	if err != nil {
+		return Path{}, fmt.Errorf("could not query path in Neo4j: %v", err)
+	}
+	if !result.Next() {
+		if err := result.Err(); err != nil {
+			return Path{}, fmt.Errorf("could not query path in Neo4j: %v", err)
+		}
+		return Path{}, fmt.Errorf("%w within %d hops", ErrNoPath, opts.MaxHops)
+	}
+	values := result.Record().Values
+
+	kinds := make(map[string]string, len(graphKinds)) // Entity kinds by node label
+	for _, k := range graphKinds {
+		kinds[GraphRelations[k.label].Node] = k.kind
+	}
+
+	var path Path
+	var pageIDs []int
+	names := make(map[string][]string)
+	nodes, _ := values[0].([]interface{})
+	for _, value := range nodes {
+		fields, _ := value.([]interface{})
+		if len(fields) != 3 {
+			continue
+		}
+		label, _ := fields[0].(string)
+		pageID, _ := fields[1].(int64)
+		name, _ := fields[2].(string)
+
+		node := PathNode{Kind: kinds[label], Name: name}
+		if label == "Game" {
+			node.Kind, node.ID = "games", int(pageID) // Page ID until translated below
+			pageIDs = append(pageIDs, int(pageID))
+		} else {
+			names[node.Kind] = append(names[node.Kind], name)
+		}
+		path.Nodes = append(path.Nodes, node)
+	}
+	relationships, _ := values[1].([]interface{})
+	for _, relationship := range relationships {
+		name, _ := relationship.(string)
+		path.Relationships = append(path.Relationships, name)
+	}
+
+	// Translate page IDs and names to PostgreSQL IDs; nodes the graph has but
+	// PostgreSQL no longer does keep ID 0
+	games, err := f.Catalog.gameSummaries(ctx, "page_id", pageIDs)
+	if err != nil {
+		return Path{}, err
+	}
+	entityIDs := make(map[string]map[string]int, len(names))
+	for kind, kindNames := range names {
+		if entityIDs[kind], err = f.Catalog.entityIDsByName(ctx, kind, kindNames); err != nil {
+			return Path{}, err
+		}
+	}
+	for i, node := range path.Nodes {
+		if node.Kind == "games" {
+			path.Nodes[i].ID = games[node.ID].ID
+		} else {
+			path.Nodes[i].ID = entityIDs[node.Kind][node.Name]
+		}
+	}
+	return path, nil
+}
+
+// graphNodePattern returns the Cypher pattern matching a node, e.g.
+// ":Game {page_id: $from}", and the value of its parameter.
+func graphNodePattern(node PathNode, pageID int, param string) (string, interface{}) {
+	if node.Kind == "games" {
+		return ":Game {page_id: $" + param + "}", int64(pageID)
+	}
+	for _, k := range graphKinds {
+		if k.kind == node.Kind {
+			return ":" + GraphRelations[k.label].Node + " {name: $" + param + "}", node.Name
+		}
+	}
+	return "", nil
+}
+
+// reversePath reverses the nodes and relationships of a path in place.
+func reversePath(path *Path) {
+	for i, j := 0, len(path.Nodes)-1; i < j; i, j = i+1, j-1 {
+		path.Nodes[i], path.Nodes[j] = path.Nodes[j], path.Nodes[i]
+	}
+	for i, j := 0, len(path.Relationships)-1; i < j; i, j = i+1, j-1 {
+		path.Relationships[i], path.Relationships[j] = path.Relationships[j], path.Relationships[i]
+	}
+}
+
+// containsString reports whether values contains value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -17,6 +17,9 @@ const (
 	dbname   = "gamenet"
 )
 
+// DB is the PostgreSQL handle returned by InitPostgres.
+type DB = sql.DB
+
 // InitPostgres initializes a connection to the PostgreSQL database and configures connection pooling.
 // It returns the *sql.DB object representing the connection and an error if any.
 func InitPostgres() (*sql.DB, error) {
@@ -48,11 +51,11 @@ func InitPostgres() (*sql.DB, error) {
 	return db, nil
 }
 
-// StoreInPostgres inserts a game into the 'games' table in the PostgreSQL database.
-// The function takes a connection, the title, and description of the game.
+// StoreInPostgres inserts a game into the Games table in the PostgreSQL database.
+// The function takes a connection, the title, and description (summary) of the game.
 func StoreInPostgres(db *sql.DB, title, description string) error {
-	// SQL query to insert the game into the 'games' table
-	query := `INSERT INTO games (title, description) VALUES ($1, $2)`
+	// SQL query to insert the game into the Games table
+	query := `INSERT INTO Games (title, summary) VALUES ($1, $2)`
 
 	// Execute the insert query, passing the title and description as parameters
 	_, err := db.Exec(query, title, description)
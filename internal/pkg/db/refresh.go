@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gamenet/internal/pkg/wiki"
+	"github.com/lib/pq"
+	"sort"
+)
+
+// TrackedGame is a stored game that came from a Wikipedia page and can be refreshed.
+type TrackedGame struct {
+	ID         int
+	PageID     int
+	Title      string
+	RevisionID int64 // Revision the game was last extracted from; 0 when unknown
+}
+
+// RefreshPlan sorts tracked games by what happened to their page since they were extracted.
+type RefreshPlan struct {
+	Unchanged []TrackedGame
+	Changed   []TrackedGame // Edited (or of unknown revision); must be extracted again
+	Moved     []TrackedGame // Renamed; a subset of Changed, with Title set to the new title
+	Deleted   []TrackedGame // Deleted or turned into a redirect; must be removed
+	Unknown   []TrackedGame // Not reported by the API at all; left alone
+}
+
+// A refresh deleting more than MaxRefreshDeletedShare of the tracked games, and more
+// than MinRefreshDeletedGames, more likely talks to the wrong API than sees real deletions.
+const (
+	MaxRefreshDeletedShare = 0.05
+	MinRefreshDeletedGames = 10
+)
+
+// ErrTooManyDeletions is returned by RefreshPlan.CheckDeletions.
+var ErrTooManyDeletions = errors.New("refresh would delete too many games")
+
+// CheckDeletions returns ErrTooManyDeletions when the plan deletes more games than a
+// refresh of tracked games plausibly should.
+func (p RefreshPlan) CheckDeletions(tracked int) error {
+	deleted := len(p.Deleted)
+	if deleted > MinRefreshDeletedGames && float64(deleted) > MaxRefreshDeletedShare*float64(tracked) {
+		return fmt.Errorf("%w: %d of %d", ErrTooManyDeletions, deleted, tracked)
+	}
+	return nil
+}
+
+// TrackedGames returns every game with a Wikipedia page ID, ordered by page ID.
+func TrackedGames(ctx context.Context, db *sql.DB) ([]TrackedGame, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, page_id, title, COALESCE(revision_id, 0) FROM Games WHERE page_id IS NOT NULL ORDER BY page_id`)
+	if err != nil {
+		return nil, fmt.Errorf("could not read tracked games: %v", err)
+	}
+	defer rows.Close()
+
+	var games []TrackedGame
+	for rows.Next() {
+		var game TrackedGame
+		if err := rows.Scan(&game.ID, &game.PageID, &game.Title, &game.RevisionID); err != nil {
+			return nil, err
+		}
+		games = append(games, game)
+	}
+	return games, rows.Err()
+}
+
+// PlanRefresh compares tracked games with the current state of their pages, as
+// returned by wiki.Client.FetchPageInfo. Only pages the API reports as missing or
+// redirected are deleted; a page missing from info, e.g. from a truncated response,
+// is left alone.
+func PlanRefresh(games []TrackedGame, info map[int]wiki.Page) RefreshPlan {
+	var plan RefreshPlan
+	for _, game := range games {
+		page, ok := info[game.PageID]
+		switch {
+		case !ok:
+			plan.Unknown = append(plan.Unknown, game)
+		case page.Missing || page.Redirect:
+			plan.Deleted = append(plan.Deleted, game)
+		case page.Title != "" && page.Title != game.Title:
+			game.Title = page.Title
+			plan.Moved = append(plan.Moved, game)
+			plan.Changed = append(plan.Changed, game)
+		case game.RevisionID == 0 || page.LastRevID != game.RevisionID:
+			plan.Changed = append(plan.Changed, game)
+		default:
+			plan.Unchanged = append(plan.Unchanged, game)
+		}
+	}
+	return plan
+}
+
+// PageIDs returns the page IDs of the given games in ascending order.
+func PageIDs(games []TrackedGame) []int {
+	ids := make([]int, len(games))
+	for i, game := range games {
+		ids[i] = game.PageID
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// DeleteGames removes the games with the given page IDs together with their
+// entity links and returns how many were deleted.
+func DeleteGames(ctx context.Context, db *sql.DB, pageIDs []int) (int64, error) {
+	if len(pageIDs) == 0 {
+		return 0, nil
+	}
+	ids := make([]int64, len(pageIDs))
+	for i, id := range pageIDs {
+		ids[i] = int64(id)
+	}
+
+	// The join tables cascade, so only the games themselves are deleted
+	result, err := db.ExecContext(ctx, `DELETE FROM Games WHERE page_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("could not delete games: %v", err)
+	}
+	return result.RowsAffected()
+}
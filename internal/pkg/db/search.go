@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Default and maximum number of results returned by Search and Autocomplete.
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// searchHeadline configures the snippets of Search: up to two fragments of the
+// summary, with the matched words wrapped in <mark>.
+const searchHeadline = `StartSel=<mark>, StopSel=</mark>, MinWords=15, MaxWords=35, MaxFragments=2, FragmentDelimiter=" … "`
+
+// escapedSummary is the game's summary with the characters special to HTML escaped,
+// so the only markup in a snippet is the <mark> around the matched words.
+const escapedSummary = `replace(replace(replace(COALESCE(g.summary, ''), '&', '&amp;'), '<', '&lt;'), '>', '&gt;')`
+
+// SearchResult is a game matching a search, with its relevance and a snippet of its
+// summary.
+type SearchResult struct {
+	GameSummary
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet,omitempty"` // HTML excerpt of the summary, escaped, with the matched words in <mark>
+}
+
+// Suggestion is a game title or entity name completing a prefix.
+type Suggestion struct {
+	Kind string `json:"kind"` // "games" or an entity kind, e.g. "developers"
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// clampSearchLimit applies the default and maximum to a result limit.
+func clampSearchLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultSearchLimit
+	}
+	if limit > MaxSearchLimit {
+		return MaxSearchLimit
+	}
+	return limit
+}
+
+// Search returns the games best matching a query, most relevant first, skipping the
+// first offset. Words are matched against the title and summary with PostgreSQL
+// full-text search (websearch syntax: "quoted phrases", -excluded, or), title matches
+// ranking above summary matches; titles are also matched by trigram similarity, so
+// misspelled titles are found too. Snippets are HTML.
+func (c *Catalog) Search(ctx context.Context, query string, limit, offset int) ([]SearchResult, error) {
+	rows, err := c.db.QueryContext(ctx, `WITH q AS (SELECT websearch_to_tsquery('english', $1) AS query)
+		SELECT g.id, COALESCE(g.page_id, 0), g.title, COALESCE(g.release_date, ''), COALESCE(`+releaseYear+`, ''),
+			ts_rank(g.search, q.query) + greatest(similarity(g.title, $1), word_similarity($1, g.title)) AS rank,
+			ts_headline('english', `+escapedSummary+`, q.query, '`+searchHeadline+`')
+		FROM Games g, q
+		WHERE g.search @@ q.query OR g.title % $1 OR $1 <% g.title
+		ORDER BY rank DESC, g.id
+		LIMIT $2 OFFSET $3`, query, clampSearchLimit(limit), offset)
+	if err != nil {
+		return nil, fmt.Errorf("could not search games: %v", err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		var year string
+		err := rows.Scan(&result.ID, &result.PageID, &result.Title, &result.ReleaseDate, &year, &result.Rank, &result.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		result.ReleaseYear, _ = strconv.Atoi(year)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// Autocomplete returns game titles and entity names completing prefix: names starting
+// with it first, then names with a word starting with it, then names merely similar
+// to it, which catches typos. Within each group the most similar and shortest names
+// come first.
+func (c *Catalog) Autocomplete(ctx context.Context, prefix string, limit int) ([]Suggestion, error) {
+	sources := []string{`SELECT 'games' AS kind, id, title AS name FROM Games`}
+	for _, k := range graphKinds {
+		sources = append(sources, `SELECT '`+k.kind+`', id, name FROM `+catalogTables[k.kind].table)
+	}
+
+	rows, err := c.db.QueryContext(ctx, `SELECT kind, id, name FROM (`+strings.Join(sources, " UNION ALL ")+`) names
+		WHERE name ILIKE $1::text || '%' OR name ILIKE '% ' || $1::text || '%' OR $2 <% name
+		ORDER BY CASE WHEN name ILIKE $1::text || '%' THEN 0 WHEN name ILIKE '% ' || $1::text || '%' THEN 1 ELSE 2 END,
+			word_similarity($2, name) DESC, length(name), name
+		LIMIT $3`, escapeLike(prefix), prefix, clampSearchLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("could not complete %q: %v", prefix, err)
+	}
+	defer rows.Close()
+
+	suggestions := []Suggestion{}
+	for rows.Next() {
+		var suggestion Suggestion
+		if err := rows.Scan(&suggestion.Kind, &suggestion.ID, &suggestion.Name); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, rows.Err()
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
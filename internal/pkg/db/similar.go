@@ -0,0 +1,419 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"math"
+	"sort"
+)
+
+// Metrics scoring the overlap of two games' entities of one kind.
+const (
+	MetricJaccard    = "jaccard"     // Shared entities over the entities of either game
+	MetricAdamicAdar = "adamic-adar" // Shared entities weighed by 1/ln(games linked to them), so rare ones count more
+)
+
+// Default and maximum number of games returned by SimilarGames.
+const (
+	DefaultSimilarLimit = 10
+	MaxSimilarLimit     = 100
+)
+
+// EraSpan is how many years apart two games' releases can be before their era no
+// longer adds to their similarity.
+const EraSpan = 10
+
+// similarCandidateFactor is how many candidates per requested game the graph ranks by
+// overlap before the release era, which only PostgreSQL knows, reorders them.
+const similarCandidateFactor = 5
+
+// graphKinds are the entity kinds stored in both PostgreSQL and Neo4j, with the
+// labels of their graph nodes. Similarity and paths are computed over them.
+var graphKinds = []struct {
+	kind  string
+	label string
+}{
+	{"developers", "Developer"},
+	{"publishers", "Publisher"},
+	{"platforms", "Platform"},
+	{"genres", "Genre"},
+	{"series", "Series"},
+}
+
+// SimilarityWeights weigh the overlap score of each entity kind ("developers",
+// "publishers", "platforms", "genres", "series") and the era score ("era") in a game's similarity.
+// Kinds without a weight are ignored.
+type SimilarityWeights map[string]float64
+
+// DefaultSimilarityWeights favour games of the same series or developer over games
+// that merely share a genre or a platform, which many do.
+var DefaultSimilarityWeights = SimilarityWeights{
+	"series":     3,
+	"developers": 2,
+	"genres":     1.5,
+	"publishers": 1,
+	"platforms":  1,
+	"era":        0.5,
+}
+
+// SimilarOptions configures SimilarGames.
+type SimilarOptions struct {
+	Metric  string            // MetricJaccard (the default) or MetricAdamicAdar
+	Weights SimilarityWeights // DefaultSimilarityWeights when nil
+	Limit   int               // Maximum number of games; DefaultSimilarLimit when 0, at most MaxSimilarLimit
+}
+
+// normalize fills in the defaults of o and checks its metric.
+func (o SimilarOptions) normalize() (SimilarOptions, error) {
+	switch o.Metric {
+	case "":
+		o.Metric = MetricJaccard
+	case MetricJaccard, MetricAdamicAdar:
+	default:
+		return o, fmt.Errorf("unknown similarity metric %q", o.Metric)
+	}
+	if o.Weights == nil {
+		o.Weights = DefaultSimilarityWeights
+	}
+	if o.Limit <= 0 {
+		o.Limit = DefaultSimilarLimit
+	}
+	if o.Limit > MaxSimilarLimit {
+		o.Limit = MaxSimilarLimit
+	}
+	return o, nil
+}
+
+// SimilarGame is a game recommended as similar to another and why.
+type SimilarGame struct {
+	GameSummary
+	Score  float64             `json:"score"`
+	Shared map[string][]string `json:"shared"` // Names of the entities both games have, by kind
+}
+
+// SimilarGames is a game and the games most similar to it, best first.
+type SimilarGames struct {
+	Game    GameSummary   `json:"game"`
+	Metric  string        `json:"metric"`
+	Similar []SimilarGame `json:"similar"`
+}
+
+// OverlapScore scores how much two games overlap in one entity kind, given the number
+// of entities of that kind each has and the degree (number of linked games) of every
+// entity they share. MetricJaccard is the number of shared entities over the number
+// of entities of either game; MetricAdamicAdar sums 1/ln(degree) over the shared
+// entities, so a studio with two games says more than a platform with thousands.
+func OverlapScore(metric string, a, b int, sharedDegrees []int) float64 {
+	if len(sharedDegrees) == 0 {
+		return 0
+	}
+	if metric == MetricAdamicAdar {
+		score := 0.0
+		for _, degree := range sharedDegrees {
+			// A shared entity links at least the two games themselves
+			score += 1 / math.Log(math.Max(float64(degree), 2))
+		}
+		return score
+	}
+	union := a + b - len(sharedDegrees)
+	if union <= 0 {
+		return 0
+	}
+	return float64(len(sharedDegrees)) / float64(union)
+}
+
+// EraScore is 1 for games first released the same year, falling linearly to 0 for
+// games EraSpan years apart. It is 0 when either year is unknown.
+func EraScore(a, b int) float64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	gap := math.Abs(float64(a - b))
+	return math.Max(0, 1-gap/EraSpan)
+}
+
+// rankSimilar adds the era score to the overlap scores of the candidates and returns
+// the best opts.Limit of them, ties broken by ID.
+func rankSimilar(target GameSummary, candidates []SimilarGame, opts SimilarOptions) []SimilarGame {
+	for i := range candidates {
+		candidates[i].Score += opts.Weights["era"] * EraScore(target.ReleaseYear, candidates[i].ReleaseYear)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	if len(candidates) > opts.Limit {
+		candidates = candidates[:opts.Limit]
+	}
+	return candidates
+}
+
+// similarCandidate collects what a candidate game shares with the target game.
+type similarCandidate struct {
+	shared  map[string][]string // Shared entity names by kind
+	degrees map[string][]int    // Degrees of the shared entities by kind
+	sizes   map[string]int      // Number of entities of the candidate by kind
+}
+
+// SimilarGames returns the games most similar to the game with the given ID, or
+// ErrNotFound. Every game sharing a developer, publisher, platform, genre or series
+// with it is scored in Go: the weighted OverlapScore of each kind plus the weighted
+// EraScore.
+// Games sharing no entity are not recommended, whatever their release era.
+func (c *Catalog) SimilarGames(ctx context.Context, id int, opts SimilarOptions) (SimilarGames, error) {
+	opts, err := opts.normalize()
+	if err != nil {
+		return SimilarGames{}, err
+	}
+	targets, err := c.gameSummaries(ctx, "id", []int{id})
+	if err != nil {
+		return SimilarGames{}, err
+	}
+	target, ok := targets[id]
+	if !ok {
+		return SimilarGames{}, fmt.Errorf("game %d: %w", id, ErrNotFound)
+	}
+
+	candidates := make(map[int]*similarCandidate)
+	targetSizes := make(map[string]int)
+	for _, k := range graphKinds {
+		if opts.Weights[k.kind] == 0 {
+			continue
+		}
+		if err := c.collectSimilar(ctx, k.kind, id, candidates, targetSizes); err != nil {
+			return SimilarGames{}, err
+		}
+	}
+
+	ids := make([]int, 0, len(candidates))
+	for candidateID := range candidates {
+		ids = append(ids, candidateID)
+	}
+	summaries, err := c.gameSummaries(ctx, "id", ids)
+	if err != nil {
+		return SimilarGames{}, err
+	}
+
+	similar := make([]SimilarGame, 0, len(candidates))
+	for candidateID, candidate := range candidates {
+		game := SimilarGame{GameSummary: summaries[candidateID], Shared: candidate.shared}
+		for kind, degrees := range candidate.degrees {
+			game.Score += opts.Weights[kind] * OverlapScore(opts.Metric, targetSizes[kind], candidate.sizes[kind], degrees)
+		}
+		similar = append(similar, game)
+	}
+	return SimilarGames{Game: target, Metric: opts.Metric, Similar: rankSimilar(target, similar, opts)}, nil
+}
+
+// collectSimilar adds the games sharing entities of one kind with the game to
+// candidates, and the game's number of entities of that kind to targetSizes.
+func (c *Catalog) collectSimilar(ctx context.Context, kind string, gameID int, candidates map[int]*similarCandidate, targetSizes map[string]int) error {
+	t := catalogTables[kind]
+	var size int
+	err := c.db.QueryRowContext(ctx, `SELECT count(*) FROM `+t.joinTable+` WHERE game_id = $1`, gameID).Scan(&size)
+	if err != nil {
+		return fmt.Errorf("could not count %s of game %d: %v", kind, gameID, err)
+	}
+	targetSizes[kind] = size
+	if size == 0 {
+		return nil
+	}
+
+	// The degree of each of the game's entities and the size of each candidate are
+	// counted once, not once per shared pair
+	rows, err := c.db.QueryContext(ctx, `WITH shared AS (
+			SELECT l.game_id, l.`+t.joinColumn+` AS entity_id
+			FROM `+t.joinTable+` target
+			JOIN `+t.joinTable+` l ON l.`+t.joinColumn+` = target.`+t.joinColumn+` AND l.game_id <> target.game_id
+			WHERE target.game_id = $1
+		), degrees AS (
+			SELECT d.`+t.joinColumn+` AS entity_id, count(*) AS degree
+			FROM `+t.joinTable+` d
+			WHERE d.`+t.joinColumn+` IN (SELECT entity_id FROM shared)
+			GROUP BY d.`+t.joinColumn+`
+		), sizes AS (
+			SELECT s.game_id, count(*) AS size
+			FROM `+t.joinTable+` s
+			WHERE s.game_id IN (SELECT game_id FROM shared)
+			GROUP BY s.game_id
+		)
+		SELECT shared.game_id, e.name, degrees.degree, sizes.size
+		FROM shared
+		JOIN degrees ON degrees.entity_id = shared.entity_id
+		JOIN sizes ON sizes.game_id = shared.game_id
+		JOIN `+t.table+` e ON e.id = shared.entity_id
+		ORDER BY shared.game_id, e.name`, gameID)
+	if err != nil {
+		return fmt.Errorf("could not find games sharing %s with game %d: %v", kind, gameID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var candidateID, degree, candidateSize int
+		var name string
+		if err := rows.Scan(&candidateID, &name, &degree, &candidateSize); err != nil {
+			return err
+		}
+		candidate, ok := candidates[candidateID]
+		if !ok {
+			candidate = &similarCandidate{shared: make(map[string][]string), degrees: make(map[string][]int), sizes: make(map[string]int)}
+			candidates[candidateID] = candidate
+		}
+		candidate.shared[kind] = append(candidate.shared[kind], name)
+		candidate.degrees[kind] = append(candidate.degrees[kind], degree)
+		candidate.sizes[kind] = candidateSize
+	}
+	return rows.Err()
+}
+
+// gameSummaries returns the games whose column ("id" or "page_id") is one of values,
+// keyed on that column.
+func (c *Catalog) gameSummaries(ctx context.Context, column string, values []int) (map[int]GameSummary, error) {
+	games := make(map[int]GameSummary, len(values))
+	if len(values) == 0 {
+		return games, nil
+	}
+	rows, err := c.db.QueryContext(ctx, `SELECT g.id, COALESCE(g.page_id, 0), g.title, COALESCE(g.release_date, ''), COALESCE(`+releaseYear+`, '')
+		FROM Games g WHERE g.`+column+` = ANY($1)`, pq.Array(values))
+	if err != nil {
+		return nil, fmt.Errorf("could not read games: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		game, err := scanGameSummary(rows)
+		if err != nil {
+			return nil, err
+		}
+		if column == "page_id" {
+			games[game.PageID] = game
+		} else {
+			games[game.ID] = game
+		}
+	}
+	return games, rows.Err()
+}
+
+// similarQuery scores, in Cypher, the games sharing entities with the game of a page
+// ID through the relationship types in $types: per type the Jaccard or Adamic-Adar
+// index of the two games' entities, weighed by $weights and summed.
+const similarQuery = `MATCH (g:Game {page_id: $page_id})-[r]->(e)<-[s]-(other:Game)
+	WHERE type(r) IN $types AND type(s) = type(r) AND other <> g
+	WITH g, other, type(r) AS rel, e
+	WITH g, other, rel, e, size([(e)<-[x]-(:Game) WHERE type(x) = rel | x]) AS degree
+	WITH g, other, rel, collect(e.name) AS shared, count(e) AS overlap, sum(1.0 / log(degree)) AS adamicAdar
+	WITH other, rel, shared, CASE $metric
+		WHEN 'adamic-adar' THEN adamicAdar
+		ELSE toFloat(overlap) / (size([(g)-[x]->() WHERE type(x) = rel | x]) + size([(other)-[x]->() WHERE type(x) = rel | x]) - overlap)
+	END AS score
+	WITH other, sum($weights[rel] * score) AS score, collect({rel: rel, names: shared}) AS shared
+	RETURN other.page_id, score, shared
+	ORDER BY score DESC, other.page_id
+	LIMIT $limit`
+
+// GraphRecommender computes similar games with Cypher over the Neo4j graph. Games are
+// matched to Game nodes by page ID; their release years, which the graph does not
+// hold, are read from PostgreSQL.
+type GraphRecommender struct {
+	Driver  neo4j.Driver
+	Catalog *Catalog
+}
+
+// NewGraphRecommender returns a recommender querying driver and reading games from catalog.
+func NewGraphRecommender(driver neo4j.Driver, catalog *Catalog) *GraphRecommender {
+	return &GraphRecommender{Driver: driver, Catalog: catalog}
+}
+
+// SimilarGames returns the games most similar to the game with the given ID, or
+// ErrNotFound, scored like Catalog.SimilarGames but with the overlap computed by
+// Neo4j. Games without a page ID are not in the graph and fall back to the Catalog.
+func (r *GraphRecommender) SimilarGames(ctx context.Context, id int, opts SimilarOptions) (SimilarGames, error) {
+	opts, err := opts.normalize()
+	if err != nil {
+		return SimilarGames{}, err
+	}
+	targets, err := r.Catalog.gameSummaries(ctx, "id", []int{id})
+	if err != nil {
+		return SimilarGames{}, err
+	}
+	target, ok := targets[id]
+	if !ok {
+		return SimilarGames{}, fmt.Errorf("game %d: %w", id, ErrNotFound)
+	}
+	if target.PageID == 0 {
+		return r.Catalog.SimilarGames(ctx, id, opts)
+	}
+
+	var types []string
+	weights := make(map[string]interface{})
+	kinds := make(map[string]string) // Entity kinds by relationship type
+	for _, k := range graphKinds {
+		if opts.Weights[k.kind] == 0 {
+			continue
+		}
+		relationship := GraphRelations[k.label].Relationship
+		types = append(types, relationship)
+		weights[relationship] = opts.Weights[k.kind]
+		kinds[relationship] = k.kind
+	}
+
+	session := r.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	result, err := session.Run(similarQuery, map[string]interface{}{
+		"page_id": int64(target.PageID),
+		"types":   types,
+		"weights": weights,
+		"metric":  opts.Metric,
+		"limit":   int64(opts.Limit * similarCandidateFactor),
+	})
+	if err != nil {
+		return SimilarGames{}, fmt.Errorf("could not query similar games in Neo4j: %v", err)
+	}
+
+	var pageIDs []int
+	scored := make(map[int]SimilarGame)
+	for result.Next() {
+		values := result.Record().Values
+		pageID, _ := values[0].(int64)
+		game := SimilarGame{Shared: make(map[string][]string)}
+		game.Score, _ = values[1].(float64)
+		relations, _ := values[2].([]interface{})
+		for _, relation := range relations {
+			fields, _ := relation.(map[string]interface{})
+			rel, _ := fields["rel"].(string)
+			names, _ := fields["names"].([]interface{})
+			for _, name := range names {
+				if name, ok := name.(string); ok {
+					game.Shared[kinds[rel]] = append(game.Shared[kinds[rel]], name)
+				}
+			}
+		}
+		pageIDs = append(pageIDs, int(pageID))
+		scored[int(pageID)] = game
+	}
+	if err := result.Err(); err != nil {
+		return SimilarGames{}, fmt.Errorf("could not query similar games in Neo4j: %v", err)
+	}
+
+	summaries, err := r.Catalog.gameSummaries(ctx, "page_id", pageIDs)
+	if err != nil {
+		return SimilarGames{}, err
+	}
+	similar := make([]SimilarGame, 0, len(pageIDs))
+	for _, pageID := range pageIDs {
+		summary, ok := summaries[pageID]
+		if !ok {
+			// The graph lags behind a deletion in PostgreSQL
+			continue
+		}
+		game := scored[pageID]
+		game.GameSummary = summary
+		similar = append(similar, game)
+	}
+	return SimilarGames{Game: target, Metric: opts.Metric, Similar: rankSimilar(target, similar, opts)}, nil
+}
@@ -0,0 +1,221 @@
+// Package pipeline connects processing stages with bounded channels. Every stage runs
+// a fixed number of workers, stops when its context is cancelled and reports failed
+// items on its own error channel instead of aborting the whole pipeline.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSkip can be returned by a stage function to drop an item without reporting an error.
+var ErrSkip = errors.New("skip item")
+
+// StageConfig sizes a single stage.
+type StageConfig struct {
+	Workers int // Number of goroutines processing items concurrently
+	Buffer  int // Capacity of the stage's output channel; a full buffer blocks the workers
+}
+
+// StageError is an item a stage failed to process.
+type StageError struct {
+	Stage string      // Name of the stage that failed
+	Item  interface{} // The input the stage was processing, if any
+	Err   error
+}
+
+// Error implements the error interface.
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Stage is a running stage: its output and the errors of the items it failed to process.
+// Both channels are closed once the stage has finished. The caller must drain Errors,
+// otherwise a failing stage blocks.
+type Stage[Out any] struct {
+	Name   string
+	Out    <-chan Out
+	Errors <-chan error
+}
+
+// Source runs a single goroutine calling fn, which produces items with emit. emit
+// blocks while the output buffer is full and fails once ctx is cancelled. An error
+// returned by fn is reported on the stage's error channel.
+func Source[Out any](ctx context.Context, name string, buffer int, fn func(ctx context.Context, emit func(Out) error) error) Stage[Out] {
+	out := make(chan Out, max(buffer, 0))
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(out)
+
+		emit := func(item Out) error {
+			select {
+			case out <- item:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := fn(ctx, emit); err != nil {
+			errs <- &StageError{Stage: name, Err: err}
+		}
+	}()
+	return Stage[Out]{Name: name, Out: out, Errors: errs}
+}
+
+// Map runs config.Workers goroutines applying fn to every item received from in and
+// sends the results to the stage's output in completion order. Items fn fails on are
+// reported on the stage's error channel, except those it skips with ErrSkip. The stage
+// finishes when in is closed and drained, or when ctx is cancelled.
+func Map[In, Out any](ctx context.Context, name string, config StageConfig, in <-chan In, fn func(ctx context.Context, item In) (Out, error)) Stage[Out] {
+	workers := max(config.Workers, 1)
+	out := make(chan Out, max(config.Buffer, 0))
+	errs := make(chan error, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var item In
+				var ok bool
+				select {
+				case item, ok = <-in:
+					if !ok {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+
+				result, err := fn(ctx, item)
+				if errors.Is(err, ErrSkip) {
+					continue
+				}
+				if err != nil {
+					select {
+					case errs <- &StageError{Stage: name, Item: item, Err: err}:
+						continue
+					case <-ctx.Done():
+						return
+					}
+				}
+
+				select {
+				case out <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+		close(errs)
+	}()
+	return Stage[Out]{Name: name, Out: out, Errors: errs}
+}
+
+// Sink runs config.Workers goroutines calling fn for every item received from in.
+// Its output channel is closed without carrying values; wait on it to know when the
+// sink has finished.
+func Sink[In any](ctx context.Context, name string, config StageConfig, in <-chan In, fn func(ctx context.Context, item In) error) Stage[struct{}] {
+	stage := Map(ctx, name, StageConfig{Workers: config.Workers}, in, func(ctx context.Context, item In) (struct{}, error) {
+		if err := fn(ctx, item); err != nil {
+			return struct{}{}, err
+		}
+		return struct{}{}, ErrSkip
+	})
+	return stage
+}
+
+// Tee copies every item received from in to n output channels, each buffered with
+// buffer items. A slow consumer slows down all of them. The outputs are closed once
+// in is closed or ctx is cancelled.
+func Tee[T any](ctx context.Context, in <-chan T, n, buffer int) []<-chan T {
+	outs := make([]chan T, n)
+	result := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T, max(buffer, 0))
+		result[i] = outs[i]
+	}
+
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		for item := range in {
+			for _, out := range outs {
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return result
+}
+
+// Collect forwards the errors of every stage to fn, one at a time, until all error
+// channels are closed.
+func Collect(fn func(err error), stages ...<-chan error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, errs := range stages {
+		wg.Add(1)
+		go func(errs <-chan error) {
+			defer wg.Done()
+			for err := range errs {
+				mu.Lock()
+				fn(err)
+				mu.Unlock()
+			}
+		}(errs)
+	}
+	wg.Wait()
+}
+
+// Merge forwards the items of every input channel to a single output buffered with
+// buffer items. Nil inputs are ignored. The output is closed once every input is
+// closed or ctx is cancelled.
+func Merge[T any](ctx context.Context, buffer int, ins ...<-chan T) <-chan T {
+	out := make(chan T, max(buffer, 0))
+
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		if in == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(in <-chan T) {
+			defer wg.Done()
+			for item := range in {
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(in)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
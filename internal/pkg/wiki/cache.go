@@ -0,0 +1,148 @@
+package wiki
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotCached is returned in offline mode for requests the cache cannot answer.
+var ErrNotCached = errors.New("response is not in the offline cache")
+
+// Cache stores API responses on disk so repeated runs do not fetch the same pages
+// again. Response bodies are stored once under the hash of their content; an index
+// entry per request URL points at the body together with the revisions it reflects
+// and the HTTP validators (ETag, Last-Modified) it was served with.
+//
+// A cached response is reused without a request when it was stored for the same
+// revisions the pages have now. Otherwise it is revalidated with a conditional
+// request. In offline mode every request is answered from the cache or fails with
+// ErrNotCached.
+type Cache struct {
+	Dir     string // Root directory of the cache
+	Offline bool   // Never contact the API; serve everything from the cache
+
+	hits        atomic.Int64
+	misses      atomic.Int64
+	notModified atomic.Int64
+}
+
+// CacheStats counts how requests were answered by a Cache.
+type CacheStats struct {
+	Hits        int64 // Served from the cache without a request
+	Misses      int64 // Fetched because no usable entry existed
+	NotModified int64 // Revalidated with a conditional request answered by 304
+}
+
+// cacheEntry is the index record of a cached request.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	Revision     string    `json:"revision,omitempty"` // Revisions of the pages the response covers, see revisionKey
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Blob         string    `json:"blob"` // SHA-256 of the body
+	StoredAt     time.Time `json:"stored_at"`
+
+	Body []byte `json:"-"`
+}
+
+// NewCache returns a cache rooted at dir, creating the directory if needed.
+func NewCache(dir string) (*Cache, error) {
+	for _, sub := range []string{"index", "blobs"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %v", err)
+		}
+	}
+	return &Cache{Dir: dir}, nil
+}
+
+// Stats returns the cache counters.
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), NotModified: c.notModified.Load()}
+}
+
+// lookup returns the entry stored for url with its body, if any.
+func (c *Cache) lookup(url string) (*cacheEntry, bool) {
+	data, err := os.ReadFile(c.indexPath(url))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != url || !validBlob(entry.Blob) {
+		return nil, false
+	}
+	entry.Body, err = os.ReadFile(c.blobPath(entry.Blob))
+	if err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// store saves a response body and points the index entry of its URL at it.
+func (c *Cache) store(entry cacheEntry) error {
+	sum := sha256.Sum256(entry.Body)
+	entry.Blob = hex.EncodeToString(sum[:])
+	entry.StoredAt = time.Now().UTC()
+
+	// Identical bodies share a blob, so only write it once
+	blob := c.blobPath(entry.Blob)
+	if _, err := os.Stat(blob); err != nil {
+		if err := writeFileAtomic(blob, entry.Body); err != nil {
+			return err
+		}
+	}
+
+	index, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.indexPath(entry.URL), index)
+}
+
+func (c *Cache) indexPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(c.Dir, "index", key[:2], key+".json")
+}
+
+// validBlob reports whether blob is a SHA-256 hex digest, as written by store. A
+// truncated or hand-edited index may hold anything else.
+func validBlob(blob string) bool {
+	if len(blob) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(blob)
+	return err == nil
+}
+
+func (c *Cache) blobPath(blob string) string {
+	return filepath.Join(c.Dir, "blobs", blob[:2], blob)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place, so
+// concurrent readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
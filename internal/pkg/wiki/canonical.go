@@ -0,0 +1,284 @@
+package wiki
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// defaultEntityAliases is the curated list of alternative names of well-known entities.
+//
+//go:embed entity_aliases.tsv
+var defaultEntityAliases string
+
+// corporateSuffixes are dropped from the end of names before they are compared, so
+// "Nintendo Co., Ltd." and "Nintendo" are the same developer.
+var corporateSuffixes = map[string]bool{
+	"inc": true, "incorporated": true, "ltd": true, "limited": true, "co": true, "company": true,
+	"corp": true, "corporation": true, "llc": true, "plc": true, "gmbh": true, "ag": true,
+	"sa": true, "srl": true, "bv": true, "ab": true, "oy": true, "kk": true, "pty": true,
+}
+
+// EntityAlias maps an alternative name of an entity to its canonical name.
+type EntityAlias struct {
+	Label     string
+	Alias     string
+	Canonical string
+}
+
+// Canonicalizer maps the many spellings of a developer, publisher, platform or genre to
+// one canonical name, so each real-world entity becomes one row and one graph node.
+//
+// A linked name is resolved through the Wikipedia page it links to: names linking to the
+// same page are the same entity, and the page title, through the curated alias table,
+// becomes its name. The link text is ignored, since "[[PlayStation 4|Sony]]" says nothing
+// about what "Sony" means elsewhere. An unlinked name is resolved through the alias table,
+// then the link targets seen before and finally the unlinked names seen before that are
+// equal after NormalizeEntityName. Developers and publishers are both companies and share
+// their names and aliases. A Canonicalizer is safe for concurrent use.
+type Canonicalizer struct {
+	mu       sync.Mutex
+	aliases  map[string]string // Curated canonical names by label and normalized alias
+	byKey    map[string]string // Canonical names by label and normalized unlinked name
+	byTarget map[string]string // Canonical names by label and normalized link target
+	byLink   map[string]string // Canonical names by label and linked page title
+}
+
+// sharedLabels maps labels that name the same kind of entity to the label their names
+// are kept under.
+var sharedLabels = map[string]string{
+	"Publisher": "Developer",
+}
+
+// NewCanonicalizer returns a canonicalizer using the given curated aliases.
+func NewCanonicalizer(aliases []EntityAlias) *Canonicalizer {
+	c := &Canonicalizer{
+		aliases:  make(map[string]string),
+		byKey:    make(map[string]string),
+		byTarget: make(map[string]string),
+		byLink:   make(map[string]string),
+	}
+	for _, alias := range aliases {
+		key := NormalizeEntityName(alias.Alias)
+		if key == "" || alias.Canonical == "" {
+			continue
+		}
+		label := namesLabel(alias.Label)
+		c.aliases[label+"|"+key] = alias.Canonical
+		c.aliases[label+"|"+NormalizeEntityName(alias.Canonical)] = alias.Canonical
+	}
+	return c
+}
+
+// Add records an entity known to exist, e.g. a row already stored, so later
+// spellings of it resolve to its name.
+func (c *Canonicalizer) Add(entity Entity) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	label := namesLabel(entity.Label)
+	name := strings.TrimSpace(entity.Text)
+	if name == "" {
+		return
+	}
+	learn(c.byKey, label, NormalizeEntityName(name), name)
+	if link := NormalizeTitle(entity.Link); link != "" {
+		learn(c.byLink, label, link, name)
+		learn(c.byTarget, label, NormalizeEntityName(stripDisambiguation(link)), name)
+	}
+}
+
+// Canonical returns the entity under its canonical name. The linked page is kept.
+func (c *Canonicalizer) Canonical(entity Entity) Entity {
+	name := strings.TrimSpace(entity.Text)
+	key := NormalizeEntityName(name)
+	link := NormalizeTitle(entity.Link)
+	if key == "" {
+		return entity
+	}
+	label := namesLabel(entity.Label)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var canonical string
+	if link != "" {
+		// The page title names the entity; "[[Rare (company)|Rareware]]" is Rare
+		target := stripDisambiguation(link)
+		targetKey := NormalizeEntityName(target)
+		canonical = firstNonEmpty(
+			c.byLink[label+"|"+link],
+			c.aliases[label+"|"+targetKey],
+			c.byTarget[label+"|"+targetKey],
+			target,
+		)
+		learn(c.byLink, label, link, canonical)
+		learn(c.byTarget, label, targetKey, canonical)
+		learn(c.byTarget, label, NormalizeEntityName(canonical), canonical)
+	} else {
+		canonical = firstNonEmpty(
+			c.aliases[label+"|"+key],
+			c.byTarget[label+"|"+key],
+			c.byKey[label+"|"+key],
+			name,
+		)
+		learn(c.byKey, label, key, canonical)
+		learn(c.byKey, label, NormalizeEntityName(canonical), canonical)
+	}
+
+	entity.Text = canonical
+	entity.Link = link
+	return entity
+}
+
+// namesLabel returns the label the names of entities with the given label are kept under.
+func namesLabel(label string) string {
+	if shared, ok := sharedLabels[label]; ok {
+		return shared
+	}
+	return label
+}
+
+// learn records the canonical name for a key unless one is already known.
+func learn(names map[string]string, label, key, canonical string) {
+	if key == "" {
+		return
+	}
+	if _, ok := names[label+"|"+key]; !ok {
+		names[label+"|"+key] = canonical
+	}
+}
+
+// firstNonEmpty returns the first of values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// CanonicalizeAll canonicalizes every entity and drops the duplicates this creates.
+// A nil Canonicalizer only drops duplicates.
+func (c *Canonicalizer) CanonicalizeAll(entities []Entity) []Entity {
+	if c == nil {
+		return MergeEntities(entities)
+	}
+	canonical := make([]Entity, len(entities))
+	for i, entity := range entities {
+		canonical[i] = c.Canonical(entity)
+	}
+	return MergeEntities(canonical)
+}
+
+// NormalizeEntityName returns the key names are compared by: lower case, "&" read as
+// "and", punctuation dropped, whitespace collapsed and trailing corporate suffixes
+// (Inc., Co., Ltd., ...) removed.
+func NormalizeEntityName(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "&", " and ")
+
+	// Hyphens and slashes separate words; other punctuation (dots, commas, apostrophes) is dropped
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '/' || r == '|' || r == '_':
+			b.WriteByte(' ')
+		}
+	}
+
+	words := strings.Fields(b.String())
+	for len(words) > 1 && corporateSuffixes[words[len(words)-1]] {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// stripDisambiguation removes a trailing parenthesized qualifier from a page title,
+// e.g. "Rare (company)" becomes "Rare".
+func stripDisambiguation(title string) string {
+	if i := strings.LastIndex(title, " ("); i > 0 && strings.HasSuffix(title, ")") {
+		return title[:i]
+	}
+	return title
+}
+
+// DefaultEntityAliases returns the curated alias list compiled into the binary.
+func DefaultEntityAliases() []EntityAlias {
+	aliases, _ := parseEntityAliases(strings.NewReader(defaultEntityAliases))
+	return aliases
+}
+
+// LoadEntityAliasesFile reads aliases from a file of "Label<TAB>Alias<TAB>Canonical" lines.
+// Blank lines and lines starting with '#' are ignored.
+func LoadEntityAliasesFile(path string) ([]EntityAlias, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open entity alias file: %v", err)
+	}
+	defer file.Close()
+
+	aliases, err := parseEntityAliases(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entity alias file %s: %v", path, err)
+	}
+	return aliases, nil
+}
+
+// LoadEntitiesPostgres reads every stored developer, publisher, platform, genre and series with the
+// Wikipedia page it is linked to, if any, to seed a Canonicalizer.
+func LoadEntitiesPostgres(ctx context.Context, db *sql.DB) ([]Entity, error) {
+	var entities []Entity
+	for _, label := range entityTableOrder {
+		table := entityTables[label]
+		rows, err := db.QueryContext(ctx, `SELECT name, COALESCE(wiki_title, '') FROM `+table.table+` ORDER BY id`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %v", table.table, err)
+		}
+		for rows.Next() {
+			entity := Entity{Label: label}
+			if err := rows.Scan(&entity.Text, &entity.Link); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			entities = append(entities, entity)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rows.Close()
+	}
+	return entities, nil
+}
+
+// parseEntityAliases reads "Label<TAB>Alias<TAB>Canonical" lines.
+func parseEntityAliases(r io.Reader) ([]EntityAlias, error) {
+	var aliases []EntityAlias
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected Label<TAB>Alias<TAB>Canonical", lineNo)
+		}
+		aliases = append(aliases, EntityAlias{
+			Label:     strings.TrimSpace(fields[0]),
+			Alias:     strings.TrimSpace(fields[1]),
+			Canonical: strings.TrimSpace(fields[2]),
+		})
+	}
+	return aliases, scanner.Err()
+}
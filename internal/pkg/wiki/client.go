@@ -0,0 +1,938 @@
+package wiki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultBaseURL is the MediaWiki API endpoint of the English Wikipedia.
+const DefaultBaseURL = "https://en.wikipedia.org/w/api.php"
+
+// DefaultCategory is the category walked when no category is configured.
+const DefaultCategory = "Category:Video games"
+
+// DefaultUserAgent identifies GameNet to Wikipedia, as required by the Wikimedia
+// User-Agent policy. Deployments should set WIKI_USER_AGENT to include a contact address.
+const DefaultUserAgent = "GameNet/1.0 (https://github.com/acolinhe/GameNet)"
+
+// Defaults for the etiquette settings of a Client.
+const (
+	DefaultRequestsPerSecond = 5 // Average request rate of a client
+	DefaultMaxLag            = 5 // Seconds of replication lag at which the API should refuse our requests
+	DefaultMaxRetries        = 5 // Retries of a throttled or failed request
+	defaultBackoffBase       = 500 * time.Millisecond
+	defaultBackoffMax        = 30 * time.Second
+	defaultMaxLagRetryAfter  = 5 * time.Second // Wait used when a maxlag error comes without Retry-After
+)
+
+// maxExtractBatch is the largest number of pages the TextExtracts extension
+// returns plain-text extracts for in a single request.
+const maxExtractBatch = 20
+
+// maxPageBatch is the largest number of page IDs a regular API request accepts.
+const maxPageBatch = 50
+
+// Namespaces used while walking a category.
+const (
+	namespaceArticle  = 0
+	namespaceCategory = 14
+)
+
+// Page is a single article returned by the MediaWiki API.
+type Page struct {
+	PageID   int    `json:"pageid"`   // Stable Wikipedia page ID
+	NS       int    `json:"ns"`       // Namespace the page lives in (0 for articles)
+	Title    string `json:"title"`    // Display title of the page
+	Extract  string `json:"extract"`  // Plain-text extract of the page
+	Missing  bool   `json:"missing"`  // Set when the requested page does not exist
+	Redirect bool   `json:"redirect"` // Set when the page is a redirect, when requested with prop=info
+
+	LastRevID int64 `json:"lastrevid,omitempty"` // ID of the latest revision, when requested with prop=info
+
+	Revisions []Revision `json:"revisions,omitempty"` // Latest revision, when requested with prop=revisions
+	Wikitext  string     `json:"-"`                   // Raw wikitext of the latest revision, when requested
+	Aliases   []string   `json:"-"`                   // Redirects that resolved to this page, when fetched through them
+}
+
+// Revision is a page revision returned by prop=revisions.
+type Revision struct {
+	RevID int64 `json:"revid"`
+	Slots struct {
+		Main struct {
+			Content string `json:"content"`
+		} `json:"main"`
+	} `json:"slots"`
+}
+
+// CategoryMember is a page or subcategory listed by list=categorymembers.
+type CategoryMember struct {
+	PageID int    `json:"pageid"`
+	NS     int    `json:"ns"`
+	Title  string `json:"title"`
+}
+
+// WikiResponse mirrors the parts of a MediaWiki API response (formatversion=2)
+// that GameNet reads.
+type WikiResponse struct {
+	Continue map[string]string `json:"continue"` // Continuation parameters for the next request
+	Query    struct {
+		Pages           []Page           `json:"pages"`
+		CategoryMembers []CategoryMember `json:"categorymembers"`
+		Normalized      []TitleMapping   `json:"normalized"` // Requested titles rewritten to their normal form
+		Redirects       []TitleMapping   `json:"redirects"`  // Redirects followed, with redirects=1
+	} `json:"query"`
+	Error *APIError `json:"error"`
+}
+
+// TitleMapping is an entry of the normalized or redirects list of a query response.
+type TitleMapping struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// APIError is the error object MediaWiki returns in the body of a failed request.
+type APIError struct {
+	Code string `json:"code"`
+	Info string `json:"info"`
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("mediawiki api error %s: %s", e.Code, e.Info)
+}
+
+// Client talks to a MediaWiki API endpoint.
+type Client struct {
+	BaseURL    string       // API endpoint, e.g. https://en.wikipedia.org/w/api.php
+	HTTPClient *http.Client // HTTP client used for every request
+	MaxDepth   int          // How many levels of subcategories to descend into
+	BatchSize  int          // Number of pages handed to the callback at once
+
+	IncludeWikitext bool // Also fetch the wikitext of every page WalkCategory reports
+
+	UserAgent   string        // Sent with every request
+	MaxLag      int           // maxlag parameter sent with every request; 0 leaves it out
+	MaxRetries  int           // How often a request is retried after a 429, 5xx, maxlag or network error
+	RateLimiter *RateLimiter  // Token bucket every request waits on; nil disables rate limiting
+	BackoffBase time.Duration // Delay before the first retry, doubled (with jitter) for each further retry
+	BackoffMax  time.Duration // Upper bound of the retry delay
+	Cache       *Cache        // On-disk response cache; nil disables caching
+
+	// SkipPage, when set, is asked about every article WalkCategory finds; pages it
+	// returns true for are neither fetched nor reported, e.g. when resuming a run.
+	// Redirects are asked about again under their target's page ID once resolved.
+	SkipPage func(pageID int) bool
+
+	requests  atomic.Int64
+	retries   atomic.Int64
+	throttled atomic.Int64
+}
+
+// ClientStats counts the requests a Client has sent.
+type ClientStats struct {
+	Requests  int64 // HTTP requests sent, including retries
+	Retries   int64 // Requests that were retried
+	Throttled int64 // Responses asking us to slow down: HTTP 429 or a maxlag error
+}
+
+// NewClient returns a Client for the given API endpoint. An empty baseURL
+// falls back to DefaultBaseURL.
+func NewClient(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL:    baseURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		MaxDepth:   1,
+		BatchSize:  maxExtractBatch,
+
+		IncludeWikitext: true,
+
+		UserAgent:   DefaultUserAgent,
+		MaxLag:      DefaultMaxLag,
+		MaxRetries:  DefaultMaxRetries,
+		RateLimiter: NewRateLimiter(DefaultRequestsPerSecond, DefaultRequestsPerSecond),
+		BackoffBase: defaultBackoffBase,
+		BackoffMax:  defaultBackoffMax,
+	}
+}
+
+// NewClientFromEnv returns a Client configured from the WIKI_API_URL, WIKI_CATEGORY_DEPTH,
+// WIKI_INFOBOX, WIKI_USER_AGENT, WIKI_RATE_LIMIT, WIKI_MAXLAG, WIKI_MAX_RETRIES,
+// WIKI_CACHE_DIR and WIKI_OFFLINE environment variables.
+func NewClientFromEnv() *Client {
+	client := NewClient(os.Getenv("WIKI_API_URL"))
+	if userAgent := os.Getenv("WIKI_USER_AGENT"); userAgent != "" {
+		client.UserAgent = userAgent
+	}
+	if rate, err := strconv.ParseFloat(os.Getenv("WIKI_RATE_LIMIT"), 64); err == nil {
+		client.RateLimiter = NewRateLimiter(rate, max(int(rate), 1))
+	}
+	if maxLag, err := strconv.Atoi(os.Getenv("WIKI_MAXLAG")); err == nil {
+		client.MaxLag = maxLag
+	}
+	if retries, err := strconv.Atoi(os.Getenv("WIKI_MAX_RETRIES")); err == nil {
+		client.MaxRetries = retries
+	}
+	if depth, err := strconv.Atoi(os.Getenv("WIKI_CATEGORY_DEPTH")); err == nil {
+		client.MaxDepth = depth
+	}
+	if infobox, err := strconv.ParseBool(os.Getenv("WIKI_INFOBOX")); err == nil {
+		client.IncludeWikitext = infobox
+	}
+	if dir := os.Getenv("WIKI_CACHE_DIR"); dir != "" {
+		client.Cache = &Cache{Dir: dir}
+		client.Cache.Offline, _ = strconv.ParseBool(os.Getenv("WIKI_OFFLINE"))
+	}
+	return client
+}
+
+// FetchWikiData walks the given category with a client configured from the
+// environment and returns every page found in a single response.
+func FetchWikiData(category string) (*WikiResponse, error) {
+	var result WikiResponse
+	err := NewClientFromEnv().WalkCategory(context.Background(), category, func(batch []Page) error {
+		result.Query.Pages = append(result.Query.Pages, batch...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WalkCategory walks a category and its subcategories down to MaxDepth and
+// calls fn with batches of article pages, each carrying its plain-text extract.
+// Every page is reported at most once, even if it is listed in several
+// subcategories.
+func (c *Client) WalkCategory(ctx context.Context, category string, fn func(batch []Page) error) error {
+	category = normalizeCategory(category)
+
+	seenCategories := map[string]bool{category: true}
+	seenPages := make(map[int]bool)
+	var pending []int
+
+	// flush fetches the extracts for the pending page IDs and hands them to fn. Redirects
+	// listed in the category resolve to their target, which may have been reported
+	// already or be skipped.
+	reported := make(map[int]bool)
+	flush := func() error {
+		ids := pending
+		pending = nil
+		return c.fetchPages(ctx, ids, c.SkipPage, func(batch []Page) error {
+			fresh := batch[:0]
+			for _, page := range batch {
+				if !reported[page.PageID] {
+					reported[page.PageID] = true
+					fresh = append(fresh, page)
+				}
+			}
+			if len(fresh) == 0 {
+				return nil
+			}
+			return fn(fresh)
+		})
+	}
+
+	// Breadth-first walk so that shallow categories are fully covered first
+	level := []string{category}
+	for depth := 0; len(level) > 0 && depth <= c.MaxDepth; depth++ {
+		var next []string
+		for _, cat := range level {
+			err := c.CategoryMembers(ctx, cat, func(member CategoryMember) error {
+				switch member.NS {
+				case namespaceCategory:
+					if !seenCategories[member.Title] {
+						seenCategories[member.Title] = true
+						next = append(next, member.Title)
+					}
+				case namespaceArticle:
+					if seenPages[member.PageID] {
+						return nil
+					}
+					seenPages[member.PageID] = true
+					if c.SkipPage != nil && c.SkipPage(member.PageID) {
+						return nil
+					}
+					pending = append(pending, member.PageID)
+					if len(pending) >= c.batchSize() {
+						return flush()
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list members of %s: %v", cat, err)
+			}
+		}
+		level = next
+	}
+
+	return flush()
+}
+
+// FetchPages fetches the extracts and revision IDs (and, with IncludeWikitext, the
+// wikitext) of the given pages and calls fn with them in batches of BatchSize.
+// Missing pages are left out.
+func (c *Client) FetchPages(ctx context.Context, pageIDs []int, fn func(batch []Page) error) error {
+	return c.fetchPages(ctx, pageIDs, nil, fn)
+}
+
+// fetchPages is FetchPages, but leaves out the pages skip (when not nil) returns true
+// for. skip is asked with the page ID each extract came back with, which for a redirect
+// is its target's, before the page's wikitext is fetched.
+func (c *Client) fetchPages(ctx context.Context, pageIDs []int, skip func(pageID int) bool, fn func(batch []Page) error) error {
+	for len(pageIDs) > 0 {
+		n := min(len(pageIDs), c.batchSize())
+		pages, err := c.FetchExtracts(ctx, pageIDs[:n])
+		if err != nil {
+			return err
+		}
+		if skip != nil {
+			kept := pages[:0]
+			for _, page := range pages {
+				if !skip(page.PageID) {
+					kept = append(kept, page)
+				}
+			}
+			pages = kept
+		}
+		if c.IncludeWikitext {
+			if err := c.attachWikitext(ctx, pages); err != nil {
+				return err
+			}
+		}
+		pageIDs = pageIDs[n:]
+		if len(pages) == 0 {
+			continue
+		}
+		if err := fn(pages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CategoryMembers lists the articles and subcategories of a category,
+// following cmcontinue until the listing is exhausted.
+func (c *Client) CategoryMembers(ctx context.Context, category string, fn func(CategoryMember) error) error {
+	params := url.Values{
+		"list":    {"categorymembers"},
+		"cmtitle": {normalizeCategory(category)},
+		"cmtype":  {"page|subcat"},
+		"cmprop":  {"ids|title"},
+		"cmlimit": {"max"},
+	}
+	return c.query(ctx, params, "", func(resp *WikiResponse) error {
+		for _, member := range resp.Query.CategoryMembers {
+			if err := fn(member); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FetchExtracts returns the plain-text extracts and latest revision IDs of the
+// given pages in the order the API reports them. Missing pages are left out.
+func (c *Client) FetchExtracts(ctx context.Context, pageIDs []int) ([]Page, error) {
+	params := url.Values{
+		"prop":        {"extracts|info"},
+		"pageids":     {joinIDs(pageIDs)},
+		"explaintext": {"1"},
+		"exintro":     {"1"},
+		"exlimit":     {"max"},
+		"redirects":   {"1"},
+	}
+
+	// Redirects are followed, so the response changes with the pages they lead to
+	revision, err := c.revisionKey(ctx, pageIDs, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extracts may be spread over several continued responses, so merge them by page ID.
+	// Redirects among the pages are followed, so their targets are reported instead.
+	var order []int
+	byID := make(map[int]*Page)
+	aliases := make(map[string][]string)
+	err = c.query(ctx, params, revision, func(resp *WikiResponse) error {
+		for _, redirect := range resp.Query.Redirects {
+			aliases[redirect.To] = appendUnique(aliases[redirect.To], redirect.From)
+		}
+		for _, page := range resp.Query.Pages {
+			if page.Missing {
+				continue
+			}
+			existing, ok := byID[page.PageID]
+			if !ok {
+				p := page
+				byID[page.PageID] = &p
+				order = append(order, page.PageID)
+				continue
+			}
+			if existing.Extract == "" {
+				existing.Extract = page.Extract
+			}
+			if existing.LastRevID == 0 {
+				existing.LastRevID = page.LastRevID
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]Page, 0, len(order))
+	for _, id := range order {
+		page := *byID[id]
+		page.Aliases = aliases[page.Title]
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// ResolveTitles resolves titles the way a link to them would: each title is normalized
+// (underscores, capitalization, ...) and followed through redirects to the article it
+// stands for. The result maps every requested title to that page, with Missing set
+// when no such page exists.
+func (c *Client) ResolveTitles(ctx context.Context, titles []string) (map[string]Page, error) {
+	resolved := make(map[string]Page, len(titles))
+	for start := 0; start < len(titles); start += maxPageBatch {
+		batch := titles[start:min(start+maxPageBatch, len(titles))]
+		params := url.Values{
+			"prop":      {"info"},
+			"titles":    {strings.Join(batch, "|")},
+			"redirects": {"1"},
+		}
+
+		renamed := make(map[string]string)
+		byTitle := make(map[string]Page)
+		err := c.query(ctx, params, "", func(resp *WikiResponse) error {
+			for _, mapping := range append(resp.Query.Normalized, resp.Query.Redirects...) {
+				renamed[mapping.From] = mapping.To
+			}
+			for _, page := range resp.Query.Pages {
+				byTitle[page.Title] = page
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, title := range batch {
+			// Normalization comes first, then at most a couple of redirects; the bound guards against loops
+			target := title
+			for i := 0; i < 4 && renamed[target] != ""; i++ {
+				target = renamed[target]
+			}
+			page, ok := byTitle[target]
+			if !ok || page.PageID == 0 {
+				page = Page{Title: target, Missing: true} // Also covers invalid titles
+			}
+			if target != title {
+				page.Aliases = []string{title}
+			}
+			resolved[title] = page
+		}
+	}
+	return resolved, nil
+}
+
+// fetchLatestRevisions returns the latest revision of each page with its content, keyed by page ID.
+func (c *Client) fetchLatestRevisions(ctx context.Context, pageIDs []int) (map[int]Revision, error) {
+	revisions := make(map[int]Revision, len(pageIDs))
+	for start := 0; start < len(pageIDs); start += maxPageBatch {
+		end := min(start+maxPageBatch, len(pageIDs))
+		params := url.Values{
+			"prop":    {"revisions"},
+			"pageids": {joinIDs(pageIDs[start:end])},
+			"rvprop":  {"ids|content"},
+			"rvslots": {"main"},
+		}
+		revision, err := c.revisionKey(ctx, pageIDs[start:end], false)
+		if err != nil {
+			return nil, err
+		}
+		err = c.query(ctx, params, revision, func(resp *WikiResponse) error {
+			for _, page := range resp.Query.Pages {
+				if len(page.Revisions) > 0 {
+					revisions[page.PageID] = page.Revisions[0]
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return revisions, nil
+}
+
+// attachWikitext fills in the Wikitext and LastRevID fields of the given pages.
+func (c *Client) attachWikitext(ctx context.Context, pages []Page) error {
+	ids := make([]int, len(pages))
+	for i, page := range pages {
+		ids[i] = page.PageID
+	}
+	revisions, err := c.fetchLatestRevisions(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to fetch wikitext: %v", err)
+	}
+	for i := range pages {
+		if revision, ok := revisions[pages[i].PageID]; ok {
+			pages[i].Wikitext = revision.Slots.Main.Content
+			pages[i].LastRevID = revision.RevID
+		}
+	}
+	return nil
+}
+
+// FetchPageInfo returns the current title, latest revision ID and redirect flag of
+// each page, keyed by page ID. Pages that no longer exist are reported with Missing set.
+func (c *Client) FetchPageInfo(ctx context.Context, pageIDs []int) (map[int]Page, error) {
+	info := make(map[int]Page, len(pageIDs))
+	for start := 0; start < len(pageIDs); start += maxPageBatch {
+		end := min(start+maxPageBatch, len(pageIDs))
+		params := url.Values{
+			"prop":    {"info"},
+			"pageids": {joinIDs(pageIDs[start:end])},
+		}
+		err := c.query(ctx, params, "", func(resp *WikiResponse) error {
+			for _, page := range resp.Query.Pages {
+				info[page.PageID] = page
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// fetchResolvedRevisions returns the latest revision of every page the given pages
+// resolve to once redirects are followed, keyed by the ID of the resolved page.
+// Missing pages are left out.
+func (c *Client) fetchResolvedRevisions(ctx context.Context, pageIDs []int) (map[int]int64, error) {
+	revisions := make(map[int]int64, len(pageIDs))
+	for start := 0; start < len(pageIDs); start += maxPageBatch {
+		end := min(start+maxPageBatch, len(pageIDs))
+		params := url.Values{
+			"prop":      {"info"},
+			"pageids":   {joinIDs(pageIDs[start:end])},
+			"redirects": {"1"},
+		}
+		err := c.query(ctx, params, "", func(resp *WikiResponse) error {
+			for _, page := range resp.Query.Pages {
+				if !page.Missing && page.LastRevID != 0 {
+					revisions[page.PageID] = page.LastRevID
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return revisions, nil
+}
+
+// FetchRevisions returns the ID of the latest revision of each page, keyed by page ID.
+// Missing pages are left out.
+func (c *Client) FetchRevisions(ctx context.Context, pageIDs []int) (map[int]int64, error) {
+	info, err := c.FetchPageInfo(ctx, pageIDs)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make(map[int]int64, len(info))
+	for id, page := range info {
+		if !page.Missing && page.LastRevID != 0 {
+			revisions[id] = page.LastRevID
+		}
+	}
+	return revisions, nil
+}
+
+// revisionKey identifies the current revisions of the given pages, e.g. "12:345|13:678",
+// so cached responses about these pages are reused until one of them is edited. For
+// requests following redirects, it identifies the pages the redirects lead to instead,
+// whose edits change the response. It is empty when there is no cache to key, or in
+// offline mode where revisions are unknown.
+func (c *Client) revisionKey(ctx context.Context, pageIDs []int, redirects bool) (string, error) {
+	if c.Cache == nil || c.Cache.Offline {
+		return "", nil
+	}
+	var revisions map[int]int64
+	var err error
+	ids := append([]int(nil), pageIDs...)
+	if redirects {
+		revisions, err = c.fetchResolvedRevisions(ctx, pageIDs)
+		ids = ids[:0]
+		for id := range revisions {
+			ids = append(ids, id)
+		}
+	} else {
+		revisions, err = c.FetchRevisions(ctx, pageIDs)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch revisions: %v", err)
+	}
+
+	sort.Ints(ids)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id) + ":" + strconv.FormatInt(revisions[id], 10)
+	}
+	return strings.Join(parts, "|"), nil
+}
+
+// query runs an action=query request and keeps issuing it with the returned
+// continuation parameters until the API reports no further results.
+func (c *Client) query(ctx context.Context, params url.Values, revision string, fn func(*WikiResponse) error) error {
+	continuation := map[string]string{}
+	for {
+		req := url.Values{}
+		for key, values := range params {
+			req[key] = values
+		}
+		for key, value := range continuation {
+			req.Set(key, value)
+		}
+		req.Set("action", "query")
+
+		var resp WikiResponse
+		if err := c.get(ctx, req, revision, &resp); err != nil {
+			return err
+		}
+		if err := fn(&resp); err != nil {
+			return err
+		}
+
+		if len(resp.Continue) == 0 {
+			return nil
+		}
+		continuation = resp.Continue
+	}
+}
+
+// Stats returns the request counters of the client.
+func (c *Client) Stats() ClientStats {
+	return ClientStats{
+		Requests:  c.requests.Load(),
+		Retries:   c.retries.Load(),
+		Throttled: c.throttled.Load(),
+	}
+}
+
+// retryableError is a failed request that may succeed when sent again.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration // Delay requested by the server, if any
+	throttled  bool          // The server asked us to slow down
+}
+
+// Error implements the error interface.
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// get issues a GET request against the API and decodes the JSON body into out. Every
+// attempt waits for the rate limiter; throttled and failed requests are retried up to
+// MaxRetries times with jittered exponential backoff, waiting at least as long as the
+// server's Retry-After.
+//
+// With a Cache, a response stored for the same revision (see revisionKey) is returned
+// without a request, and other cached responses are revalidated with a conditional
+// request. An empty revision means the response cannot be tied to page revisions.
+func (c *Client) get(ctx context.Context, params url.Values, revision string, out *WikiResponse) error {
+	params.Set("format", "json")
+	params.Set("formatversion", "2")
+	if c.MaxLag > 0 {
+		params.Set("maxlag", strconv.Itoa(c.MaxLag))
+	}
+	// maxlag only matters on the wire, so leave it out of the cache key
+	rawURL := c.BaseURL + "?" + params.Encode()
+	cacheURL := rawURL
+	if c.MaxLag > 0 {
+		cacheParams := url.Values{}
+		for key, values := range params {
+			cacheParams[key] = values
+		}
+		cacheParams.Del("maxlag")
+		cacheURL = c.BaseURL + "?" + cacheParams.Encode()
+	}
+
+	var cached *cacheEntry
+	if c.Cache != nil {
+		entry, ok := c.Cache.lookup(cacheURL)
+		switch {
+		case ok && (c.Cache.Offline || (revision != "" && entry.Revision == revision)):
+			c.Cache.hits.Add(1)
+			return decodeResponse(entry.Body, out)
+		case c.Cache.Offline:
+			c.Cache.misses.Add(1)
+			return fmt.Errorf("%w: %s", ErrNotCached, cacheURL)
+		case ok:
+			cached = entry
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if c.RateLimiter != nil {
+			if err := c.RateLimiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		resp, err := c.do(ctx, rawURL, cached)
+		if err == nil {
+			err = decodeResponse(resp.Body, out)
+			// The replicas are lagging; the API asks clients to wait before trying again
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.Code == "maxlag" {
+				retryAfter := resp.retryAfter
+				if retryAfter == 0 {
+					retryAfter = defaultMaxLagRetryAfter
+				}
+				err = &retryableError{err: err, retryAfter: retryAfter, throttled: true}
+			}
+			if err == nil && c.Cache != nil {
+				c.storeResponse(cacheURL, revision, cached, resp)
+			}
+		}
+
+		var retry *retryableError
+		if !errors.As(err, &retry) {
+			return err
+		}
+		if retry.throttled {
+			c.throttled.Add(1)
+		}
+		if attempt >= c.MaxRetries {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt+1, retry.err)
+		}
+		c.retries.Add(1)
+
+		// Back off, and hold back every other request too when the server asked for it
+		delay := c.backoff(attempt)
+		if retry.retryAfter > 0 {
+			delay = max(delay, retry.retryAfter)
+			if c.RateLimiter != nil {
+				c.RateLimiter.Pause(retry.retryAfter)
+			}
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// response is the body and validators of a successful request.
+type response struct {
+	Body         []byte
+	ETag         string
+	LastModified string
+	NotModified  bool          // The server confirmed the cached body with a 304
+	retryAfter   time.Duration // Retry-After sent with the response, e.g. with a maxlag error
+}
+
+// do sends a single request, conditional on the validators of cached when it is not nil.
+// Failures worth retrying are returned as *retryableError.
+func (c *Client) do(ctx context.Context, rawURL string, cached *cacheEntry) (*response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	c.requests.Add(1)
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &retryableError{err: fmt.Errorf("failed to reach Wikipedia: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return &response{Body: cached.Body, ETag: cached.ETag, LastModified: cached.LastModified, NotModified: true}, nil
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("unexpected status %s from Wikipedia: %s", resp.Status, strings.TrimSpace(string(body)))
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			return nil, &retryableError{err: err, retryAfter: retryAfter, throttled: true}
+		case resp.StatusCode >= 500:
+			return nil, &retryableError{err: err, retryAfter: retryAfter}
+		}
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("failed to read Wikipedia response: %v", err)}
+	}
+	return &response{
+		Body:         body,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		retryAfter:   retryAfter,
+	}, nil
+}
+
+// storeResponse records a successful response in the cache. A cache that cannot be
+// written is ignored since it only saves requests.
+func (c *Client) storeResponse(cacheURL, revision string, cached *cacheEntry, resp *response) {
+	if resp.NotModified {
+		c.Cache.notModified.Add(1)
+	} else {
+		c.Cache.misses.Add(1)
+	}
+	c.Cache.store(cacheEntry{
+		URL:          cacheURL,
+		Revision:     revision,
+		ETag:         resp.ETag,
+		LastModified: resp.LastModified,
+		Body:         resp.Body,
+	})
+}
+
+// decodeResponse decodes an API response body into out and returns the API error it reports, if any.
+func decodeResponse(body []byte, out *WikiResponse) error {
+	*out = WikiResponse{}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode Wikipedia response: %v", err)
+	}
+	if out.Error != nil {
+		return out.Error
+	}
+	return nil
+}
+
+// backoff returns the delay before retry number attempt+1: an exponentially growing
+// base with random jitter, so that many clients do not retry in lockstep.
+func (c *Client) backoff(attempt int) time.Duration {
+	base := c.BackoffBase
+	if base <= 0 {
+		base = defaultBackoffBase
+	}
+	limit := c.BackoffMax
+	if limit <= 0 {
+		limit = defaultBackoffMax
+	}
+
+	delay := base << min(attempt, 30)
+	if delay <= 0 || delay > limit {
+		delay = limit
+	}
+	// Equal jitter: half of the delay is fixed, the other half random
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) batchSize() int {
+	if c.BatchSize <= 0 || c.BatchSize > maxExtractBatch {
+		return maxExtractBatch
+	}
+	return c.BatchSize
+}
+
+// normalizeCategory turns "video_games" or "Category:Video_games" into "Category:Video games".
+func normalizeCategory(category string) string {
+	category = NormalizeTitle(strings.TrimPrefix(strings.TrimSpace(category), "Category:"))
+	if category == "" {
+		return DefaultCategory
+	}
+	return "Category:" + category
+}
+
+// NormalizeTitle rewrites a title the way MediaWiki does before looking it up:
+// underscores become spaces, runs of whitespace collapse and the first letter is
+// capitalized. "zelda_1" and "Zelda 1" name the same page.
+func NormalizeTitle(title string) string {
+	title = strings.Join(strings.Fields(strings.ReplaceAll(title, "_", " ")), " ")
+	first, size := utf8.DecodeRuneInString(title)
+	if first == utf8.RuneError {
+		return title
+	}
+	return string(unicode.ToUpper(first)) + title[size:]
+}
+
+// appendUnique appends value to values unless it is already present.
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// joinIDs formats page IDs as a pipe-separated list for the pageids parameter.
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, "|")
+}
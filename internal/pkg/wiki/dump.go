@@ -0,0 +1,270 @@
+package wiki
+
+import (
+	"compress/bzip2"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	categoryLinkPattern = regexp.MustCompile(`(?i)\[\[\s*category\s*:\s*([^|\]]+)`)
+	headingPattern      = regexp.MustCompile(`(?m)^=+[^=\n].*=+\s*$`)
+	tablePattern        = regexp.MustCompile(`(?s)\{\|.*?\|\}`)
+	magicWordPattern    = regexp.MustCompile(`__[A-Z]+__`)
+)
+
+// gameSubcategories are the names of the subcategories of a category, e.g. "Video games",
+// whose articles are games as well; %[1]s stands for the category and %[2]s for one of
+// gameQualifiers. Other names ending in it, such as "Lists of video games", hold list
+// and topic articles instead.
+var gameSubcategories = []string{
+	`\d{4} %[1]s`,     // 1993 video games
+	`(?:%[2]s) %[1]s`, // Puzzle video games
+	`%[1]s (?:developed|published) (?:in|by) [^:]+`, // Video games developed in Japan
+}
+
+// gameQualifiers are the genres and modes that name game subcategories.
+var gameQualifiers = []string{
+	"action", "action-adventure", "action role-playing", "adventure", "arcade", "beat 'em up",
+	"fighting", "first-person shooter", "horror", "indie", "multiplayer", "multiplayer and single-player",
+	"platform", "puzzle", "racing", "real-time strategy", "rhythm", "role-playing", "sandbox",
+	"shooter", "simulation", "single-player", "sports", "stealth", "strategy", "survival",
+	"survival horror", "third-person shooter", "turn-based strategy",
+}
+
+// DumpReader streams a MediaWiki XML export (pages-articles.xml, optionally
+// bzip2-compressed) and reports the video game articles it contains as the same
+// Page records WalkCategory produces, so the rest of the pipeline does not need to
+// know where the pages came from. Pages are decoded one at a time, so memory use
+// does not grow with the size of the dump.
+type DumpReader struct {
+	BatchSize int // Number of pages handed to the callback at once
+
+	// A page is kept when it carries an {{Infobox video game}} (if Infobox is set),
+	// is in one of Categories or in a category matching one of Subcategories, such as
+	// "2010 video games" for "Video games".
+	Infobox       bool
+	Categories    []string
+	Subcategories []*regexp.Regexp
+
+	IncludeWikitext bool // Attach the wikitext of every page, as Client.IncludeWikitext does
+
+	// SkipPage, when set, is asked about every matching page; pages it returns true
+	// for are not reported, e.g. when resuming a run.
+	SkipPage func(pageID int) bool
+}
+
+// dumpPage is a <page> element of the export.
+type dumpPage struct {
+	Title    string `xml:"title"`
+	NS       int    `xml:"ns"`
+	ID       int    `xml:"id"`
+	Redirect *struct {
+		Title string `xml:"title,attr"`
+	} `xml:"redirect"`
+	Revision struct {
+		ID   int64  `xml:"id"`
+		Text string `xml:"text"`
+	} `xml:"revision"`
+}
+
+// NewDumpReader returns a reader keeping pages with a video game infobox, in category
+// (e.g. "Category:Video games") or in its year, genre and country subcategories.
+func NewDumpReader(category string) *DumpReader {
+	name := strings.TrimPrefix(normalizeCategory(category), "Category:")
+	return &DumpReader{
+		BatchSize:       maxExtractBatch,
+		Infobox:         true,
+		Categories:      []string{name},
+		Subcategories:   GameSubcategories(name),
+		IncludeWikitext: true,
+	}
+}
+
+// GameSubcategories returns patterns matching the names of the subcategories of
+// category whose articles are games, e.g. "1993 video games" or "Puzzle video games"
+// for "Video games".
+func GameSubcategories(category string) []*regexp.Regexp {
+	qualifiers := make([]string, len(gameQualifiers))
+	for i, qualifier := range gameQualifiers {
+		qualifiers[i] = regexp.QuoteMeta(qualifier)
+	}
+	name := regexp.QuoteMeta(strings.TrimSpace(category))
+
+	patterns := make([]*regexp.Regexp, len(gameSubcategories))
+	for i, pattern := range gameSubcategories {
+		patterns[i] = regexp.MustCompile(`(?i)^` + fmt.Sprintf(pattern, name, strings.Join(qualifiers, "|")) + `$`)
+	}
+	return patterns
+}
+
+// WalkFile opens the dump at path, decompressing it when the name ends in .bz2,
+// and walks it like Walk.
+func (d *DumpReader) WalkFile(ctx context.Context, path string, fn func(batch []Page) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open dump: %v", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".bz2") {
+		r = bzip2.NewReader(file)
+	}
+	return d.Walk(ctx, r, fn)
+}
+
+// Walk reads a dump from r and calls fn with batches of the matching articles,
+// each carrying a plain-text extract of its introduction. Redirects and pages
+// outside the article namespace are ignored.
+func (d *DumpReader) Walk(ctx context.Context, r io.Reader, fn func(batch []Page) error) error {
+	decoder := xml.NewDecoder(r)
+	var batch []Page
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read dump: %v", err)
+		}
+
+		// Only <page> elements are decoded; everything else (siteinfo, ...) is skipped
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "page" {
+			continue
+		}
+		var page dumpPage
+		if err := decoder.DecodeElement(&page, &start); err != nil {
+			return fmt.Errorf("failed to decode dump page: %v", err)
+		}
+
+		if page.NS != namespaceArticle || page.Redirect != nil || !d.Match(page.Revision.Text) {
+			continue
+		}
+		if d.SkipPage != nil && d.SkipPage(page.ID) {
+			continue
+		}
+
+		batch = append(batch, d.toPage(page))
+		if len(batch) >= d.batchSize() {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// Match reports whether a page with the given wikitext is a video game article:
+// it carries the infobox or is in one of the configured categories or subcategories.
+func (d *DumpReader) Match(wikitext string) bool {
+	if d.Infobox && infoboxPattern.MatchString(wikitext) {
+		return true
+	}
+	for _, category := range PageCategories(wikitext) {
+		for _, wanted := range d.Categories {
+			if strings.EqualFold(category, strings.TrimSpace(wanted)) {
+				return true
+			}
+		}
+		for _, pattern := range d.Subcategories {
+			if pattern.MatchString(category) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toPage turns a dump page into the record the live API would have returned.
+func (d *DumpReader) toPage(page dumpPage) Page {
+	result := Page{
+		PageID:    page.ID,
+		NS:        page.NS,
+		Title:     page.Title,
+		Extract:   PlainTextExtract(page.Revision.Text),
+		LastRevID: page.Revision.ID,
+	}
+	if d.IncludeWikitext {
+		result.Wikitext = page.Revision.Text
+	}
+	return result
+}
+
+func (d *DumpReader) batchSize() int {
+	if d.BatchSize <= 0 {
+		return maxExtractBatch
+	}
+	return d.BatchSize
+}
+
+// PageCategories returns the names of the categories a page's wikitext puts it in,
+// without the "Category:" prefix.
+func PageCategories(wikitext string) []string {
+	var categories []string
+	for _, match := range categoryLinkPattern.FindAllStringSubmatch(stripComments(wikitext), -1) {
+		name := strings.TrimSpace(strings.ReplaceAll(match[1], "_", " "))
+		if name != "" {
+			categories = append(categories, name)
+		}
+	}
+	return categories
+}
+
+// PlainTextExtract approximates the plain-text introduction the TextExtracts API
+// returns (explaintext, exintro): the text before the first section heading, with
+// templates, tables, references and markup removed and paragraphs on separate lines.
+func PlainTextExtract(wikitext string) string {
+	text := stripComments(wikitext)
+	if loc := headingPattern.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]]
+	}
+	text = stripTemplates(text)
+	text = tablePattern.ReplaceAllString(text, "")
+	text = magicWordPattern.ReplaceAllString(text, "")
+
+	var paragraphs []string
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if cleaned := cleanWikitext(paragraph); cleaned != "" {
+			paragraphs = append(paragraphs, cleaned)
+		}
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+// stripTemplates removes every top-level {{...}} template, including ones (like the
+// infobox) that span several paragraphs.
+func stripTemplates(text string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(text, "{{")
+		if i < 0 {
+			break
+		}
+		b.WriteString(text[:i])
+		end := matchClosing(text, i)
+		if end < 0 {
+			text = ""
+			break
+		}
+		text = text[end:]
+	}
+	b.WriteString(text)
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package wiki
+
+import "context"
+
+// EntityExtractor finds entities (developers, platforms, genres, ...) in a piece of text.
+//
+// *NERPool is the model-backed implementation; *Gazetteer is a dictionary-based
+// implementation that needs no external processes.
+type EntityExtractor interface {
+	Extract(ctx context.Context, text string) ([]Entity, error)
+}
+
+// Confidence given to entities by extractors that do not score them individually.
+const (
+	InfoboxConfidence   = 1.0 // Infobox values are curated by the article's editors
+	GazetteerConfidence = 0.9 // Known names matched as whole words
+	NERConfidence       = 0.6 // Model predictions the NER worker did not score
+)
+
+// GazetteerSource is the Source of entities found by a Gazetteer.
+const GazetteerSource = "gazetteer"
+
+// InfoboxSource returns the Source of entities taken from an infobox field.
+func InfoboxSource(field string) string {
+	return "infobox:" + field
+}
+
+// NERSource returns the Source of entities found by a NER model, given as "name@version".
+func NERSource(model string) string {
+	return "ner:" + model
+}
+
+// MergeEntities concatenates entity lists, dropping entities with the same text
+// and label as one seen earlier. The first occurrence keeps its place, but it takes
+// the link of a later one if it has none, and the provenance (confidence, offsets
+// and source) of a later one the extractor is more confident about.
+func MergeEntities(lists ...[]Entity) []Entity {
+	merged := []Entity{}
+	seen := make(map[[2]string]int)
+	for _, list := range lists {
+		for _, entity := range list {
+			key := [2]string{entity.Text, entity.Label}
+			if i, ok := seen[key]; ok {
+				if merged[i].Link == "" {
+					merged[i].Link = entity.Link
+				}
+				if entity.Confidence > merged[i].Confidence {
+					merged[i].Confidence = entity.Confidence
+					merged[i].Start, merged[i].End = entity.Start, entity.End
+					merged[i].Source = entity.Source
+				}
+				continue
+			}
+			seen[key] = len(merged)
+			merged = append(merged, entity)
+		}
+	}
+	return merged
+}
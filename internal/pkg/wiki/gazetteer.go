@@ -0,0 +1,276 @@
+package wiki
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// defaultGazetteer is a small seed list of well-known developers, platforms and genres.
+//
+//go:embed gazetteer.tsv
+var defaultGazetteer string
+
+// GazetteerEntry is a single known name and the entity label it maps to.
+type GazetteerEntry struct {
+	Name  string
+	Label string
+}
+
+// Gazetteer is an EntityExtractor that finds known names in text using an
+// Aho-Corasick automaton. Only whole words are matched, and overlapping matches are
+// resolved in favour of the leftmost, then longest name. Matching is case-insensitive,
+// except for names of a single word: those are common words too ("Rare", "Racing"),
+// so only their exact spelling is taken for the name.
+type Gazetteer struct {
+	entries []GazetteerEntry
+	exact   []bool // Whether the entry of the same index only matches its exact spelling
+	nodes   []gazetteerNode
+}
+
+// gazetteerNode is a state of the Aho-Corasick automaton.
+type gazetteerNode struct {
+	next   map[rune]int // Goto transitions
+	fail   int          // Failure link
+	output int          // Nearest state (this one or along the failure links) that ends a name, or -1
+	depth  int          // Length of the state's prefix in runes
+	ends   []int        // Entries whose name ends exactly at this state
+}
+
+// gazetteerMatch is a candidate match expressed in rune positions of the text.
+type gazetteerMatch struct {
+	start, end int // Rune offsets, end exclusive
+	entries    []int
+}
+
+// NewGazetteer builds a gazetteer over the given entries. Duplicate entries are ignored.
+func NewGazetteer(entries []GazetteerEntry) *Gazetteer {
+	g := &Gazetteer{nodes: []gazetteerNode{{next: map[rune]int{}, output: -1}}}
+
+	seen := make(map[GazetteerEntry]bool)
+	for _, entry := range entries {
+		entry.Name = strings.TrimSpace(entry.Name)
+		if entry.Name == "" || entry.Label == "" || seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		g.entries = append(g.entries, entry)
+		g.exact = append(g.exact, isSingleWord(entry.Name))
+		g.insert(entry.Name, len(g.entries)-1)
+	}
+	g.link()
+	return g
+}
+
+// LoadGazetteerFile reads gazetteer entries from a file of "Label<TAB>Name" lines.
+// Blank lines and lines starting with '#' are ignored.
+func LoadGazetteerFile(path string) ([]GazetteerEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gazetteer file: %v", err)
+	}
+	defer file.Close()
+
+	entries, err := parseGazetteer(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gazetteer file %s: %v", path, err)
+	}
+	return entries, nil
+}
+
+// LoadGazetteerPostgres reads every known developer, platform and genre name from PostgreSQL.
+func LoadGazetteerPostgres(ctx context.Context, db *sql.DB) ([]GazetteerEntry, error) {
+	tables := []struct{ table, label string }{
+		{"Developers", "Developer"},
+		{"Platforms", "Platform"},
+		{"Genres", "Genre"},
+	}
+
+	var entries []GazetteerEntry
+	for _, t := range tables {
+		rows, err := db.QueryContext(ctx, `SELECT name FROM `+t.table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %v", t.table, err)
+		}
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			entries = append(entries, GazetteerEntry{Name: name, Label: t.label})
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rows.Close()
+	}
+	return entries, nil
+}
+
+// DefaultGazetteerEntries returns the seed list of names compiled into the binary.
+func DefaultGazetteerEntries() []GazetteerEntry {
+	entries, _ := parseGazetteer(strings.NewReader(defaultGazetteer))
+	return entries
+}
+
+// Extract returns every known name found in text, in order of appearance. Offsets
+// are in characters (runes) of text.
+func (g *Gazetteer) Extract(ctx context.Context, text string) ([]Entity, error) {
+	runes := []rune(text)
+
+	// Walk the automaton and collect every whole-word match
+	var matches []gazetteerMatch
+	state := 0
+	for i, r := range runes {
+		r = unicode.ToLower(r)
+		for state != 0 && g.nodes[state].next[r] == 0 {
+			state = g.nodes[state].fail
+		}
+		state = g.nodes[state].next[r]
+
+		for out := g.nodes[state].output; out > 0; out = g.nodes[g.nodes[out].fail].output {
+			node := g.nodes[out]
+			start := i + 1 - node.depth
+			if !isWordBoundary(runes, start-1) || !isWordBoundary(runes, i+1) {
+				continue
+			}
+			var entries []int
+			for _, idx := range node.ends {
+				if !g.exact[idx] || string(runes[start:i+1]) == g.entries[idx].Name {
+					entries = append(entries, idx)
+				}
+			}
+			if len(entries) > 0 {
+				matches = append(matches, gazetteerMatch{start: start, end: i + 1, entries: entries})
+			}
+		}
+	}
+
+	// Keep the leftmost, then longest, non-overlapping matches
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end > matches[j].end
+	})
+
+	entities := []Entity{}
+	covered := 0
+	for _, match := range matches {
+		if match.start < covered {
+			continue
+		}
+		covered = match.end
+		for _, idx := range match.entries {
+			entities = append(entities, Entity{
+				Text:       g.entries[idx].Name,
+				Label:      g.entries[idx].Label,
+				Confidence: GazetteerConfidence,
+				Start:      match.start,
+				End:        match.end,
+				Source:     GazetteerSource,
+			})
+		}
+	}
+	return entities, nil
+}
+
+// Len returns the number of names in the gazetteer.
+func (g *Gazetteer) Len() int {
+	return len(g.entries)
+}
+
+// insert adds a name to the trie, case-folded rune by rune.
+func (g *Gazetteer) insert(name string, entry int) {
+	state := 0
+	for _, r := range name {
+		r = unicode.ToLower(r)
+		next, ok := g.nodes[state].next[r]
+		if !ok {
+			g.nodes = append(g.nodes, gazetteerNode{next: map[rune]int{}, output: -1, depth: g.nodes[state].depth + 1})
+			next = len(g.nodes) - 1
+			g.nodes[state].next[r] = next
+		}
+		state = next
+	}
+	g.nodes[state].ends = append(g.nodes[state].ends, entry)
+}
+
+// link computes failure and output links breadth-first.
+func (g *Gazetteer) link() {
+	queue := []int{}
+	for _, child := range g.nodes[0].next {
+		g.nodes[child].fail = 0
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		// Output link: this state if it ends a name, otherwise inherit from the failure state
+		if len(g.nodes[state].ends) > 0 {
+			g.nodes[state].output = state
+		} else {
+			g.nodes[state].output = g.nodes[g.nodes[state].fail].output
+		}
+
+		for r, child := range g.nodes[state].next {
+			fail := g.nodes[state].fail
+			for fail != 0 && g.nodes[fail].next[r] == 0 {
+				fail = g.nodes[fail].fail
+			}
+			if target, ok := g.nodes[fail].next[r]; ok && target != child {
+				g.nodes[child].fail = target
+			} else {
+				g.nodes[child].fail = 0
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+// isWordBoundary reports whether position i of runes is outside the text or not part of a word.
+func isWordBoundary(runes []rune, i int) bool {
+	if i < 0 || i >= len(runes) {
+		return true
+	}
+	return !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i])
+}
+
+// isSingleWord reports whether name is one word of letters and digits only.
+func isSingleWord(name string) bool {
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseGazetteer reads "Label<TAB>Name" lines.
+func parseGazetteer(r io.Reader) ([]GazetteerEntry, error) {
+	var entries []GazetteerEntry
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		label, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected Label<TAB>Name", lineNo)
+		}
+		entries = append(entries, GazetteerEntry{Name: strings.TrimSpace(name), Label: strings.TrimSpace(label)})
+	}
+	return entries, scanner.Err()
+}
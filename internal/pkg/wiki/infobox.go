@@ -0,0 +1,431 @@
+package wiki
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// infoboxLabels maps "Infobox video game" parameters to the entity label their values get.
+// The order is the order entities are reported in.
+var infoboxLabels = []struct {
+	field string
+	label string
+}{
+	{"developer", "Developer"},
+	{"publisher", "Publisher"},
+	{"director", "Director"},
+	{"producer", "Producer"},
+	{"designer", "Designer"},
+	{"programmer", "Programmer"},
+	{"artist", "Artist"},
+	{"writer", "Writer"},
+	{"composer", "Composer"},
+	{"series", "Series"},
+	{"engine", "Engine"},
+	{"platforms", "Platform"},
+	{"platform", "Platform"},
+	{"released", "ReleaseDate"},
+	{"release", "ReleaseDate"},
+	{"genre", "Genre"},
+	{"modes", "Mode"},
+}
+
+// Templates whose arguments are each a separate list item.
+var listTemplates = map[string]bool{
+	"ubl": true, "ublist": true, "unbulleted list": true, "unbullet": true,
+	"hlist": true, "flatlist": true, "flat list": true, "plainlist": true, "plain list": true,
+	"bulleted list": true, "bull": true, "collapsible list": true, "vgrtbl": true,
+}
+
+// Templates listing release dates as alternating region/date arguments.
+var releaseTemplates = map[string]bool{
+	"vgrelease": true, "video game release": true, "vgrelease new": true, "vgr": true,
+}
+
+// Templates that only wrap their first argument in formatting.
+var wrapperTemplates = map[string]bool{
+	"nowrap": true, "nobr": true, "small": true, "nobold": true, "noitalic": true,
+	"vgy": true, "vgyear": true, "video game year": true, "nihongo foot": true,
+	"smaller": true, "resize": true, "abbr": true, "sort": true,
+}
+
+var (
+	commentPattern = regexp.MustCompile(`(?s)<!--.*?-->`)
+	refPattern     = regexp.MustCompile(`(?is)<ref[^>/]*/>|<ref[^>]*>.*?</ref>`)
+	brPattern      = regexp.MustCompile(`(?i)^<br\s*/?>`)
+	tagPattern     = regexp.MustCompile(`<[^>]+>`)
+	extLinkPattern = regexp.MustCompile(`\[(?:https?:)?//[^\s\]]+\s*([^\]]*)\]`)
+	spacePattern   = regexp.MustCompile(`\s+`)
+	infoboxPattern = regexp.MustCompile(`(?i)\{\{\s*infobox[ _]+video[ _]+game\s*(\||\}\}|\n)`)
+)
+
+// Infobox holds the parameters of an {{Infobox video game}} template.
+type Infobox struct {
+	Fields map[string]string // Raw wikitext value of each parameter, keyed by lower-case name
+}
+
+// ParseInfobox finds the {{Infobox video game}} template in a page's wikitext and
+// returns its parameters. The boolean is false when the page has no such infobox.
+func ParseInfobox(wikitext string) (*Infobox, bool) {
+	wikitext = stripComments(wikitext)
+
+	loc := infoboxPattern.FindStringIndex(wikitext)
+	if loc == nil {
+		return nil, false
+	}
+	end := matchClosing(wikitext, loc[0])
+	if end < 0 {
+		return nil, false
+	}
+
+	_, args := parseTemplate(wikitext[loc[0]:end])
+	infobox := &Infobox{Fields: make(map[string]string)}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if key != "" && value != "" {
+			infobox.Fields[key] = value
+		}
+	}
+	return infobox, true
+}
+
+// Values returns the cleaned list items of a parameter, expanding list templates,
+// line breaks and bullets into separate items and wiki links into their text.
+func (ib *Infobox) Values(field string) []string {
+	raw, ok := ib.Fields[field]
+	if !ok {
+		return nil
+	}
+	return splitValue(raw)
+}
+
+// Entities turns the infobox parameters into labelled entities. Items that are a
+// single wiki link keep the linked page title, which identifies the entity. Entities
+// record the field they come from as their source; they have no offsets.
+func (ib *Infobox) Entities() []Entity {
+	entities := []Entity{}
+	for _, mapping := range infoboxLabels {
+		raw, ok := ib.Fields[mapping.field]
+		if !ok {
+			continue
+		}
+		for _, item := range splitValueItems(raw) {
+			entities = append(entities, Entity{
+				Text:       item.text,
+				Label:      mapping.label,
+				Link:       item.link,
+				Confidence: InfoboxConfidence,
+				Source:     InfoboxSource(mapping.field),
+			})
+		}
+	}
+	return entities
+}
+
+// ReleaseDate returns the first release date listed in the infobox, or "" if there is none.
+func (ib *Infobox) ReleaseDate() string {
+	for _, field := range []string{"released", "release"} {
+		if values := ib.Values(field); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// InfoboxEntities parses the infobox in wikitext and returns its entities, or nil if
+// the page has no video game infobox.
+func InfoboxEntities(wikitext string) []Entity {
+	infobox, ok := ParseInfobox(wikitext)
+	if !ok {
+		return nil
+	}
+	return infobox.Entities()
+}
+
+// valueItem is a cleaned item of an infobox value and the page it links to, if the
+// item is a single wiki link.
+type valueItem struct {
+	text string
+	link string
+}
+
+// splitValue breaks an infobox value into its individual cleaned items.
+func splitValue(raw string) []string {
+	var values []string
+	for _, item := range splitValueItems(raw) {
+		values = append(values, item.text)
+	}
+	return values
+}
+
+// splitValueItems breaks an infobox value into its individual cleaned items,
+// keeping the link target of items that are a single wiki link.
+func splitValueItems(raw string) []valueItem {
+	var items []valueItem
+	var current strings.Builder
+
+	// flush turns the text collected so far into an item
+	flush := func() {
+		text := current.String()
+		current.Reset()
+		items = append(items, splitLinkedList(text)...)
+	}
+
+	for i := 0; i < len(raw); {
+		switch {
+		case strings.HasPrefix(raw[i:], "{{"):
+			end := matchClosing(raw, i)
+			if end < 0 {
+				current.WriteString(raw[i:])
+				i = len(raw)
+				continue
+			}
+			name, args := parseTemplate(raw[i:end])
+			positional := positionalArgs(args)
+			switch {
+			case listTemplates[name]:
+				flush()
+				for _, arg := range positional {
+					items = append(items, splitValueItems(arg)...)
+				}
+			case releaseTemplates[name]:
+				flush()
+				for _, date := range releaseDates(positional) {
+					items = append(items, valueItem{text: date})
+				}
+			case wrapperTemplates[name]:
+				if len(positional) > 0 {
+					current.WriteString(positional[0])
+				}
+			case name == "start date" || name == "release date" || name == "start date and age":
+				current.WriteString(strings.Join(positional, "-"))
+			case name == "lang" && len(positional) > 1:
+				current.WriteString(positional[1])
+			}
+			// Any other template (citations, footnotes, ...) is dropped
+			i = end
+
+		case strings.HasPrefix(raw[i:], "[["):
+			end := matchClosing(raw, i)
+			if end < 0 {
+				end = len(raw)
+			}
+			current.WriteString(raw[i:end])
+			i = end
+
+		case raw[i] == '\n':
+			flush()
+			i++
+
+		case raw[i] == '<' && brPattern.MatchString(raw[i:]):
+			flush()
+			i += len(brPattern.FindString(raw[i:]))
+
+		default:
+			current.WriteByte(raw[i])
+			i++
+		}
+	}
+	flush()
+
+	// Drop empty and duplicate items
+	var result []valueItem
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if item.text != "" && !seen[item.text] {
+			seen[item.text] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// splitLinkedList cleans a single line of wikitext. Comma-separated lists are only
+// split when every part is a wiki link, so names like "Nintendo Co., Ltd." stay whole.
+func splitLinkedList(text string) []valueItem {
+	text = strings.TrimSpace(text)
+	text = strings.TrimLeft(text, "*#:; ")
+
+	parts := splitTopLevel(text, ',')
+	if len(parts) > 1 {
+		allLinks := true
+		for _, part := range parts {
+			if !strings.HasPrefix(strings.TrimSpace(part), "[[") {
+				allLinks = false
+				break
+			}
+		}
+		if allLinks {
+			var items []valueItem
+			for _, part := range parts {
+				items = append(items, valueItem{text: cleanWikitext(part), link: soleLinkTarget(part)})
+			}
+			return items
+		}
+	}
+	return []valueItem{{text: cleanWikitext(text), link: soleLinkTarget(text)}}
+}
+
+// soleLinkTarget returns the target of text if it consists of a single wiki link,
+// possibly in italics or bold, and "" otherwise. Files and categories are not targets.
+func soleLinkTarget(text string) string {
+	text = strings.Trim(strings.TrimSpace(text), "'")
+	if !strings.HasPrefix(text, "[[") || matchClosing(text, 0) != len(text) {
+		return ""
+	}
+	target, _ := splitLink(text)
+	if linkText(text) == "" {
+		return ""
+	}
+	return target
+}
+
+// releaseDates extracts the dates from {{vgrelease|NA|date|EU|date}} style arguments.
+// A single argument is a worldwide date.
+func releaseDates(args []string) []string {
+	if len(args) == 1 {
+		return splitValue(args[0])
+	}
+	var dates []string
+	for i := 1; i < len(args); i += 2 {
+		dates = append(dates, splitValue(args[i])...)
+	}
+	return dates
+}
+
+// cleanWikitext converts inline wikitext into plain text: links become their
+// display text, formatting, tags and leftover templates are removed.
+func cleanWikitext(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		switch {
+		case strings.HasPrefix(text[i:], "[["):
+			end := matchClosing(text, i)
+			if end < 0 {
+				end = len(text)
+			}
+			b.WriteString(linkText(text[i:end]))
+			i = end
+		case strings.HasPrefix(text[i:], "{{"):
+			end := matchClosing(text, i)
+			if end < 0 {
+				end = len(text)
+			}
+			i = end
+		default:
+			b.WriteByte(text[i])
+			i++
+		}
+	}
+
+	out := extLinkPattern.ReplaceAllString(b.String(), "$1")
+	out = strings.ReplaceAll(out, "'''", "")
+	out = strings.ReplaceAll(out, "''", "")
+	out = tagPattern.ReplaceAllString(out, "")
+	out = html.UnescapeString(out)
+	out = spacePattern.ReplaceAllString(out, " ")
+	return strings.Trim(out, " ,;")
+}
+
+// linkText returns the display text of a [[Target|Text]] or [[Target]] link.
+// Links to files and categories have no text.
+func linkText(link string) string {
+	target, text := splitLink(link)
+	lower := strings.ToLower(target)
+	if strings.HasPrefix(lower, "file:") || strings.HasPrefix(lower, "image:") || strings.HasPrefix(lower, "category:") {
+		return ""
+	}
+	return text
+}
+
+// splitLink returns the target and display text of a wiki link.
+func splitLink(link string) (target, text string) {
+	inner := strings.TrimSuffix(strings.TrimPrefix(link, "[["), "]]")
+	target, text, ok := strings.Cut(inner, "|")
+	if !ok {
+		text = target
+	}
+	target = strings.TrimSpace(target)
+	if i := strings.Index(target, "#"); i > 0 {
+		target = target[:i]
+	}
+	return target, strings.TrimSpace(text)
+}
+
+// parseTemplate splits "{{name|arg|key=value}}" into its normalized name and raw arguments.
+func parseTemplate(template string) (string, []string) {
+	inner := strings.TrimSuffix(strings.TrimPrefix(template, "{{"), "}}")
+	parts := splitTopLevel(inner, '|')
+	name := strings.ToLower(strings.TrimSpace(strings.ReplaceAll(parts[0], "_", " ")))
+	return name, parts[1:]
+}
+
+// positionalArgs returns the template arguments that are not key=value pairs,
+// treating explicit numeric keys (1=...) as positional.
+func positionalArgs(args []string) []string {
+	var positional []string
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if ok && !strings.ContainsAny(key, "[{") {
+			key = strings.TrimSpace(key)
+			if key == "" || strings.Trim(key, "0123456789") != "" {
+				continue
+			}
+			arg = value
+		}
+		positional = append(positional, arg)
+	}
+	return positional
+}
+
+// splitTopLevel splits s on sep, ignoring separators nested in templates or links.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "{{") || strings.HasPrefix(s[i:], "[["):
+			depth++
+			i++
+		case (strings.HasPrefix(s[i:], "}}") || strings.HasPrefix(s[i:], "]]")) && depth > 0:
+			depth--
+			i++
+		case s[i] == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// matchClosing returns the index just past the "}}" or "]]" that closes the
+// template or link opening at s[start], or -1 if it is never closed.
+func matchClosing(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s)-1; i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "{{") || strings.HasPrefix(s[i:], "[["):
+			depth++
+			i++
+		case strings.HasPrefix(s[i:], "}}") || strings.HasPrefix(s[i:], "]]"):
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// stripComments removes HTML comments and <ref> footnotes from wikitext.
+func stripComments(wikitext string) string {
+	wikitext = commentPattern.ReplaceAllString(wikitext, "")
+	return refPattern.ReplaceAllString(wikitext, "")
+}
@@ -0,0 +1,352 @@
+package wiki
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// defaultLabelRules maps the labels of spaCy's English models to GameNet entity types.
+//
+//go:embed label_map.tsv
+var defaultLabelRules string
+
+// DropLabel is the type of a rule that drops the entities it matches.
+const DropLabel = "-"
+
+// entityTypes are the GameNet entity types besides the infobox labels. Entities an
+// extractor already labels with a GameNet type, like the gazetteer's, pass through
+// a LabelMap unchanged.
+var entityTypes = map[string]bool{"Game": true, "Product": true}
+
+// LabelRule maps an extractor-native label to a GameNet entity type.
+type LabelRule struct {
+	Raw     string // Label emitted by the extractor, e.g. ORG
+	Type    string // GameNet entity type, e.g. Developer, or DropLabel
+	Context string // Phrase that must precede the mention in its sentence, e.g. "published by"; "" for the default
+}
+
+// LabelMap translates the labels an extractor emits (ORG, PRODUCT, WORK_OF_ART, ...)
+// into GameNet entity types.
+//
+// Each raw label has an ordered list of rules. Rules with a context apply when their
+// phrase occurs before the mention in the same sentence; when several do, the one
+// whose phrase is nearest to the mention wins, so in "developed by Nintendo and
+// published by Sega" Nintendo is a Developer and Sega a Publisher. Otherwise the
+// first rule without a context applies. Entities with a label that has no rules and
+// is not a GameNet type are dropped as unmapped.
+type LabelMap struct {
+	rules map[string][]LabelRule
+}
+
+// NewLabelMap returns a map applying the given rules.
+func NewLabelMap(rules []LabelRule) *LabelMap {
+	m := &LabelMap{rules: make(map[string][]LabelRule)}
+	for _, rule := range rules {
+		if rule.Raw == "" || rule.Type == "" {
+			continue
+		}
+		rule.Context = normalizeContext(rule.Context)
+		m.rules[rule.Raw] = append(m.rules[rule.Raw], rule)
+	}
+	return m
+}
+
+// DefaultLabelRules returns the rules compiled into the binary.
+func DefaultLabelRules() []LabelRule {
+	rules, _ := parseLabelRules(strings.NewReader(defaultLabelRules))
+	return rules
+}
+
+// DefaultLabelMap returns a map applying DefaultLabelRules.
+func DefaultLabelMap() *LabelMap {
+	return NewLabelMap(DefaultLabelRules())
+}
+
+// LoadLabelRulesFile reads rules from a file of "Raw<TAB>Type[<TAB>Context]" lines.
+// Blank lines and lines starting with '#' are ignored.
+func LoadLabelRulesFile(path string) ([]LabelRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open label map file: %v", err)
+	}
+	defer file.Close()
+
+	rules, err := parseLabelRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read label map file %s: %v", path, err)
+	}
+	return rules, nil
+}
+
+// Map relabels the entities extracted from text with GameNet types and returns the
+// ones that are kept. Context rules need the entities' offsets into text. Every
+// decision is counted in report, which may be nil.
+func (m *LabelMap) Map(text string, entities []Entity, report *LabelReport) []Entity {
+	var runes []rune
+	mapped := make([]Entity, 0, len(entities))
+	for _, entity := range entities {
+		rules, ok := m.rules[entity.Label]
+		if !ok {
+			if isEntityType(entity.Label) {
+				mapped = append(mapped, entity)
+			} else {
+				report.count(entity.Label, "")
+			}
+			continue
+		}
+
+		if runes == nil {
+			runes = []rune(text)
+		}
+		entityType := resolveLabel(rules, sentenceBefore(runes, entity))
+		report.count(entity.Label, entityType)
+		if entityType == "" || entityType == DropLabel {
+			continue
+		}
+		entity.Label = entityType
+		mapped = append(mapped, entity)
+	}
+	return mapped
+}
+
+// resolveLabel picks the type of the rule whose context phrase is nearest to the end of
+// before, falling back to the first rule without a context. It returns "" when no rule
+// applies.
+func resolveLabel(rules []LabelRule, before string) string {
+	fallback, nearestType, nearest := "", "", -1
+	for _, rule := range rules {
+		if rule.Context == "" {
+			if fallback == "" {
+				fallback = rule.Type
+			}
+			continue
+		}
+		if end := lastPhraseEnd(before, rule.Context); end > nearest {
+			nearestType, nearest = rule.Type, end
+		}
+	}
+	if nearest >= 0 {
+		return nearestType
+	}
+	return fallback
+}
+
+// sentenceBefore returns the normalized text between the start of the entity's
+// sentence and the entity, or "" if the entity's position is unknown.
+func sentenceBefore(runes []rune, entity Entity) string {
+	if entity.End == 0 || entity.Start > len(runes) {
+		return ""
+	}
+	start := entity.Start
+	for start > 0 && !strings.ContainsRune(".!?;\n", runes[start-1]) {
+		start--
+	}
+	return normalizeContext(string(runes[start:entity.Start]))
+}
+
+// lastPhraseEnd returns the end of the last whole-word occurrence of phrase in text,
+// or -1 if there is none.
+func lastPhraseEnd(text, phrase string) int {
+	for end := len(text); end > 0; {
+		i := strings.LastIndex(text[:end], phrase)
+		if i < 0 {
+			return -1
+		}
+		after := i + len(phrase)
+		if isWordBoundaryByte(text, i-1) && isWordBoundaryByte(text, after) {
+			return after
+		}
+		end = i + len(phrase) - 1
+	}
+	return -1
+}
+
+// isWordBoundaryByte reports whether byte i of the normalized text s is outside it or
+// a space.
+func isWordBoundaryByte(s string, i int) bool {
+	return i < 0 || i >= len(s) || s[i] == ' '
+}
+
+// normalizeContext lower-cases text and reduces punctuation and whitespace to single
+// spaces, so phrases match regardless of formatting.
+func normalizeContext(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// isEntityType reports whether label is already a GameNet entity type.
+func isEntityType(label string) bool {
+	if entityTypes[label] {
+		return true
+	}
+	for _, mapping := range infoboxLabels {
+		if mapping.label == label {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLabelRules reads "Raw<TAB>Type[<TAB>Context]" lines.
+func parseLabelRules(r io.Reader) ([]LabelRule, error) {
+	var rules []LabelRule
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: expected Raw<TAB>Type[<TAB>Context]", lineNo)
+		}
+		rule := LabelRule{Raw: strings.TrimSpace(fields[0]), Type: strings.TrimSpace(fields[1])}
+		if len(fields) == 3 {
+			rule.Context = strings.TrimSpace(fields[2])
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// LabelCounts counts how the entities of a run were labelled and which were lost.
+type LabelCounts struct {
+	Mapped   map[string]map[string]int `json:"mapped,omitempty"`   // Entities by raw label and the type it was mapped to
+	Dropped  map[string]int            `json:"dropped,omitempty"`  // Entities a rule dropped, by raw label
+	Unmapped map[string]int            `json:"unmapped,omitempty"` // Entities whose raw label has no rule
+	Unstored map[string]int            `json:"unstored,omitempty"` // Entities of a type PostgreSQL has no table for, by type
+}
+
+// Add adds the counts of other to c.
+func (c *LabelCounts) Add(other LabelCounts) {
+	for raw, types := range other.Mapped {
+		for entityType, n := range types {
+			if c.Mapped == nil {
+				c.Mapped = make(map[string]map[string]int)
+			}
+			if c.Mapped[raw] == nil {
+				c.Mapped[raw] = make(map[string]int)
+			}
+			c.Mapped[raw][entityType] += n
+		}
+	}
+	addCounts(&c.Dropped, other.Dropped)
+	addCounts(&c.Unmapped, other.Unmapped)
+	addCounts(&c.Unstored, other.Unstored)
+}
+
+// String summarizes the counts on one line, e.g. for the log.
+func (c LabelCounts) String() string {
+	var mapped []string
+	for _, raw := range sortedKeys(c.Mapped) {
+		for _, entityType := range sortedKeys(c.Mapped[raw]) {
+			mapped = append(mapped, fmt.Sprintf("%s→%s %d", raw, entityType, c.Mapped[raw][entityType]))
+		}
+	}
+	parts := []string{"mapped " + joinOrNone(mapped)}
+	for _, group := range []struct {
+		name   string
+		counts map[string]int
+	}{{"dropped", c.Dropped}, {"unmapped", c.Unmapped}, {"not stored", c.Unstored}} {
+		var items []string
+		for _, key := range sortedKeys(group.counts) {
+			items = append(items, fmt.Sprintf("%s %d", key, group.counts[key]))
+		}
+		parts = append(parts, group.name+" "+joinOrNone(items))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// LabelReport accumulates LabelCounts from concurrent extractions. A nil report
+// counts nothing.
+type LabelReport struct {
+	mu     sync.Mutex
+	counts LabelCounts
+}
+
+// CountUnstored counts the entities UpsertGame will not store because PostgreSQL has
+// no table for their type.
+func (r *LabelReport) CountUnstored(entities []Entity) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, entity := range entities {
+		if _, ok := entityTables[entity.Label]; !ok {
+			addCounts(&r.counts.Unstored, map[string]int{entity.Label: 1})
+		}
+	}
+}
+
+// Counts returns a copy of the counts so far.
+func (r *LabelReport) Counts() LabelCounts {
+	var counts LabelCounts
+	if r == nil {
+		return counts
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts.Add(r.counts)
+	return counts
+}
+
+// count records that an entity with the raw label got entityType: "" when no rule
+// applied, DropLabel when a rule dropped it.
+func (r *LabelReport) count(raw, entityType string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch entityType {
+	case "":
+		addCounts(&r.counts.Unmapped, map[string]int{raw: 1})
+	case DropLabel:
+		addCounts(&r.counts.Dropped, map[string]int{raw: 1})
+	default:
+		r.counts.Add(LabelCounts{Mapped: map[string]map[string]int{raw: {entityType: 1}}})
+	}
+}
+
+// addCounts adds other to the counts in *counts, allocating the map when needed.
+func addCounts(counts *map[string]int, other map[string]int) {
+	for key, n := range other {
+		if *counts == nil {
+			*counts = make(map[string]int)
+		}
+		(*counts)[key] += n
+	}
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// joinOrNone joins items with commas, or returns "none".
+func joinOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
@@ -2,29 +2,56 @@ import sys
 import spacy
 import json
 
-nlp = spacy.load("en_core_web_sm")
+MODEL = "en_core_web_sm"
 
-ENTITY_LABELS = {
-    "ORG": "Developer",   # Organizations -> Developers
-    "PRODUCT": "Platform",  # Products -> Platforms
-    "NORP": "Genre",       # Nationalities, religious or political groups -> Genres (as in game genres)
-}
+nlp = spacy.load(MODEL)
 
 def extract_entities(text):
     doc = nlp(text)
     entities = []
 
+    # Labels are the model's own (ORG, PRODUCT, ...); the Go side maps them to
+    # GameNet types, see label_map.tsv
     for ent in doc.ents:
-        label = ENTITY_LABELS.get(ent.label_, "Other")
+        # Offsets are in characters, as Python counts them; spaCy's default
+        # pipelines do not score entities, so no confidence is reported
         entities.append({
             "text": ent.text,
-            "label": label
+            "label": ent.label_,
+            "start": ent.start_char,
+            "end": ent.end_char
         })
     return entities
 
+def serve():
+    # Worker mode used by the Go NER pool. The protocol is line-delimited JSON:
+    # after loading the model we announce readiness, then answer every
+    # {"id": N, "text": "..."} request line with {"id": N, "entities": [...]}
+    # or {"id": N, "error": "..."}.
+    print(json.dumps({"ready": True, "model": MODEL, "version": nlp.meta.get("version", "")}), flush=True)
+
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+
+        request_id = None
+        try:
+            request = json.loads(line)
+            request_id = request.get("id")
+            response = {"id": request_id, "entities": extract_entities(request.get("text", ""))}
+        except Exception as e:
+            response = {"id": request_id, "error": str(e)}
+
+        print(json.dumps(response), flush=True)
+
 if __name__ == "__main__":
+    if len(sys.argv) == 2 and sys.argv[1] == "--serve":
+        serve()
+        sys.exit(0)
+
     if len(sys.argv) != 2:
-        print("Usage: python ner.py 'Your text here'")
+        print("Usage: python ner.py 'Your text here' | python ner.py --serve")
         sys.exit(1)
 
     input_text = sys.argv[1]
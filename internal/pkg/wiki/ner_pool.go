@@ -0,0 +1,418 @@
+package wiki
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNERPoolClosed is returned by Extract once the pool has been closed.
+var ErrNERPoolClosed = errors.New("NER pool is closed")
+
+// Errors wrapped by Extract when a worker fails to answer a request.
+var (
+	ErrNERTimeout      = errors.New("NER request timed out")
+	ErrNERWorkerExited = errors.New("NER worker exited")
+	ErrNERFailed       = errors.New("NER worker failed") // The worker reported an error for the text
+)
+
+// NERPoolConfig configures a pool of long-lived NER worker processes.
+//
+// Workers speak a line-delimited JSON protocol over stdin/stdout: once the
+// model is loaded a worker prints {"ready": true, "model": "..."}, then answers
+// every {"id": N, "text": "..."} line with {"id": N, "entities": [...]} or
+// {"id": N, "error": "..."}.
+type NERPoolConfig struct {
+	Command        string        // Program to launch, e.g. python3
+	Args           []string      // Arguments for the program, e.g. ner.py --serve
+	Size           int           // Number of worker processes
+	RequestTimeout time.Duration // Maximum time a single request may take before the worker is restarted
+	StartTimeout   time.Duration // Maximum time a worker may take to load its model
+	Labels         *LabelMap     // Maps the model's labels to GameNet types; DefaultLabelMap when nil
+	LabelReport    *LabelReport  // Counts how labels were mapped, if set
+}
+
+// NERPool runs NER requests against a fixed number of long-lived worker processes,
+// restarting workers that crash or stop responding.
+type NERPool struct {
+	config NERPoolConfig
+
+	slots  chan *nerWorker // Idle workers; a nil entry is a slot whose worker must be (re)started
+	nextID atomic.Uint64   // Source of request IDs
+
+	mu      sync.Mutex
+	workers map[*nerWorker]bool // Live workers, so Close can stop them
+	model   string              // Model name reported by the most recent worker handshake
+
+	restarts atomic.Int64
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// nerWorker is a single child process of the pool.
+type nerWorker struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses chan nerResponse // Decoded response lines, closed when the process exits
+	exited    chan struct{}    // Closed once the process has been reaped
+	quit      chan struct{}    // Closed when the pool retires the worker
+	stop      sync.Once
+}
+
+// nerRequest is a single line written to a worker's stdin.
+type nerRequest struct {
+	ID   uint64 `json:"id"`
+	Text string `json:"text"`
+}
+
+// nerResponse is a single line read from a worker's stdout.
+type nerResponse struct {
+	ID       uint64   `json:"id"`
+	Entities []Entity `json:"entities"`
+	Error    string   `json:"error"`
+	Ready    bool     `json:"ready"`
+	Model    string   `json:"model"`
+	Version  string   `json:"version"`
+}
+
+// NewNERPool starts config.Size workers and waits for each of them to load its model.
+func NewNERPool(config NERPoolConfig) (*NERPool, error) {
+	if config.Size <= 0 {
+		config.Size = 1
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = time.Minute
+	}
+	if config.StartTimeout <= 0 {
+		config.StartTimeout = 2 * time.Minute
+	}
+	if config.Labels == nil {
+		config.Labels = DefaultLabelMap()
+	}
+
+	pool := &NERPool{
+		config:  config,
+		slots:   make(chan *nerWorker, config.Size),
+		workers: make(map[*nerWorker]bool),
+		closed:  make(chan struct{}),
+	}
+
+	// Start all workers concurrently since loading a model can take several seconds
+	started := make(chan error, config.Size)
+	for i := 0; i < config.Size; i++ {
+		go func() {
+			worker, err := pool.spawn()
+			pool.slots <- worker
+			started <- err
+		}()
+	}
+
+	var errs []error
+	for i := 0; i < config.Size; i++ {
+		if err := <-started; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		pool.Close()
+		return nil, fmt.Errorf("failed to start NER workers: %v", errors.Join(errs...))
+	}
+	return pool, nil
+}
+
+// NERPoolConfigFromEnv builds a pool configuration from the NER_COMMAND, NER_SCRIPT,
+// NER_WORKERS and NER_TIMEOUT environment variables.
+func NERPoolConfigFromEnv() NERPoolConfig {
+	config := NERPoolConfig{
+		Command: "python3",
+		Args:    []string{"ner.py", "--serve"},
+		Size:    2,
+	}
+	if command := os.Getenv("NER_COMMAND"); command != "" {
+		config.Command = command
+	}
+	if script := os.Getenv("NER_SCRIPT"); script != "" {
+		config.Args = []string{script, "--serve"}
+	}
+	if size, err := strconv.Atoi(os.Getenv("NER_WORKERS")); err == nil {
+		config.Size = size
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("NER_TIMEOUT")); err == nil {
+		config.RequestTimeout = timeout
+	}
+	return config
+}
+
+// Extract runs NER on text using the next idle worker. A worker that crashes or
+// exceeds the request timeout is killed and replaced on the next request.
+// Entities are attributed to the pool's model; those the worker did not score get
+// NERConfidence. The worker's labels are mapped to GameNet types by config.Labels.
+func (p *NERPool) Extract(ctx context.Context, text string) ([]Entity, error) {
+	select {
+	case <-p.closed:
+		return nil, ErrNERPoolClosed
+	default:
+	}
+
+	// Wait for an idle worker
+	var worker *nerWorker
+	select {
+	case worker = <-p.slots:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.closed:
+		return nil, ErrNERPoolClosed
+	}
+
+	// Replace workers that died while idle
+	if worker == nil || !worker.alive() {
+		if worker != nil {
+			p.retire(worker)
+		}
+		var err error
+		worker, err = p.spawn()
+		p.restarts.Add(1)
+		if err != nil {
+			p.slots <- nil
+			return nil, err
+		}
+	}
+
+	id := p.nextID.Add(1)
+	line, err := json.Marshal(nerRequest{ID: id, Text: text})
+	if err != nil {
+		p.slots <- worker
+		return nil, err
+	}
+	if _, err := worker.stdin.Write(append(line, '\n')); err != nil {
+		p.retire(worker)
+		p.slots <- nil
+		return nil, fmt.Errorf("failed to send request to NER worker: %v", err)
+	}
+
+	timer := time.NewTimer(p.config.RequestTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case resp, ok := <-worker.responses:
+			if !ok {
+				// The worker exited before answering
+				p.retire(worker)
+				p.slots <- nil
+				return nil, fmt.Errorf("%w while processing request %d: %v", ErrNERWorkerExited, id, worker.exitErr())
+			}
+			if resp.ID != id {
+				// A late answer to a request whose caller gave up; drop it
+				continue
+			}
+			p.slots <- worker
+			if resp.Error != "" {
+				return nil, fmt.Errorf("%w: %s", ErrNERFailed, resp.Error)
+			}
+			if resp.Entities == nil {
+				resp.Entities = []Entity{}
+			}
+			source := NERSource(p.Model())
+			for i := range resp.Entities {
+				resp.Entities[i].Source = source
+				if resp.Entities[i].Confidence == 0 {
+					resp.Entities[i].Confidence = NERConfidence
+				}
+			}
+			return p.config.Labels.Map(text, resp.Entities, p.config.LabelReport), nil
+		case <-timer.C:
+			// The worker is stuck; kill it so the slot gets a fresh process
+			p.retire(worker)
+			p.slots <- nil
+			return nil, fmt.Errorf("%w: request %d took longer than %s", ErrNERTimeout, id, p.config.RequestTimeout)
+		case <-ctx.Done():
+			// The worker is still busy with our request; its answer will be discarded
+			p.slots <- worker
+			return nil, ctx.Err()
+		case <-p.closed:
+			return nil, ErrNERPoolClosed
+		}
+	}
+}
+
+// Alive returns the number of worker processes currently running.
+func (p *NERPool) Alive() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	alive := 0
+	for worker := range p.workers {
+		if worker.alive() {
+			alive++
+		}
+	}
+	return alive
+}
+
+// Check reports an error when the pool is closed or none of its workers is running.
+// It has the signature of a readiness check.
+func (p *NERPool) Check(ctx context.Context) error {
+	select {
+	case <-p.closed:
+		return ErrNERPoolClosed
+	default:
+	}
+	if p.Alive() == 0 {
+		return errors.New("no NER workers are running")
+	}
+	return nil
+}
+
+// Restarts returns how many times a worker has been restarted after a crash or timeout.
+func (p *NERPool) Restarts() int64 {
+	return p.restarts.Load()
+}
+
+// Model returns the model name reported by the workers, e.g. en_core_web_sm.
+func (p *NERPool) Model() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.model
+}
+
+// Close stops all worker processes. Pending and future requests fail with ErrNERPoolClosed.
+func (p *NERPool) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+
+		p.mu.Lock()
+		workers := make([]*nerWorker, 0, len(p.workers))
+		for worker := range p.workers {
+			workers = append(workers, worker)
+		}
+		p.mu.Unlock()
+
+		for _, worker := range workers {
+			p.retire(worker)
+		}
+	})
+	return nil
+}
+
+// spawn starts a new worker process and waits for its readiness handshake.
+func (p *NERPool) spawn() (*nerWorker, error) {
+	cmd := exec.Command(p.config.Command, p.config.Args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start NER worker: %v", err)
+	}
+
+	worker := &nerWorker{
+		cmd:       cmd,
+		stdin:     stdin,
+		responses: make(chan nerResponse),
+		exited:    make(chan struct{}),
+		quit:      make(chan struct{}),
+	}
+	go worker.read(stdout)
+
+	p.mu.Lock()
+	p.workers[worker] = true
+	p.mu.Unlock()
+
+	// Wait for the model to load
+	timer := time.NewTimer(p.config.StartTimeout)
+	defer timer.Stop()
+
+	select {
+	case resp, ok := <-worker.responses:
+		if !ok {
+			p.retire(worker)
+			return nil, fmt.Errorf("NER worker exited during startup: %v", worker.exitErr())
+		}
+		if !resp.Ready {
+			p.retire(worker)
+			return nil, fmt.Errorf("NER worker sent unexpected handshake")
+		}
+		p.mu.Lock()
+		p.model = resp.Model
+		if resp.Version != "" {
+			p.model += "@" + resp.Version
+		}
+		p.mu.Unlock()
+		return worker, nil
+	case <-timer.C:
+		p.retire(worker)
+		return nil, fmt.Errorf("NER worker did not become ready within %s", p.config.StartTimeout)
+	}
+}
+
+// retire kills a worker and forgets about it.
+func (p *NERPool) retire(worker *nerWorker) {
+	p.mu.Lock()
+	delete(p.workers, worker)
+	p.mu.Unlock()
+
+	worker.stop.Do(func() {
+		close(worker.quit)
+		worker.stdin.Close()
+		if worker.alive() {
+			worker.cmd.Process.Kill()
+		}
+	})
+}
+
+// read decodes response lines from the worker until its stdout is closed,
+// then reaps the process.
+func (w *nerWorker) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var resp nerResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			// Ignore anything on stdout that is not part of the protocol
+			continue
+		}
+		select {
+		case w.responses <- resp:
+		case <-w.quit:
+		}
+	}
+
+	w.cmd.Wait()
+	close(w.exited)
+	close(w.responses)
+}
+
+// alive reports whether the worker process is still running.
+func (w *nerWorker) alive() bool {
+	select {
+	case <-w.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// exitErr describes how the worker process terminated.
+func (w *nerWorker) exitErr() string {
+	if w.alive() {
+		return "still running"
+	}
+	return w.cmd.ProcessState.String()
+}
@@ -0,0 +1,86 @@
+package wiki
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by every request of a Client. Tokens are added
+// at a fixed rate up to a burst size; each request takes one, waiting if none is left.
+// The limiter can also be paused, e.g. when the server asks us to back off.
+type RateLimiter struct {
+	mu          sync.Mutex
+	rate        float64   // Tokens added per second
+	burst       float64   // Maximum number of tokens
+	tokens      float64   // Tokens currently available
+	last        time.Time // When tokens was last updated
+	pausedUntil time.Time // No tokens are handed out before this time
+}
+
+// NewRateLimiter returns a limiter allowing requestsPerSecond requests per second on
+// average and bursts of up to burst requests. A non-positive rate disables limiting.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request may be sent or ctx is cancelled.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Pause stops handing out tokens for d, on top of any pause already in effect.
+func (l *RateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// reserve takes a token if one is available and returns 0, or returns how long to
+// wait before trying again.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.rate <= 0 {
+		return 0
+	}
+
+	// Refill the bucket for the time that has passed
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
@@ -0,0 +1,349 @@
+package wiki
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// GameRecord is a game and the entities extracted for it, ready to be stored.
+type GameRecord struct {
+	PageID      int      // Wikipedia page ID; 0 when unknown, in which case the title identifies the game
+	Title       string   // Title of the game's article
+	Summary     string   // Plain-text summary of the article
+	ReleaseDate string   // First release date, if known
+	RevisionID  int64    // Wikipedia revision the record was extracted from; 0 when unknown
+	Aliases     []string // Other titles of the game, e.g. redirects to its page
+	Entities    []Entity // Extracted developers, platforms, genres, ...
+}
+
+// entityTable describes where entities with a given label are stored.
+type entityTable struct {
+	table      string // Lookup table holding each name once
+	joinTable  string // Table linking games to the lookup table
+	joinColumn string // Column of the join table referencing the lookup table
+}
+
+// entityTables maps entity labels to the tables they are stored in. Entities with
+// other labels are not stored in PostgreSQL.
+var entityTables = map[string]entityTable{
+	"Developer": {"Developers", "GameDevelopers", "developer_id"},
+	"Publisher": {"Publishers", "GamePublishers", "publisher_id"},
+	"Platform":  {"Platforms", "GamePlatforms", "platform_id"},
+	"Genre":     {"Genres", "GameGenres", "genre_id"},
+	"Series":    {"Series", "GameSeries", "series_id"},
+}
+
+// entityTableOrder fixes the order join tables are rewritten in.
+var entityTableOrder = []string{"Developer", "Publisher", "Platform", "Genre", "Series"}
+
+// ErrEmptyTitle is returned when a game without a title is stored.
+var ErrEmptyTitle = errors.New("game title must not be empty")
+
+// ErrEntityNameTaken is returned when an entity cannot be stored because its name,
+// and its page title, already belong to an entity linking to another page.
+var ErrEntityNameTaken = errors.New("entity name belongs to another page")
+
+// ErrGameNotFound is returned when no game is known by a title.
+var ErrGameNotFound = errors.New("game not found")
+
+// UpsertGame stores a game together with its developers, publishers, platforms, genres and
+// series in a single transaction and returns the game's ID.
+//
+// The game is keyed on its Wikipedia page ID (or on its title when the page ID is
+// unknown), so storing the same game again updates it instead of duplicating it.
+// Its title and aliases are recorded in GameAliases, where earlier titles are kept
+// so the game can still be found by them after a page move.
+// The game's previous entity links are replaced by the ones in the record, so
+// either the whole new state is written or nothing is.
+func UpsertGame(ctx context.Context, db *sql.DB, game GameRecord) (int, error) {
+	gameID, _, err := UpsertGameEntities(ctx, db, game)
+	return gameID, err
+}
+
+// UpsertGameEntities is UpsertGame, also returning the game's entities as they were
+// stored: named after the row they were linked to, which may be another spelling or
+// the page title of the entity (see linkEntity), and with the page that row links to.
+// Entities that resolved to the same row are returned once, with the most confident
+// provenance; entities that are not stored are left out.
+func UpsertGameEntities(ctx context.Context, db *sql.DB, game GameRecord) (int, []Entity, error) {
+	if game.Title == "" {
+		return 0, nil, ErrEmptyTitle
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback() // No-op once the transaction is committed
+
+	gameID, err := upsertGameRow(ctx, tx, game)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to upsert game: %w", err)
+	}
+	if err := recordAliases(ctx, tx, gameID, append([]string{game.Title}, game.Aliases...)); err != nil {
+		return 0, nil, fmt.Errorf("failed to record aliases: %w", err)
+	}
+
+	// Drop the previous links so the new entity set replaces them
+	for _, label := range entityTableOrder {
+		table := entityTables[label]
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table.joinTable+` WHERE game_id = $1`, gameID); err != nil {
+			return 0, nil, fmt.Errorf("failed to clear %s: %w", table.joinTable, err)
+		}
+	}
+
+	// Link the entities in a fixed order so concurrent upserts lock lookup rows in the same order
+	entities := append([]Entity(nil), game.Entities...)
+	sort.Slice(entities, func(i, j int) bool {
+		if entities[i].Label != entities[j].Label {
+			return entities[i].Label < entities[j].Label
+		}
+		return entities[i].Text < entities[j].Text
+	})
+	var stored []Entity
+	index := make(map[[2]string]int) // Label and stored name -> position in stored
+	for _, entity := range entities {
+		table, ok := entityTables[entity.Label]
+		if !ok || entity.Text == "" {
+			continue
+		}
+		linked, err := linkEntity(ctx, tx, table, gameID, entity)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to insert entity (%s): %w", entity.Text, err)
+		}
+		key := [2]string{linked.Label, linked.Text}
+		if i, ok := index[key]; !ok {
+			index[key] = len(stored)
+			stored = append(stored, linked)
+		} else if linked.Confidence > stored[i].Confidence {
+			stored[i] = linked
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+	return gameID, stored, nil
+}
+
+// InsertGameWithEntities inserts a game and its related entities (Developers, Platforms, Genres)
+// into the database. It is InsertGameWithEntitiesWithContext without cancellation.
+func InsertGameWithEntities(db *sql.DB, title, summary, releaseDate string, entities []Entity) error {
+	return InsertGameWithEntitiesWithContext(context.Background(), db, title, summary, releaseDate, entities)
+}
+
+// InsertGameWithEntitiesWithContext inserts or updates a game identified by its title together with
+// its related entities (Developers, Platforms, Genres). See UpsertGame.
+func InsertGameWithEntitiesWithContext(ctx context.Context, db *sql.DB, title, summary, releaseDate string, entities []Entity) error {
+	_, err := UpsertGame(ctx, db, GameRecord{
+		Title:       title,
+		Summary:     summary,
+		ReleaseDate: releaseDate,
+		Entities:    entities,
+	})
+	return err
+}
+
+// upsertGameRow inserts or updates the Games row and returns its ID.
+func upsertGameRow(ctx context.Context, tx *sql.Tx, game GameRecord) (int, error) {
+	var gameID int
+
+	if game.PageID == 0 {
+		// Update the game currently holding the title, preferring one also stored without a page ID
+		query := `UPDATE Games SET summary = $2, release_date = $3, revision_id = $4
+			WHERE id = (SELECT id FROM Games WHERE title = $1 ORDER BY page_id NULLS FIRST LIMIT 1)
+			RETURNING id`
+		err := tx.QueryRowContext(ctx, query, game.Title, game.Summary, game.ReleaseDate, nullRevision(game.RevisionID)).Scan(&gameID)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return gameID, err
+		}
+
+		// Only titles of games without a page ID are unique (see games_title_key)
+		query = `INSERT INTO Games (title, summary, release_date, revision_id) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (title) WHERE page_id IS NULL DO UPDATE SET summary = EXCLUDED.summary,
+				release_date = EXCLUDED.release_date, revision_id = EXCLUDED.revision_id
+			RETURNING id`
+		err = tx.QueryRowContext(ctx, query, game.Title, game.Summary, game.ReleaseDate, nullRevision(game.RevisionID)).Scan(&gameID)
+		return gameID, err
+	}
+
+	// Adopt a row stored before page IDs were recorded, unless the page already has its own row
+	_, err := tx.ExecContext(ctx, `UPDATE Games SET page_id = $1 WHERE title = $2 AND page_id IS NULL
+		AND NOT EXISTS (SELECT 1 FROM Games WHERE page_id = $1)`, game.PageID, game.Title)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO Games (page_id, title, summary, release_date, revision_id) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (page_id) DO UPDATE SET title = EXCLUDED.title, summary = EXCLUDED.summary, release_date = EXCLUDED.release_date,
+			revision_id = EXCLUDED.revision_id
+		RETURNING id`
+	err = tx.QueryRowContext(ctx, query, game.PageID, game.Title, game.Summary, game.ReleaseDate, nullRevision(game.RevisionID)).Scan(&gameID)
+	return gameID, err
+}
+
+// nullRevision stores an unknown (0) revision ID as NULL.
+func nullRevision(revisionID int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: revisionID, Valid: revisionID != 0}
+}
+
+// recordAliases points every alias at the game. An alias that belonged to another
+// game (e.g. a redirect that was retargeted) moves to this one.
+func recordAliases(ctx context.Context, tx *sql.Tx, gameID int, aliases []string) error {
+	normalized := make([]string, 0, len(aliases))
+	seen := make(map[string]bool)
+	for _, alias := range aliases {
+		alias = NormalizeTitle(alias)
+		if alias != "" && !seen[alias] {
+			seen[alias] = true
+			normalized = append(normalized, alias)
+		}
+	}
+	// Lock alias rows in a fixed order, like entities
+	sort.Strings(normalized)
+
+	for _, alias := range normalized {
+		_, err := tx.ExecContext(ctx, `INSERT INTO GameAliases (alias, game_id) VALUES ($1, $2)
+			ON CONFLICT (alias) DO UPDATE SET game_id = EXCLUDED.game_id`, alias, gameID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindGameID returns the ID of the game known by title, which may be its current
+// title, an earlier one or a redirect to its page, in any of the spellings that
+// normalize to the same Wikipedia title.
+func FindGameID(ctx context.Context, db *sql.DB, title string) (int, error) {
+	var gameID int
+	err := db.QueryRowContext(ctx, `SELECT game_id FROM GameAliases WHERE alias = $1
+		UNION ALL SELECT id FROM Games WHERE title = $2
+		LIMIT 1`, NormalizeTitle(title), title).Scan(&gameID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: %s", ErrGameNotFound, title)
+	}
+	return gameID, err
+}
+
+// linkEntity makes sure an entity exists in the lookup table and links it to the game,
+// recording how the entity was extracted on the link.
+// An entity linking to a Wikipedia page is the row already linked to that page, if any,
+// whatever its name; otherwise the row with its name, which then records the link.
+// When that name already belongs to another page, the entity is stored under its
+// page's title instead, e.g. "Rare (publisher)" next to "Rare".
+// The entity is returned with the name and page of the row it was linked to.
+func linkEntity(ctx context.Context, tx *sql.Tx, table entityTable, gameID int, entity Entity) (Entity, error) {
+	row, err := entityRow(ctx, tx, table, entity.Text, NormalizeTitle(entity.Link))
+	if err != nil {
+		return entity, err
+	}
+	entity.Text, entity.Link = row.name, row.wikiTitle
+
+	// Two spellings may resolve to the same row; keep the link found with more confidence
+	query := `INSERT INTO ` + table.joinTable + ` AS link
+			(game_id, ` + table.joinColumn + `, confidence, start_offset, end_offset, source, revision_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (game_id, ` + table.joinColumn + `) DO UPDATE SET confidence = EXCLUDED.confidence,
+			start_offset = EXCLUDED.start_offset, end_offset = EXCLUDED.end_offset,
+			source = EXCLUDED.source, revision_id = EXCLUDED.revision_id
+		WHERE COALESCE(EXCLUDED.confidence, 0) > COALESCE(link.confidence, 0)`
+	_, err = tx.ExecContext(ctx, query, gameID, row.id,
+		sql.NullFloat64{Float64: entity.Confidence, Valid: entity.Confidence != 0},
+		sql.NullInt32{Int32: int32(entity.Start), Valid: entity.End != 0},
+		sql.NullInt32{Int32: int32(entity.End), Valid: entity.End != 0},
+		sql.NullString{String: entity.Source, Valid: entity.Source != ""},
+		nullRevision(entity.RevisionID))
+	return entity, err
+}
+
+// lookupRow is a row of an entity lookup table.
+type lookupRow struct {
+	id        int
+	name      string
+	wikiTitle string // Empty when unlinked
+}
+
+// entityRow returns the lookup row of the entity with the given name and linked page
+// title (empty when unlinked), inserting it if needed; see linkEntity.
+func entityRow(ctx context.Context, tx *sql.Tx, table entityTable, name, link string) (lookupRow, error) {
+	row := lookupRow{name: name, wikiTitle: link}
+	if link != "" {
+		err := tx.QueryRowContext(ctx, `SELECT id, name FROM `+table.table+` WHERE wiki_title = $1`, link).Scan(&row.id, &row.name)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return row, err
+		}
+	}
+
+	// DO NOTHING on any conflict, as a failed statement would abort the transaction
+	wikiTitle := sql.NullString{String: link, Valid: link != ""}
+	err := tx.QueryRowContext(ctx, `INSERT INTO `+table.table+` (name, wiki_title) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING RETURNING id`, name, wikiTitle).Scan(&row.id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return row, err
+	}
+
+	// A concurrent upsert linked the page first, or the name is taken
+	if link != "" {
+		err := tx.QueryRowContext(ctx, `SELECT id, name FROM `+table.table+` WHERE wiki_title = $1`, link).Scan(&row.id, &row.name)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return row, err
+		}
+	}
+	var existing sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT id, wiki_title FROM `+table.table+` WHERE name = $1 FOR UPDATE`, name).Scan(&row.id, &existing)
+	if err != nil {
+		return row, err
+	}
+	switch {
+	case link == "" || existing.String == link:
+		row.wikiTitle = existing.String
+		return row, nil
+	case !existing.Valid:
+		// The name was only seen unlinked so far; it now records its page
+		_, err := tx.ExecContext(ctx, `UPDATE `+table.table+` SET wiki_title = $1 WHERE id = $2`, link, row.id)
+		return row, err
+	case name != link:
+		// The name belongs to another page; tell this one apart by its page title
+		return entityRow(ctx, tx, table, link, link)
+	default:
+		return row, fmt.Errorf("%w: %s links to %s and %s", ErrEntityNameTaken, name, existing.String, link)
+	}
+}
+
+// GameEntities returns the developers, publishers, platforms, genres and series stored for a game with
+// the provenance of each link, most confident first within each label. Links with
+// a confidence below minConfidence are left out; links of unknown confidence are
+// only returned when minConfidence is 0.
+func GameEntities(ctx context.Context, db *sql.DB, gameID int, minConfidence float64) ([]Entity, error) {
+	var entities []Entity
+	for _, label := range entityTableOrder {
+		table := entityTables[label]
+		rows, err := db.QueryContext(ctx, `SELECT e.name, COALESCE(e.wiki_title, ''), COALESCE(l.confidence, 0),
+				COALESCE(l.start_offset, 0), COALESCE(l.end_offset, 0), COALESCE(l.source, ''), COALESCE(l.revision_id, 0)
+			FROM `+table.joinTable+` l JOIN `+table.table+` e ON e.id = l.`+table.joinColumn+`
+			WHERE l.game_id = $1 AND COALESCE(l.confidence, 0) >= $2
+			ORDER BY l.confidence DESC NULLS LAST, e.name`, gameID, minConfidence)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %v", table.joinTable, err)
+		}
+		for rows.Next() {
+			entity := Entity{Label: label}
+			err := rows.Scan(&entity.Text, &entity.Link, &entity.Confidence, &entity.Start, &entity.End, &entity.Source, &entity.RevisionID)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			entities = append(entities, entity)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rows.Close()
+	}
+	return entities, nil
+}
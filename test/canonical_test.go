@@ -0,0 +1,138 @@
+package test
+
+import (
+	"context"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"testing"
+)
+
+// Test the normalization rules names are compared by
+func TestNormalizeEntityName(t *testing.T) {
+	tests := map[string]string{
+		"Nintendo Co., Ltd.":             "nintendo",
+		"Nintendo":                       "nintendo",
+		"Square Enix Holdings Co Ltd":    "square enix holdings",
+		"Action-adventure":               "action adventure",
+		"Nintendo R&D4":                  "nintendo r and d4",
+		"Xbox Series X/S":                "xbox series x s",
+		"  Naughty   Dog, Inc. ":         "naughty dog",
+		"Co.":                            "co",
+		"Sony Interactive Entertainment": "sony interactive entertainment",
+	}
+	for name, expected := range tests {
+		if key := wiki.NormalizeEntityName(name); key != expected {
+			t.Errorf("NormalizeEntityName(%q) = %q, expected %q", name, key, expected)
+		}
+	}
+}
+
+// Test that spellings of the same entity map to one canonical name
+func TestCanonicalizer(t *testing.T) {
+	names := wiki.NewCanonicalizer(wiki.DefaultEntityAliases())
+	names.Add(wiki.Entity{Text: "Rare", Label: "Developer", Link: "Rare (company)"})
+
+	tests := []struct {
+		entity   wiki.Entity
+		expected string
+	}{
+		{wiki.Entity{Text: "Nintendo Co., Ltd.", Label: "Developer"}, "Nintendo"},
+		{wiki.Entity{Text: "Nintendo EAD", Label: "Developer"}, "Nintendo"},
+		{wiki.Entity{Text: "PS4", Label: "Platform"}, "PlayStation 4"},
+		{wiki.Entity{Text: "PlayStation 4", Label: "Platform"}, "PlayStation 4"},
+		{wiki.Entity{Text: "Namco Bandai Games", Label: "Developer"}, "Bandai Namco"},
+		// Predecessors of merged companies are not their successor
+		{wiki.Entity{Text: "Square", Label: "Developer"}, "Square"},
+		{wiki.Entity{Text: "Namco", Label: "Developer"}, "Namco"},
+		// The linked page identifies the entity whatever the text says
+		{wiki.Entity{Text: "Rareware", Label: "Developer", Link: "Rare_(company)"}, "Rare"},
+		{wiki.Entity{Text: "Sony", Label: "Platform", Link: "PlayStation 4"}, "PlayStation 4"},
+		// Names seen first become canonical for later spellings
+		{wiki.Entity{Text: "Retro Studios", Label: "Developer"}, "Retro Studios"},
+		{wiki.Entity{Text: "Retro Studios, Inc.", Label: "Developer"}, "Retro Studios"},
+		{wiki.Entity{Text: "Action-adventure", Label: "Genre", Link: "Action-adventure game"}, "Action-adventure"},
+		{wiki.Entity{Text: "action adventure", Label: "Genre"}, "Action-adventure"},
+		// Labels are canonicalized separately
+		{wiki.Entity{Text: "PS4", Label: "Genre"}, "PS4"},
+		// Publishers share the names and aliases of developers
+		{wiki.Entity{Text: "Nintendo EPD", Label: "Publisher"}, "Nintendo"},
+		{wiki.Entity{Text: "Rare Ltd", Label: "Publisher"}, "Rare"},
+	}
+	for _, test := range tests {
+		if canonical := names.Canonical(test.entity); canonical.Text != test.expected {
+			t.Errorf("Canonical(%+v) = %q, expected %q", test.entity, canonical.Text, test.expected)
+		}
+	}
+
+	// Canonicalizing a game's entities drops the duplicates it creates
+	entities := names.CanonicalizeAll([]wiki.Entity{
+		{Text: "PS4", Label: "Platform"},
+		{Text: "PlayStation 4", Label: "Platform", Link: "PlayStation 4"},
+		{Text: "Nintendo", Label: "Developer"},
+	})
+	if len(entities) != 2 || entities[0].Text != "PlayStation 4" || entities[0].Link != "PlayStation 4" {
+		t.Fatalf("Expected PlayStation 4 once with its link, and Nintendo, got %+v", entities)
+	}
+}
+
+// Test that the text of a piped link is not learned as a name, and that link targets
+// win over names seen before without a link
+func TestCanonicalizer_LinkText(t *testing.T) {
+	names := wiki.NewCanonicalizer(nil)
+
+	tests := []struct {
+		entity   wiki.Entity
+		expected string
+	}{
+		{wiki.Entity{Text: "Sony", Label: "Platform", Link: "PlayStation 4"}, "PlayStation 4"},
+		{wiki.Entity{Text: "Sony", Label: "Platform"}, "Sony"},
+		{wiki.Entity{Text: "PlayStation", Label: "Platform"}, "PlayStation"},
+		{wiki.Entity{Text: "PlayStation", Label: "Platform", Link: "PlayStation 4"}, "PlayStation 4"},
+		{wiki.Entity{Text: "PS5", Label: "Platform", Link: "PlayStation 5"}, "PlayStation 5"},
+		{wiki.Entity{Text: "Playstation 5", Label: "Platform"}, "PlayStation 5"},
+		{wiki.Entity{Text: "Rareware", Label: "Developer", Link: "Rare (company)"}, "Rare"},
+		{wiki.Entity{Text: "Rare Ltd.", Label: "Developer"}, "Rare"},
+	}
+	for _, test := range tests {
+		if canonical := names.Canonical(test.entity); canonical.Text != test.expected {
+			t.Errorf("Canonical(%+v) = %q, expected %q", test.entity, canonical.Text, test.expected)
+		}
+	}
+}
+
+// Test that entities linking to the same Wikipedia page are stored as one row
+func TestUpsertGame_EntityLinks(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	defer conn.Exec(`DELETE FROM Developers WHERE wiki_title = 'Link Test Studio (company)'`)
+	defer conn.Exec(`DELETE FROM Games WHERE page_id IN (990601, 990602)`)
+
+	games := []wiki.GameRecord{
+		{PageID: 990601, Title: "Link Test Game 1", Entities: []wiki.Entity{{Text: "Link Test Studio", Label: "Developer", Link: "Link Test Studio (company)"}}},
+		{PageID: 990602, Title: "Link Test Game 2", Entities: []wiki.Entity{{Text: "LTS", Label: "Developer", Link: "Link_Test_Studio_(company)"}}},
+	}
+	for _, game := range games {
+		if _, err := wiki.UpsertGame(ctx, conn, game); err != nil {
+			t.Fatalf("Failed to upsert %s: %v", game.Title, err)
+		}
+	}
+
+	var rows, links int
+	err = conn.QueryRow(`SELECT count(DISTINCT d.id), count(*) FROM Developers d
+		JOIN GameDevelopers gd ON gd.developer_id = d.id
+		WHERE d.wiki_title = 'Link Test Studio (company)'`).Scan(&rows, &links)
+	if err != nil {
+		t.Fatalf("Failed to count developers: %v", err)
+	}
+	if rows != 1 || links != 2 {
+		t.Fatalf("Expected one developer linked to both games, got %d rows and %d links", rows, links)
+	}
+}
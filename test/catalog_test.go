@@ -0,0 +1,239 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gamenet/internal/pkg/api"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeCatalog serves a fixed set of games and records the last filter it was asked for.
+type fakeCatalog struct {
+	games      []db.GameSummary
+	lastFilter db.GameFilter
+}
+
+func (c *fakeCatalog) ListGames(ctx context.Context, filter db.GameFilter) (db.GameList, error) {
+	c.lastFilter = filter
+	limit := filter.Limit
+	if limit == 0 {
+		limit = db.DefaultGameLimit
+	}
+	list := db.GameList{Games: []db.GameSummary{}}
+	for _, game := range c.games {
+		if game.ID <= filter.After {
+			continue
+		}
+		if len(list.Games) == limit {
+			list.Next = list.Games[limit-1].ID
+			break
+		}
+		list.Games = append(list.Games, game)
+	}
+	return list, nil
+}
+
+func (c *fakeCatalog) Game(ctx context.Context, id int) (db.GameDetail, error) {
+	for _, game := range c.games {
+		if game.ID == id {
+			return db.GameDetail{GameSummary: game, Developers: []db.EntityLink{{ID: 1, Name: "Nintendo"}}}, nil
+		}
+	}
+	return db.GameDetail{}, fmt.Errorf("game %d: %w", id, db.ErrNotFound)
+}
+
+func (c *fakeCatalog) Entity(ctx context.Context, kind string, id int) (db.EntityDetail, error) {
+	if id != 1 {
+		return db.EntityDetail{}, fmt.Errorf("%s %d: %w", kind, id, db.ErrNotFound)
+	}
+	return db.EntityDetail{ID: 1, Kind: kind, Name: "Nintendo", GameCount: len(c.games)}, nil
+}
+
+// getJSON requests path from the server, checks the status code and decodes the body into v.
+func getJSON(t *testing.T, server *api.Server, path string, status int, v interface{}) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if recorder.Code != status {
+		t.Fatalf("GET %s: expected %d, got %d: %s", path, status, recorder.Code, recorder.Body)
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), v); err != nil {
+		t.Fatalf("GET %s: invalid JSON: %v", path, err)
+	}
+}
+
+// newCatalogServer returns a server with the catalog API over five games.
+func newCatalogServer() (*api.Server, *fakeCatalog) {
+	catalog := &fakeCatalog{}
+	for id := 1; id <= 5; id++ {
+		catalog.games = append(catalog.games, db.GameSummary{ID: id, Title: fmt.Sprintf("Game %d", id)})
+	}
+	server := api.NewServer(":0")
+	server.HandleCatalog(catalog)
+	return server, catalog
+}
+
+// Test paging through /games with cursors and passing the filters on
+func TestCatalogAPI_ListGames(t *testing.T) {
+	server, catalog := newCatalogServer()
+
+	var titles []string
+	path := "/games?limit=2&developer=Nintendo&platform=Wii&genre=Platform&year=2007"
+	for pages := 0; path != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("Expected paging to end")
+		}
+		var page api.GameListResponse
+		getJSON(t, server, path, http.StatusOK, &page)
+		for _, game := range page.Games {
+			titles = append(titles, game.Title)
+		}
+		path = ""
+		if page.NextCursor != "" {
+			path = "/games?limit=2&developer=Nintendo&platform=Wii&genre=Platform&year=2007&cursor=" + page.NextCursor
+		}
+	}
+	if len(titles) != 5 || titles[4] != "Game 5" {
+		t.Fatalf("Expected all five games once, got %v", titles)
+	}
+
+	filter := catalog.lastFilter
+	if filter.Developer != "Nintendo" || filter.Platform != "Wii" || filter.Genre != "Platform" || filter.Year != 2007 || filter.Limit != 2 {
+		t.Fatalf("Unexpected filter: %+v", filter)
+	}
+
+	var failure map[string]string
+	for _, bad := range []string{"/games?limit=0", "/games?limit=1000", "/games?year=soon", "/games?cursor=nope"} {
+		getJSON(t, server, bad, http.StatusBadRequest, &failure)
+		if failure["error"] == "" {
+			t.Fatalf("Expected an error message for %s", bad)
+		}
+	}
+}
+
+// Test the game and entity endpoints, including missing IDs
+func TestCatalogAPI_Resources(t *testing.T) {
+	server, catalog := newCatalogServer()
+
+	var game db.GameDetail
+	getJSON(t, server, "/games/3", http.StatusOK, &game)
+	if game.Title != "Game 3" || len(game.Developers) != 1 {
+		t.Fatalf("Unexpected game: %+v", game)
+	}
+
+	var entity db.EntityDetail
+	getJSON(t, server, "/platforms/1", http.StatusOK, &entity)
+	if entity.Kind != "platforms" || entity.GameCount != 5 {
+		t.Fatalf("Unexpected entity: %+v", entity)
+	}
+
+	var page api.GameListResponse
+	getJSON(t, server, "/developers/1/games?limit=10", http.StatusOK, &page)
+	if len(page.Games) != 5 || catalog.lastFilter.EntityKind != "developers" || catalog.lastFilter.EntityID != 1 {
+		t.Fatalf("Expected the developer's games, got %+v with filter %+v", page, catalog.lastFilter)
+	}
+	getJSON(t, server, "/publishers/1/games", http.StatusOK, &page)
+	if catalog.lastFilter.EntityKind != "publishers" || catalog.lastFilter.EntityID != 1 {
+		t.Fatalf("Expected the publisher's games, got filter %+v", catalog.lastFilter)
+	}
+
+	var failure map[string]string
+	getJSON(t, server, "/games/42", http.StatusNotFound, &failure)
+	getJSON(t, server, "/genres/2/games", http.StatusNotFound, &failure)
+	getJSON(t, server, "/games/abc", http.StatusBadRequest, &failure)
+}
+
+// Test the PostgreSQL catalog queries
+func TestCatalog_Postgres(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	defer conn.Exec(`DELETE FROM Developers WHERE name = 'Catalog Test Studio'`)
+	defer conn.Exec(`DELETE FROM Games WHERE page_id BETWEEN 990801 AND 990803`)
+
+	var gameIDs []int
+	for i := 1; i <= 3; i++ {
+		id, err := wiki.UpsertGame(ctx, conn, wiki.GameRecord{
+			PageID:      990800 + i,
+			Title:       fmt.Sprintf("Catalog Test Game %d", i),
+			ReleaseDate: fmt.Sprintf("March %d, 200%d", i, i),
+			Entities:    []wiki.Entity{{Text: "Catalog Test Studio", Label: "Developer", Confidence: 1, Source: "infobox:developer"}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to upsert game: %v", err)
+		}
+		gameIDs = append(gameIDs, id)
+	}
+
+	catalog := db.NewCatalog(conn)
+	first, err := catalog.ListGames(ctx, db.GameFilter{Developer: "catalog test studio", Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to list games: %v", err)
+	}
+	if len(first.Games) != 2 || first.Next != gameIDs[1] {
+		t.Fatalf("Expected the first two games and a cursor, got %+v", first)
+	}
+	rest, err := catalog.ListGames(ctx, db.GameFilter{Developer: "Catalog Test Studio", After: first.Next})
+	if err != nil {
+		t.Fatalf("Failed to list games: %v", err)
+	}
+	if len(rest.Games) != 1 || rest.Next != 0 || rest.Games[0].ReleaseYear != 2003 {
+		t.Fatalf("Expected the last game released in 2003, got %+v", rest)
+	}
+
+	byYear, err := catalog.ListGames(ctx, db.GameFilter{Developer: "Catalog Test Studio", Year: 2002})
+	if err != nil {
+		t.Fatalf("Failed to list games: %v", err)
+	}
+	if len(byYear.Games) != 1 || byYear.Games[0].ID != gameIDs[1] {
+		t.Fatalf("Expected the game of 2002, got %+v", byYear)
+	}
+
+	game, err := catalog.Game(ctx, gameIDs[0])
+	if err != nil {
+		t.Fatalf("Failed to read game: %v", err)
+	}
+	if len(game.Developers) != 1 || game.Developers[0].Source != "infobox:developer" {
+		t.Fatalf("Expected the developer with its source, got %+v", game)
+	}
+
+	developer, err := catalog.Entity(ctx, "developers", game.Developers[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to read developer: %v", err)
+	}
+	if developer.GameCount != 3 {
+		t.Fatalf("Expected 3 games, got %+v", developer)
+	}
+
+	others, err := catalog.ListGames(ctx, db.GameFilter{EntityKind: "developers", EntityID: developer.ID, Exclude: gameIDs[0]})
+	if err != nil {
+		t.Fatalf("Failed to list the developer's games: %v", err)
+	}
+	if len(others.Games) != 2 || others.Games[0].ID != gameIDs[1] {
+		t.Fatalf("Expected the developer's other two games, got %+v", others)
+	}
+	// The games have no platforms, so none shares one with the first
+	shared, err := catalog.ListGames(ctx, db.GameFilter{EntityKind: "developers", EntityID: developer.ID, SharePlatformWith: gameIDs[0]})
+	if err != nil {
+		t.Fatalf("Failed to list games sharing a platform: %v", err)
+	}
+	if len(shared.Games) != 0 {
+		t.Fatalf("Expected no games sharing a platform, got %+v", shared)
+	}
+	if _, err := catalog.Game(ctx, -1); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+}
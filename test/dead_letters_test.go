@@ -0,0 +1,127 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"github.com/lib/pq"
+	"testing"
+)
+
+// Test recording a failure twice, listing it with its payload and resolving it
+func TestDeadLetters(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	defer conn.Exec(`DELETE FROM dead_letters WHERE page_id IN (990301, 990302, 990303)`)
+
+	page := wiki.Page{PageID: 990301, Title: "Dead Letter Game", Extract: "A game.", Wikitext: "{{Infobox video game}}",
+		Aliases: []string{"Dead Letter Game (redirect)"}}
+	timeout := fmt.Errorf("%w: request 1 took longer than 1m0s", wiki.ErrNERTimeout)
+	for i := 0; i < 2; i++ {
+		if err := db.RecordDeadLetter(ctx, conn, db.StageExtract, page, timeout, 0); err != nil {
+			t.Fatalf("Failed to record dead letter: %v", err)
+		}
+	}
+	game := wiki.GameRecord{PageID: 990302, Title: "Dead Letter Store", Entities: []wiki.Entity{{Text: "Studio", Label: "Developer"}}}
+	if err := db.RecordDeadLetter(ctx, conn, db.StageStore, game, errors.New("connection reset"), 0); err != nil {
+		t.Fatalf("Failed to record dead letter: %v", err)
+	}
+
+	unwritten := wiki.GameRecord{PageID: 990303, Title: "Dead Letter Graph"}
+	if err := db.RecordDeadLetter(ctx, conn, db.StageGraph, unwritten, errors.New("connection refused"), 0); err != nil {
+		t.Fatalf("Failed to record graph dead letter: %v", err)
+	}
+
+	letters, err := db.ListDeadLetters(ctx, conn, db.DeadLetterFilter{ErrorClass: "ner_timeout"})
+	if err != nil {
+		t.Fatalf("Failed to list dead letters: %v", err)
+	}
+	var found *db.DeadLetter
+	for i := range letters {
+		if letters[i].PageID == page.PageID {
+			found = &letters[i]
+		}
+	}
+	if found == nil {
+		t.Fatalf("Expected the NER timeout to be listed, got %v", letters)
+	}
+	if found.Attempts != 2 || found.Stage != db.StageExtract {
+		t.Fatalf("Expected 2 attempts in the extract stage, got %d in %s", found.Attempts, found.Stage)
+	}
+	if found.Page == nil || found.Page.Wikitext != page.Wikitext || len(found.Page.Aliases) != 1 || found.Game != nil {
+		t.Fatalf("Expected the page and its wikitext as payload, got %+v", found.Page)
+	}
+
+	// The stored game keeps its entities for a replay
+	letters, err = db.ListDeadLetters(ctx, conn, db.DeadLetterFilter{Stage: db.StageStore})
+	if err != nil {
+		t.Fatalf("Failed to list dead letters: %v", err)
+	}
+	replayable := false
+	for _, letter := range letters {
+		if letter.PageID == game.PageID && letter.Game != nil && len(letter.Game.Entities) == 1 {
+			replayable = true
+		}
+	}
+	if !replayable {
+		t.Fatalf("Expected the store failure with its game, got %v", letters)
+	}
+
+	// Storing a game again leaves its graph dead letter alone
+	if err := db.ResolveDeadLetters(ctx, conn, unwritten.PageID, db.StageStore); err != nil {
+		t.Fatalf("Failed to resolve dead letters: %v", err)
+	}
+	letters, err = db.ListDeadLetters(ctx, conn, db.DeadLetterFilter{Stage: db.StageGraph})
+	if err != nil {
+		t.Fatalf("Failed to list dead letters: %v", err)
+	}
+	pending := false
+	for _, letter := range letters {
+		if letter.PageID == unwritten.PageID && letter.Game != nil {
+			pending = true
+		}
+	}
+	if !pending {
+		t.Fatalf("Expected the graph failure with its game, got %v", letters)
+	}
+
+	// A page that makes it through the pipeline drops its dead letters
+	if err := db.ResolveDeadLetters(ctx, conn, page.PageID, ""); err != nil {
+		t.Fatalf("Failed to resolve dead letters: %v", err)
+	}
+	purged, err := db.PurgeDeadLetters(ctx, conn, db.DeadLetterFilter{IDs: []int64{found.ID}})
+	if err != nil || purged != 0 {
+		t.Fatalf("Expected the resolved letter to be gone, purged %d (%v)", purged, err)
+	}
+}
+
+// Test that errors are sorted into the classes dead letters are grouped by
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{fmt.Errorf("%w: request 3 took longer than 1s", wiki.ErrNERTimeout), "ner_timeout"},
+		{fmt.Errorf("%w while processing request 4: exit status 1", wiki.ErrNERWorkerExited), "ner_crash"},
+		{fmt.Errorf("%w: model not loaded", wiki.ErrNERFailed), "ner_error"},
+		{wiki.ErrEmptyTitle, "invalid_record"},
+		{fmt.Errorf("failed to upsert game: %w", &pq.Error{Code: "23505"}), "postgres_unique_violation"},
+		{fmt.Errorf("store: %w", context.DeadlineExceeded), "deadline_exceeded"},
+		{errors.New("something else"), "unknown"},
+	}
+	for _, test := range tests {
+		if class := db.ErrorClass(test.err); class != test.expected {
+			t.Errorf("ErrorClass(%v) = %s, expected %s", test.err, class, test.expected)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+	"gamenet/internal/pkg/wiki"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that the gazetteer finds whole-word, case-insensitive, leftmost-longest matches
+func TestGazetteer_Extract(t *testing.T) {
+	gazetteer := wiki.NewGazetteer([]wiki.GazetteerEntry{
+		{Name: "Nintendo", Label: "Developer"},
+		{Name: "Nintendo Switch", Label: "Platform"},
+		{Name: "Switch", Label: "Platform"},
+		{Name: "Action", Label: "Genre"},
+		{Name: "Action-adventure", Label: "Genre"},
+		{Name: "Rare", Label: "Developer"},
+	})
+
+	text := "Nintendo developed the action-adventure game on the nintendo switch. It is rarely compared to Rare's work."
+	entities, err := gazetteer.Extract(context.Background(), text)
+	if err != nil {
+		t.Fatalf("Failed to extract entities: %v", err)
+	}
+
+	expected := []wiki.Entity{
+		{Text: "Nintendo", Label: "Developer", Confidence: 0.9, Start: 0, End: 8, Source: "gazetteer"},
+		{Text: "Action-adventure", Label: "Genre", Confidence: 0.9, Start: 23, End: 39, Source: "gazetteer"},
+		{Text: "Nintendo Switch", Label: "Platform", Confidence: 0.9, Start: 52, End: 67, Source: "gazetteer"},
+		{Text: "Rare", Label: "Developer", Confidence: 0.9, Start: 94, End: 98, Source: "gazetteer"},
+	}
+	if len(entities) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, entities)
+	}
+	for i := range expected {
+		if entities[i] != expected[i] {
+			t.Fatalf("Entity %d: expected %v, got %v", i, expected[i], entities[i])
+		}
+	}
+}
+
+// Test that names of a single word are not found in common words spelled differently
+func TestGazetteer_SingleWordCase(t *testing.T) {
+	gazetteer := wiki.NewGazetteer(wiki.DefaultGazetteerEntries())
+
+	text := "Players collect rare items between racing sections, in a sandbox full of action. It was developed by Rare."
+	entities, err := gazetteer.Extract(context.Background(), text)
+	if err != nil {
+		t.Fatalf("Failed to extract entities: %v", err)
+	}
+	if len(entities) != 1 || entities[0].Text != "Rare" || entities[0].Label != "Developer" || entities[0].Start != 101 {
+		t.Fatalf("Expected only the developer Rare at the end, got %v", entities)
+	}
+}
+
+// Test that overlapping names sharing a suffix are all found via failure links
+func TestGazetteer_SuffixMatches(t *testing.T) {
+	gazetteer := wiki.NewGazetteer([]wiki.GazetteerEntry{
+		{Name: "Game Boy Advance", Label: "Platform"},
+		{Name: "Boy", Label: "Other"},
+		{Name: "Advance Wars", Label: "Game"},
+	})
+
+	entities, err := gazetteer.Extract(context.Background(), "Released for the Game Boy and Advance Wars fans.")
+	if err != nil {
+		t.Fatalf("Failed to extract entities: %v", err)
+	}
+	if len(entities) != 2 || entities[0].Text != "Boy" || entities[1].Text != "Advance Wars" {
+		t.Fatalf("Unexpected entities: %v", entities)
+	}
+}
+
+// Test loading entries from a file and from the built-in seed list
+func TestGazetteer_LoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "names.tsv")
+	content := "# comment\nDeveloper\tMojang Studios\n\nGenre\tSandbox game\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write gazetteer file: %v", err)
+	}
+
+	entries, err := wiki.LoadGazetteerFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load gazetteer file: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "Mojang Studios" || entries[1].Label != "Genre" {
+		t.Fatalf("Unexpected entries: %v", entries)
+	}
+
+	gazetteer := wiki.NewGazetteer(append(entries, wiki.DefaultGazetteerEntries()...))
+	found, err := gazetteer.Extract(context.Background(), "Minecraft is a sandbox game by Mojang Studios for Microsoft Windows.")
+	if err != nil {
+		t.Fatalf("Failed to extract entities: %v", err)
+	}
+
+	labels := map[string]string{}
+	for _, entity := range found {
+		labels[entity.Text] = entity.Label
+	}
+	if labels["Mojang Studios"] != "Developer" || labels["Sandbox game"] != "Genre" || labels["Microsoft Windows"] != "Platform" {
+		t.Fatalf("Unexpected entities: %v", found)
+	}
+}
+
+// Test that a malformed gazetteer file is rejected
+func TestGazetteer_LoadFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.tsv")
+	if err := os.WriteFile(path, []byte("Developer Nintendo\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write gazetteer file: %v", err)
+	}
+	if _, err := wiki.LoadGazetteerFile(path); err == nil {
+		t.Fatal("Expected an error for a line without a tab")
+	}
+}
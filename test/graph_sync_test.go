@@ -0,0 +1,153 @@
+package test
+
+import (
+	"context"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that a sync repairs drift between PostgreSQL and Neo4j and is a no-op when run again
+func TestSyncGraph(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+	if err := db.InitNeo4j(); err != nil {
+		t.Fatalf("Failed to connect to Neo4j: %v", err)
+	}
+	defer db.CloseNeo4j()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	session := db.Neo4jDriver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	defer session.Run(`MATCH (g:Game) WHERE g.page_id IN [990201, 990202] DETACH DELETE g`, nil)
+	defer conn.Exec(`DELETE FROM Games WHERE page_id = 990201`)
+
+	game := wiki.GameRecord{PageID: 990201, Title: "Sync Test Game", Entities: []wiki.Entity{
+		{Text: "Sync Studio", Label: "Developer"},
+		{Text: "Sync Console", Label: "Platform"},
+	}}
+	if _, err := wiki.UpsertGame(ctx, conn, game); err != nil {
+		t.Fatalf("Failed to upsert game: %v", err)
+	}
+
+	// Drift: a stale relationship on the synced game and a node with no PostgreSQL row
+	session.Run(`MERGE (g:Game {page_id: 990201}) SET g.title = "Old Title"
+		MERGE (d:Developer {name: "Stale Studio"}) MERGE (g)-[:DEVELOPED_BY]->(d)`, nil)
+	session.Run(`MERGE (:Game {page_id: 990202, title: "Deleted Game"})`, nil)
+
+	since := time.Now().Add(-time.Minute)
+	report, err := db.SyncGraph(ctx, conn, db.Neo4jDriver, db.GraphSyncOptions{Since: since})
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	t.Log(report)
+	if report.EdgesCreated < 2 || report.EdgesDeleted < 1 || report.NodesDeleted < 1 {
+		t.Fatalf("Expected relationships to be created and deleted, got %+v", report)
+	}
+
+	if count := countGraph(t, session, `MATCH (g:Game {page_id: 990201, title: "Sync Test Game"})-[r]->() RETURN count(r)`); count != 2 {
+		t.Fatalf("Expected 2 relationships after sync, got %d", count)
+	}
+	if count := countGraph(t, session, `MATCH (g:Game {page_id: 990202}) RETURN count(g)`); count != 0 {
+		t.Fatalf("Expected the deleted game's node to be removed, got %d", count)
+	}
+
+	// Nothing left to do on a second run
+	report, err = db.SyncGraph(ctx, conn, db.Neo4jDriver, db.GraphSyncOptions{Since: since})
+	if err != nil {
+		t.Fatalf("Failed to sync again: %v", err)
+	}
+	if report.NodesCreated+report.NodesUpdated+report.EdgesCreated+report.EdgesDeleted != 0 {
+		t.Fatalf("Expected an empty diff, got %+v", report)
+	}
+}
+
+// Test that a game written by a transaction that commits while a sync runs is picked up
+// by the next incremental sync
+func TestSyncGraph_ConcurrentWrite(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+	if err := db.InitNeo4j(); err != nil {
+		t.Fatalf("Failed to connect to Neo4j: %v", err)
+	}
+	defer db.CloseNeo4j()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	session := db.Neo4jDriver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	defer session.Run(`MATCH (g:Game {page_id: 990203}) DETACH DELETE g`, nil)
+	defer conn.Exec(`DELETE FROM Games WHERE page_id = 990203`)
+
+	game := wiki.GameRecord{PageID: 990203, Title: "Sync Race Game", Entities: []wiki.Entity{
+		{Text: "Sync Studio", Label: "Developer"},
+	}}
+	if _, err := wiki.UpsertGame(ctx, conn, game); err != nil {
+		t.Fatalf("Failed to upsert game: %v", err)
+	}
+	first, err := db.SyncGraph(ctx, conn, db.Neo4jDriver, db.GraphSyncOptions{Since: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	// The writer's transaction, and so updated_at, starts before the sync reads PostgreSQL
+	writer, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	defer writer.Rollback()
+	if _, err := writer.Exec(`UPDATE Games SET title = 'Sync Race Game (Remastered)' WHERE page_id = 990203`); err != nil {
+		t.Fatalf("Failed to update game: %v", err)
+	}
+	during, err := db.SyncGraph(ctx, conn, db.Neo4jDriver, db.GraphSyncOptions{Since: first.SyncedAt})
+	if err != nil {
+		t.Fatalf("Failed to sync during the write: %v", err)
+	}
+	if err := writer.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if _, err := db.SyncGraph(ctx, conn, db.Neo4jDriver, db.GraphSyncOptions{Since: during.SyncedAt}); err != nil {
+		t.Fatalf("Failed to sync after the write: %v", err)
+	}
+	if count := countGraph(t, session, `MATCH (g:Game {page_id: 990203, title: "Sync Race Game (Remastered)"}) RETURN count(g)`); count != 1 {
+		t.Fatalf("Expected the title committed during the sync to reach the graph, got %d nodes", count)
+	}
+}
+
+// Test the diff summary printed by gamenet graph sync
+func TestGraphSyncReport_String(t *testing.T) {
+	report := db.GraphSyncReport{
+		Full:           true,
+		GamesScanned:   3,
+		NodesCreated:   2,
+		EdgesCreated:   4,
+		EdgesDeleted:   1,
+		EntitiesByType: map[string]int{"Genre": 1, "Developer": 4},
+	}
+	summary := report.String()
+	for _, want := range []string{"full", "scanned 3 games", "nodes: +2 ~0 -0", "edges: +4 -1", "Developer: 4 changed"} {
+		if !strings.Contains(summary, want) {
+			t.Fatalf("Expected summary to contain %q, got:\n%s", want, summary)
+		}
+	}
+	if strings.Index(summary, "Developer") > strings.Index(summary, "Genre") {
+		t.Fatalf("Expected labels in sorted order, got:\n%s", summary)
+	}
+}
@@ -0,0 +1,138 @@
+package test
+
+import (
+	"errors"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"testing"
+)
+
+// Test writing games to Neo4j and re-writing them with a changed entity set
+func TestGraphWriter(t *testing.T) {
+	if err := db.InitNeo4j(); err != nil {
+		t.Fatalf("Failed to connect to Neo4j: %v", err)
+	}
+	defer db.CloseNeo4j()
+
+	writer := db.NewGraphWriter(db.Neo4jDriver)
+	writer.BatchSize = 2
+	if err := writer.EnsureGraphSchema(); err != nil {
+		t.Fatalf("Failed to create constraints: %v", err)
+	}
+
+	session := db.Neo4jDriver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	defer session.Run(`MATCH (g:Game) WHERE g.page_id IN [990101, 990102] DETACH DELETE g`, nil)
+
+	games := []db.GraphGame{
+		{PageID: 990101, Title: "Graph Test One", Entities: []wiki.Entity{
+			{Text: "Graph Studio", Label: "Developer"},
+			{Text: "Graph Console", Label: "Platform"},
+			{Text: "Puzzle", Label: "Genre"},
+			{Text: "Ignored Person", Label: "Composer"},
+		}},
+		{PageID: 990102, Title: "Graph Test Two", Entities: []wiki.Entity{
+			{Text: "Graph Studio", Label: "Developer"},
+			{Text: "Graph Publisher", Label: "Publisher"},
+		}},
+	}
+	for _, game := range games {
+		if err := writer.Write(game); err != nil {
+			t.Fatalf("Failed to write game: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("Failed to flush games: %v", err)
+	}
+
+	// Both games share a single developer node
+	if count := countGraph(t, session, `MATCH (:Game)-[:DEVELOPED_BY]->(d:Developer {name: "Graph Studio"}) RETURN count(DISTINCT d)`); count != 1 {
+		t.Fatalf("Expected one shared developer node, got %d", count)
+	}
+	if count := countGraph(t, session, `MATCH (g:Game {page_id: 990101})-[r]->() RETURN count(r)`); count != 3 {
+		t.Fatalf("Expected 3 relationships for the first game, got %d", count)
+	}
+
+	// Rewriting a game replaces its relationships instead of adding to them
+	games[0].Entities = []wiki.Entity{{Text: "Graph Studio", Label: "Developer"}}
+	if err := writer.WriteGames(games[:1]); err != nil {
+		t.Fatalf("Failed to rewrite game: %v", err)
+	}
+	if count := countGraph(t, session, `MATCH (g:Game {page_id: 990101})-[r]->() RETURN count(r)`); count != 1 {
+		t.Fatalf("Expected 1 relationship after rewrite, got %d", count)
+	}
+
+	// An entity linking to a page is the node of that page, renamed to the name it was stored under
+	defer session.Run(`MATCH (d:Developer) WHERE d.wiki_title = 'Graph Linked Studio (company)' DETACH DELETE d`, nil)
+	games[0].Entities = []wiki.Entity{{Text: "Graph Linked Studio", Label: "Developer", Link: "Graph Linked Studio (company)"}}
+	games[1].Entities = []wiki.Entity{{Text: "Graph Linked Studio Ltd", Label: "Developer", Link: "Graph Linked Studio (company)"}}
+	for _, game := range games {
+		if err := writer.WriteGames([]db.GraphGame{game}); err != nil {
+			t.Fatalf("Failed to rewrite game: %v", err)
+		}
+	}
+	query := `MATCH (:Game)-[:DEVELOPED_BY]->(d:Developer {wiki_title: "Graph Linked Studio (company)"}) RETURN count(DISTINCT d)`
+	if count := countGraph(t, session, query); count != 1 {
+		t.Fatalf("Expected one node for the linked developer, got %d", count)
+	}
+	if count := countGraph(t, session, `MATCH (d:Developer {name: "Graph Linked Studio Ltd"}) RETURN count(d)`); count != 1 {
+		t.Fatalf("Expected the node to carry the latest stored name, got %d", count)
+	}
+}
+
+// countGraph runs a query returning a single count.
+func countGraph(t *testing.T, session neo4j.Session, query string) int64 {
+	t.Helper()
+
+	result, err := session.Run(query, nil)
+	if err != nil {
+		t.Fatalf("Failed to run %q: %v", query, err)
+	}
+	record, err := result.Single()
+	if err != nil {
+		t.Fatalf("Failed to read result of %q: %v", query, err)
+	}
+	return record.Values[0].(int64)
+}
+
+// Test that a batch Neo4j rejects is returned whole, from Write and from Flush
+func TestGraphWriter_FailedBatch(t *testing.T) {
+	// Nothing listens on port 1, so every write fails without retrying
+	driver, err := neo4j.NewDriver("bolt://127.0.0.1:1", neo4j.NoAuth(), func(config *neo4j.Config) {
+		config.MaxTransactionRetryTime = 0
+	})
+	if err != nil {
+		t.Fatalf("Failed to create driver: %v", err)
+	}
+	defer driver.Close()
+
+	writer := db.NewGraphWriter(driver)
+	writer.BatchSize = 2
+	games := []db.GraphGame{
+		{PageID: 990111, Title: "Lost Game One"},
+		{PageID: 990112, Title: "Lost Game Two"},
+		{PageID: 990113, Title: "Lost Game Three"},
+	}
+
+	if err := writer.Write(games[0]); err != nil {
+		t.Fatalf("Expected the first game to be buffered, got %v", err)
+	}
+	var batchErr *db.GraphBatchError
+	if err := writer.Write(games[1]); !errors.As(err, &batchErr) {
+		t.Fatalf("Expected a GraphBatchError, got %v", err)
+	}
+	if len(batchErr.Games) != 2 || batchErr.Games[0].PageID != 990111 || batchErr.Games[1].PageID != 990112 {
+		t.Fatalf("Expected both games of the batch, got %+v", batchErr.Games)
+	}
+
+	if err := writer.Write(games[2]); err != nil {
+		t.Fatalf("Expected the third game to be buffered, got %v", err)
+	}
+	if err := writer.Flush(); !errors.As(err, &batchErr) {
+		t.Fatalf("Expected a GraphBatchError from Flush, got %v", err)
+	}
+	if len(batchErr.Games) != 1 || batchErr.Games[0].PageID != 990113 {
+		t.Fatalf("Expected the buffered game, got %+v", batchErr.Games)
+	}
+}
@@ -0,0 +1,344 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gamenet/internal/pkg/api"
+	"gamenet/internal/pkg/db"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// graphCatalog is an in-memory BatchCatalog that counts its batch queries.
+type graphCatalog struct {
+	fakeCatalog
+	links map[string]map[int][]int // Entity IDs by kind and game ID
+
+	mu    sync.Mutex
+	calls map[string][][]int // IDs of every batch query, by method and kind
+}
+
+// newGraphCatalog returns four games: three by developer 1, two of them on platform 1
+// with game 4 by developer 2.
+func newGraphCatalog() *graphCatalog {
+	catalog := &graphCatalog{
+		links: map[string]map[int][]int{
+			"developers": {1: {1}, 2: {1}, 3: {1}, 4: {2}},
+			"platforms":  {1: {1}, 2: {1}, 3: {2}, 4: {1}},
+			"genres":     {},
+		},
+		calls: make(map[string][][]int),
+	}
+	for id := 1; id <= 4; id++ {
+		catalog.games = append(catalog.games, db.GameSummary{ID: id, Title: fmt.Sprintf("Game %d", id)})
+	}
+	return catalog
+}
+
+func (c *graphCatalog) record(name string, ids []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[name] = append(c.calls[name], append([]int(nil), ids...))
+}
+
+func (c *graphCatalog) GamesByID(ctx context.Context, ids []int) (map[int]db.GameDetail, error) {
+	c.record("games", ids)
+	games := make(map[int]db.GameDetail)
+	for _, game := range c.games {
+		for _, id := range ids {
+			if game.ID == id {
+				games[id] = db.GameDetail{GameSummary: game}
+			}
+		}
+	}
+	return games, nil
+}
+
+func (c *graphCatalog) EntitiesOfGames(ctx context.Context, kind string, gameIDs []int) (map[int][]db.EntityLink, error) {
+	c.record("entities:"+kind, gameIDs)
+	links := make(map[int][]db.EntityLink)
+	for _, gameID := range gameIDs {
+		for _, id := range c.links[kind][gameID] {
+			links[gameID] = append(links[gameID], db.EntityLink{ID: id, Name: fmt.Sprintf("%s %d", kind, id)})
+		}
+	}
+	return links, nil
+}
+
+func (c *graphCatalog) ListGames(ctx context.Context, filter db.GameFilter) (db.GameList, error) {
+	c.record("list:"+filter.EntityKind, []int{filter.EntityID})
+	c.lastFilter = filter
+	return c.listGames(filter), nil
+}
+
+func (c *graphCatalog) ListGamesOfEntities(ctx context.Context, kind string, entityIDs []int, filter db.GameFilter) (map[int]db.GameList, error) {
+	c.record("entity-games:"+kind, entityIDs)
+	c.mu.Lock()
+	c.lastFilter = filter
+	c.mu.Unlock()
+	lists := make(map[int]db.GameList)
+	for _, id := range entityIDs {
+		filter.EntityKind, filter.EntityID = kind, id
+		lists[id] = c.listGames(filter)
+	}
+	return lists, nil
+}
+
+// listGames returns the page of games matching filter.
+func (c *graphCatalog) listGames(filter db.GameFilter) db.GameList {
+	limit := filter.Limit
+	if limit == 0 {
+		limit = db.DefaultGameLimit
+	}
+	linked := func(kind string, gameID, entityID int) bool {
+		return entityID == 0 || slices.Contains(c.links[kind][gameID], entityID)
+	}
+	list := db.GameList{Games: []db.GameSummary{}}
+	for _, game := range c.games {
+		if game.ID <= filter.After || game.ID == filter.Exclude ||
+			!linked(filter.EntityKind, game.ID, filter.EntityID) ||
+			!linked("developers", game.ID, filter.DeveloperID) ||
+			!linked("platforms", game.ID, filter.PlatformID) ||
+			!linked("genres", game.ID, filter.GenreID) {
+			continue
+		}
+		if filter.SharePlatformWith != 0 && !slices.ContainsFunc(c.links["platforms"][game.ID], func(id int) bool {
+			return slices.Contains(c.links["platforms"][filter.SharePlatformWith], id)
+		}) {
+			continue
+		}
+		if len(list.Games) == limit {
+			list.Next = list.Games[limit-1].ID
+			break
+		}
+		list.Games = append(list.Games, game)
+	}
+	return list
+}
+
+// postGraphQL runs a GraphQL query and decodes its data into v.
+func postGraphQL(t *testing.T, server *api.Server, query string, v interface{}) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": query})
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+
+	var response struct {
+		Data   json.RawMessage
+		Errors []struct{ Message string }
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if len(response.Errors) > 0 {
+		t.Fatalf("Query failed: %+v", response.Errors)
+	}
+	if err := json.Unmarshal(response.Data, v); err != nil {
+		t.Fatalf("Invalid data: %v", err)
+	}
+}
+
+// Test fetching a game, its developers and their other games on the same platform in one query
+func TestGraphQL_Traversal(t *testing.T) {
+	catalog := newGraphCatalog()
+	server := api.NewServer(":0")
+	server.HandleGraphQL(catalog)
+
+	var data struct {
+		Game struct {
+			Title      string
+			Developers []struct {
+				Name  string
+				Games struct {
+					Games []struct{ ID, Title string }
+				}
+			}
+		}
+	}
+	postGraphQL(t, server, `{
+		game(id: 1) {
+			title
+			developers {
+				name
+				games(exclude: 1, sharePlatformWith: 1) { games { id title } }
+			}
+		}
+	}`, &data)
+
+	if data.Game.Title != "Game 1" || len(data.Game.Developers) != 1 {
+		t.Fatalf("Unexpected game: %+v", data.Game)
+	}
+	games := data.Game.Developers[0].Games.Games
+	if len(games) != 1 || games[0].Title != "Game 2" {
+		t.Fatalf("Expected only Game 2 on the same platform, got %+v", games)
+	}
+	// The filters are applied by the catalog, not by loading every game of the developer
+	filter := catalog.lastFilter
+	if calls := catalog.calls["entity-games:developers"]; len(calls) != 1 || !slices.Equal(calls[0], []int{1}) ||
+		filter.Exclude != 1 || filter.SharePlatformWith != 1 {
+		t.Fatalf("Expected the filters of developer 1 to be passed to the catalog, got %v with %+v", calls, filter)
+	}
+	if calls := catalog.calls["entities:platforms"]; len(calls) != 0 {
+		t.Fatalf("Expected no platform lookups for the filter, got %v", calls)
+	}
+}
+
+// Test that the fields of a list of games, and the games of their developers, are
+// loaded in one batch per kind and level
+func TestGraphQL_Batching(t *testing.T) {
+	catalog := newGraphCatalog()
+	catalog.links["developers"] = map[int][]int{1: {1, 3}, 2: {1}, 3: {1, 5}, 4: {2, 4}}
+	server := api.NewServer(":0")
+	server.HandleGraphQL(catalog)
+
+	var data struct {
+		Games struct {
+			Games []struct {
+				Title      string
+				Developers []struct {
+					Name  string
+					Games struct {
+						Games []struct{ Title string }
+					}
+				}
+				Platforms []struct{ Name string }
+			}
+			NextCursor *string
+		}
+	}
+	postGraphQL(t, server, `{
+		games(first: 10) {
+			games {
+				title
+				developers { name games(first: 5) { games { title } } }
+				platforms { name }
+			}
+			nextCursor
+		}
+	}`, &data)
+
+	if len(data.Games.Games) != 4 || data.Games.NextCursor != nil {
+		t.Fatalf("Expected all four games on one page, got %+v", data.Games)
+	}
+	if name := data.Games.Games[3].Developers[0].Name; name != "developers 2" {
+		t.Fatalf("Expected Game 4 by developer 2, got %q", name)
+	}
+	// Loaders batch the keys requested within a short wait, so a slow scheduler may
+	// split a batch, but never down to one query per game
+	for _, name := range []string{"games", "entities:developers", "entities:platforms"} {
+		calls, queried := catalog.calls[name], 0
+		for _, ids := range calls {
+			queried += len(ids)
+		}
+		if len(calls) == 0 || len(calls) >= 4 || queried != 4 {
+			t.Fatalf("Expected the four games in batched %s queries, got %v", name, calls)
+		}
+	}
+
+	// The games of the five developers are read in batches too, each developer once
+	if games := data.Games.Games[0].Developers[0].Games.Games; len(games) != 3 {
+		t.Fatalf("Expected the three games of developer 1, got %+v", games)
+	}
+	calls, queried := catalog.calls["entity-games:developers"], 0
+	for _, ids := range calls {
+		queried += len(ids)
+	}
+	if len(calls) == 0 || len(calls) >= 5 || queried != 5 || len(catalog.calls["list:developers"]) != 0 {
+		t.Fatalf("Expected the five developers in batched games-of-entities queries, got %v", catalog.calls)
+	}
+}
+
+// Test that a query asking for more games than the limit is refused before querying them
+func TestGraphQL_MaxGames(t *testing.T) {
+	catalog := newGraphCatalog()
+	server := api.NewServer(":0")
+	server.HandleGraphQL(catalog)
+
+	var query strings.Builder
+	query.WriteString("{")
+	for i := 0; i < 6; i++ {
+		fmt.Fprintf(&query, " page%d: games(first: %d) { games { id } }", i, db.MaxGameLimit)
+	}
+	query.WriteString(" }")
+	body, _ := json.Marshal(map[string]string{"query": query.String()})
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+
+	var response struct {
+		Errors []struct{ Message string }
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if len(response.Errors) == 0 || !strings.Contains(response.Errors[0].Message, "more than") {
+		t.Fatalf("Expected the query to be refused for its size, got %s", recorder.Body)
+	}
+	if calls := catalog.calls["list:"]; len(calls) >= 6 {
+		t.Fatalf("Expected the pages past the limit not to be queried, got %d queries", len(calls))
+	}
+}
+
+// Test paging through the games of an entity with first and after
+func TestGraphQL_EntityGamesPaging(t *testing.T) {
+	catalog := newGraphCatalog()
+	server := api.NewServer(":0")
+	server.HandleGraphQL(catalog)
+
+	type page struct {
+		Game struct {
+			Platforms []struct {
+				Games struct {
+					Games      []struct{ ID string }
+					NextCursor *string
+				}
+			}
+		}
+	}
+	var first page
+	postGraphQL(t, server, `{ game(id: 4) { platforms { games(first: 1) { games { id } nextCursor } } } }`, &first)
+	games := first.Game.Platforms[0].Games
+	if len(games.Games) != 1 || games.Games[0].ID != "1" || games.NextCursor == nil {
+		t.Fatalf("Expected game 1 and a cursor, got %+v", games)
+	}
+
+	var second page
+	postGraphQL(t, server, fmt.Sprintf(`{ game(id: 4) { platforms { games(first: 2, after: %q) { games { id } nextCursor } } } }`, *games.NextCursor), &second)
+	games = second.Game.Platforms[0].Games
+	if len(games.Games) != 2 || games.Games[0].ID != "2" || games.Games[1].ID != "4" || games.NextCursor != nil {
+		t.Fatalf("Expected games 2 and 4 on the last page, got %+v", games)
+	}
+}
+
+// Test that queries nesting deeper than the limit are rejected
+func TestGraphQL_MaxDepth(t *testing.T) {
+	catalog := newGraphCatalog()
+	server := api.NewServer(":0")
+	server.HandleGraphQL(catalog)
+
+	query := `{ game(id: 1) { developers { games { games { platforms { games { games { developers { name } } } } } } } } }`
+	body, _ := json.Marshal(map[string]string{"query": query})
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+
+	var response struct {
+		Data   json.RawMessage
+		Errors []struct{ Message string }
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if len(response.Errors) == 0 {
+		t.Fatalf("Expected the query to be rejected for its depth, got %s", recorder.Body)
+	}
+	if len(catalog.calls) != 0 {
+		t.Fatalf("Expected no catalog queries, got %v", catalog.calls)
+	}
+}
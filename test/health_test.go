@@ -0,0 +1,93 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gamenet/internal/pkg/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test that liveness is reported without consulting any dependency
+func TestHealthz(t *testing.T) {
+	server := api.NewServer(":0")
+	server.AddCheck("broken", func(ctx context.Context) error { return errors.New("down") })
+
+	for _, path := range []string{"/healthz", "/health"} {
+		recorder := httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected %s to return 200, got %d", path, recorder.Code)
+		}
+	}
+}
+
+// Test that readiness reports every dependency and fails when one is down
+func TestReadyz(t *testing.T) {
+	server := api.NewServer(":0")
+	server.AddCheck("postgres", func(ctx context.Context) error { return nil })
+
+	report := getReadiness(t, server, http.StatusOK)
+	if report.Status != "ready" || report.Checks["postgres"].Status != "ok" {
+		t.Fatalf("Expected ready report, got %+v", report)
+	}
+
+	server.AddCheck("neo4j", func(ctx context.Context) error { return errors.New("connection refused") })
+	report = getReadiness(t, server, http.StatusServiceUnavailable)
+	if report.Status != "not ready" {
+		t.Fatalf("Expected not ready, got %q", report.Status)
+	}
+	if check := report.Checks["neo4j"]; check.Status != "error" || check.Error != "connection refused" {
+		t.Fatalf("Expected neo4j error to be reported, got %+v", check)
+	}
+	if report.Checks["postgres"].Status != "ok" {
+		t.Fatalf("Expected postgres to still be ok, got %+v", report.Checks["postgres"])
+	}
+}
+
+// Test that a draining server stops reporting ready
+func TestReadyz_Draining(t *testing.T) {
+	server := api.NewServer(":0")
+	server.AddCheck("postgres", func(ctx context.Context) error { return nil })
+	server.Drain()
+
+	if report := getReadiness(t, server, http.StatusServiceUnavailable); report.Status != "draining" {
+		t.Fatalf("Expected draining, got %q", report.Status)
+	}
+}
+
+// getReadiness calls /readyz and checks the status code.
+func getReadiness(t *testing.T, server *api.Server, expectedCode int) api.ReadinessReport {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != expectedCode {
+		t.Fatalf("Expected status %d, got %d: %s", expectedCode, recorder.Code, recorder.Body.String())
+	}
+
+	var report api.ReadinessReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode readiness report: %v", err)
+	}
+	return report
+}
+
+// Test that a pending check fails until its dependency's check is set
+func TestPendingCheck(t *testing.T) {
+	server := api.NewServer(":0")
+	var pending api.PendingCheck
+	server.AddCheck("ner", pending.Check)
+
+	report := server.Readiness(context.Background())
+	if check := report.Checks["ner"]; report.Status != "not ready" || check.Error != api.ErrStarting.Error() {
+		t.Fatalf("Expected the pending check to report starting, got %+v", report)
+	}
+
+	pending.Set(func(ctx context.Context) error { return nil })
+	if report := server.Readiness(context.Background()); report.Status != "ready" {
+		t.Fatalf("Expected ready once the check is set, got %+v", report)
+	}
+}
@@ -0,0 +1,100 @@
+package test
+
+import (
+	"gamenet/internal/pkg/wiki"
+	"reflect"
+	"testing"
+)
+
+// zeldaWikitext is an abridged copy of the infobox of "The Legend of Zelda (video game)".
+const zeldaWikitext = `{{Short description|1986 video game}}
+{{Infobox video game
+| title = The Legend of Zelda
+| image = Legend of zelda cover (with cartridge) gold.png
+| developer = [[Nintendo Research & Development 4|Nintendo R&D4]]
+| publisher = [[Nintendo]]
+| director = {{ubl|[[Shigeru Miyamoto]]|[[Takashi Tezuka]]}}
+| composer = [[Koji Kondo]]<ref>{{cite web|url=http://example.com|title=Credits}}</ref>
+| series = ''[[The Legend of Zelda]]''
+| platforms = {{Plainlist|
+* [[Family Computer Disk System|Famicom Disk System]]
+* [[Nintendo Entertainment System|NES]]
+}}
+| released = {{Video game release|JP|February 21, 1986|NA|August 22, 1987}}
+| genre = [[Action-adventure game|Action-adventure]]<!-- per sources -->
+| modes = [[Single-player video game|Single-player]]
+}}
+'''''The Legend of Zelda''''' is a 1986 [[action-adventure game]].`
+
+// Test parsing the fields of a video game infobox into entities
+func TestParseInfobox(t *testing.T) {
+	infobox, ok := wiki.ParseInfobox(zeldaWikitext)
+	if !ok {
+		t.Fatal("Expected an infobox to be found")
+	}
+
+	expected := []wiki.Entity{
+		{Text: "Nintendo R&D4", Label: "Developer", Link: "Nintendo Research & Development 4", Confidence: 1, Source: "infobox:developer"},
+		{Text: "Nintendo", Label: "Publisher", Link: "Nintendo", Confidence: 1, Source: "infobox:publisher"},
+		{Text: "Shigeru Miyamoto", Label: "Director", Link: "Shigeru Miyamoto", Confidence: 1, Source: "infobox:director"},
+		{Text: "Takashi Tezuka", Label: "Director", Link: "Takashi Tezuka", Confidence: 1, Source: "infobox:director"},
+		{Text: "Koji Kondo", Label: "Composer", Link: "Koji Kondo", Confidence: 1, Source: "infobox:composer"},
+		{Text: "The Legend of Zelda", Label: "Series", Link: "The Legend of Zelda", Confidence: 1, Source: "infobox:series"},
+		{Text: "Famicom Disk System", Label: "Platform", Link: "Family Computer Disk System", Confidence: 1, Source: "infobox:platforms"},
+		{Text: "NES", Label: "Platform", Link: "Nintendo Entertainment System", Confidence: 1, Source: "infobox:platforms"},
+		{Text: "February 21, 1986", Label: "ReleaseDate", Confidence: 1, Source: "infobox:released"},
+		{Text: "August 22, 1987", Label: "ReleaseDate", Confidence: 1, Source: "infobox:released"},
+		{Text: "Action-adventure", Label: "Genre", Link: "Action-adventure game", Confidence: 1, Source: "infobox:genre"},
+		{Text: "Single-player", Label: "Mode", Link: "Single-player video game", Confidence: 1, Source: "infobox:modes"},
+	}
+	if entities := infobox.Entities(); !reflect.DeepEqual(entities, expected) {
+		t.Fatalf("Unexpected entities:\n got: %v\nwant: %v", entities, expected)
+	}
+
+	if date := infobox.ReleaseDate(); date != "February 21, 1986" {
+		t.Fatalf("Expected first release date, got %q", date)
+	}
+}
+
+// Test the different list notations infoboxes use
+func TestParseInfobox_Lists(t *testing.T) {
+	wikitext := `{{infobox video game
+|developer=[[Sega]]<br/>[[Sonic Team]]
+|platforms = {{flatlist|
+* [[Sega Genesis|Genesis]]
+* [[Microsoft Windows|Windows]]}}
+|genre = [[Platform game|Platform]], [[Action game|Action]]
+|publisher = [[Sega|Sega Co., Ltd.]]
+|released = {{vgrelease|WW|{{nowrap|June 23, 1991}}}}
+|engine = {{unbulleted list|class=x|[[Unity (game engine)|Unity]]|Custom}}
+}}`
+
+	infobox, ok := wiki.ParseInfobox(wikitext)
+	if !ok {
+		t.Fatal("Expected an infobox to be found")
+	}
+
+	cases := map[string][]string{
+		"developer": {"Sega", "Sonic Team"},
+		"platforms": {"Genesis", "Windows"},
+		"genre":     {"Platform", "Action"},
+		"publisher": {"Sega Co., Ltd."},
+		"released":  {"June 23, 1991"},
+		"engine":    {"Unity", "Custom"},
+	}
+	for field, expected := range cases {
+		if values := infobox.Values(field); !reflect.DeepEqual(values, expected) {
+			t.Fatalf("Field %s: expected %v, got %v", field, expected, values)
+		}
+	}
+}
+
+// Test that pages without a video game infobox are reported as such
+func TestParseInfobox_Missing(t *testing.T) {
+	if _, ok := wiki.ParseInfobox("{{Infobox company\n| name = Nintendo\n}}"); ok {
+		t.Fatal("Expected no video game infobox")
+	}
+	if entities := wiki.InfoboxEntities("Just some text."); entities != nil {
+		t.Fatalf("Expected no entities, got %v", entities)
+	}
+}
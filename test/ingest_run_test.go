@@ -0,0 +1,125 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"testing"
+)
+
+// Test recording page progress and resuming an interrupted run
+func TestIngestRun_Resume(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	config := db.IngestConfig{Category: "Category:Run test games", CategoryDepth: 2, Extractor: "gazetteer"}
+	run, err := db.StartIngestRun(ctx, conn, config)
+	if err != nil {
+		t.Fatalf("Failed to start run: %v", err)
+	}
+	defer conn.Exec(`DELETE FROM ingest_runs WHERE id = $1`, run.ID)
+
+	pages := []wiki.Page{{PageID: 1, Title: "Stored"}, {PageID: 2, Title: "Failed"}, {PageID: 3, Title: "Extracted"}}
+	if err := run.MarkFetched(ctx, pages); err != nil {
+		t.Fatalf("Failed to mark pages fetched: %v", err)
+	}
+	run.MarkPage(ctx, 1, "Stored", db.PageStored, nil)
+	run.MarkPage(ctx, 2, "Failed", db.PageFailed, errors.New("NER worker failed"))
+	run.MarkPage(ctx, 3, "Extracted", db.PageExtracted, nil)
+	if err := run.Finish(ctx, db.RunInterrupted); err != nil {
+		t.Fatalf("Failed to finish run: %v", err)
+	}
+
+	// The run cannot be resumed while its process still holds it
+	if _, err := db.ResumeIngestRun(ctx, conn, run.ID); !errors.Is(err, db.ErrRunInProgress) {
+		t.Fatalf("Expected ErrRunInProgress while the run is held, got %v", err)
+	}
+	if err := run.Close(); err != nil {
+		t.Fatalf("Failed to release run: %v", err)
+	}
+
+	// Resuming restores the configuration and only reports the stored page as done
+	resumed, err := db.ResumeIngestRun(ctx, conn, run.ID)
+	if err != nil {
+		t.Fatalf("Failed to resume run: %v", err)
+	}
+	if _, err := db.ResumeIngestRun(ctx, conn, run.ID); !errors.Is(err, db.ErrRunInProgress) {
+		t.Fatalf("Expected a second resume to fail with ErrRunInProgress, got %v", err)
+	}
+	if resumed.Status != db.RunRunning || resumed.Config != config {
+		t.Fatalf("Expected a running run with the original config, got %s %+v", resumed.Status, resumed.Config)
+	}
+	stored, err := resumed.StoredPages(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read stored pages: %v", err)
+	}
+	if len(stored) != 1 || !stored[1] {
+		t.Fatalf("Expected only page 1 to be stored, got %v", stored)
+	}
+
+	var message string
+	conn.QueryRow(`SELECT error FROM ingest_pages WHERE run_id = $1 AND page_id = 2`, run.ID).Scan(&message)
+	if message != "NER worker failed" {
+		t.Fatalf("Expected the failure to be recorded, got %q", message)
+	}
+
+	// A completed run cannot be resumed
+	if err := resumed.Finish(ctx, db.RunCompleted); err != nil {
+		t.Fatalf("Failed to finish run: %v", err)
+	}
+	resumed.Close()
+	if _, err := db.ResumeIngestRun(ctx, conn, run.ID); err == nil {
+		t.Fatalf("Expected resuming a completed run to fail")
+	}
+	if _, err := db.ResumeIngestRun(ctx, conn, -1); !errors.Is(err, db.ErrRunNotFound) {
+		t.Fatalf("Expected ErrRunNotFound, got %v", err)
+	}
+}
+
+// Test that label reports accumulate over the sessions of a run
+func TestIngestRun_LabelCounts(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	run, err := db.StartIngestRun(ctx, conn, db.IngestConfig{Category: "Category:Label test games"})
+	if err != nil {
+		t.Fatalf("Failed to start run: %v", err)
+	}
+	defer run.Close()
+	defer conn.Exec(`DELETE FROM ingest_runs WHERE id = $1`, run.ID)
+
+	session := wiki.LabelCounts{
+		Mapped:   map[string]map[string]int{"ORG": {"Developer": 2}},
+		Unmapped: map[string]int{"GPE": 1},
+	}
+	for i := 0; i < 2; i++ {
+		if err := run.AddLabelCounts(ctx, session); err != nil {
+			t.Fatalf("Failed to add label counts: %v", err)
+		}
+	}
+
+	counts, err := run.LabelCounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read label counts: %v", err)
+	}
+	if counts.Mapped["ORG"]["Developer"] != 4 || counts.Unmapped["GPE"] != 2 {
+		t.Fatalf("Unexpected label counts: %+v", counts)
+	}
+}
@@ -0,0 +1,129 @@
+package test
+
+import (
+	"context"
+	"gamenet/internal/pkg/wiki"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// rawEntity returns an entity with a raw NER label at the position of mention in text.
+func rawEntity(text, mention, label string) wiki.Entity {
+	start := len([]rune(text[:strings.Index(text, mention)]))
+	return wiki.Entity{Text: mention, Label: label, Start: start, End: start + len([]rune(mention))}
+}
+
+// Test that raw labels are mapped by the nearest preceding context phrase
+func TestLabelMap_Context(t *testing.T) {
+	labels := wiki.DefaultLabelMap()
+
+	text := "Metroid Prime was developed by Retro Studios and published by Nintendo, and released for the GameCube. Nintendo also made Metroid Fusion."
+	entities := labels.Map(text, []wiki.Entity{
+		rawEntity(text, "Metroid Prime", "WORK_OF_ART"),
+		rawEntity(text, "Retro Studios", "ORG"),
+		rawEntity(text, "Nintendo", "ORG"),
+		rawEntity(text, "GameCube", "PRODUCT"),
+		rawEntity(text, "Nintendo also", "ORG"), // The second sentence has no context
+	}, nil)
+
+	expected := []string{"Game", "Developer", "Publisher", "Platform", "Developer"}
+	if len(entities) != len(expected) {
+		t.Fatalf("Expected %d entities, got %+v", len(expected), entities)
+	}
+	for i, label := range expected {
+		if entities[i].Label != label {
+			t.Errorf("Entity %q: expected %s, got %s", entities[i].Text, label, entities[i].Label)
+		}
+	}
+}
+
+// Test that prepositions alone do not make a product a platform
+func TestLabelMap_ProductWithoutContext(t *testing.T) {
+	labels := wiki.DefaultLabelMap()
+
+	text := "Its story is based on the Game Boy Camera, and a sequel for the Walkman followed."
+	entities := labels.Map(text, []wiki.Entity{
+		rawEntity(text, "Game Boy Camera", "PRODUCT"),
+		rawEntity(text, "Walkman", "PRODUCT"),
+	}, nil)
+
+	if len(entities) != 2 {
+		t.Fatalf("Expected 2 entities, got %+v", entities)
+	}
+	for _, entity := range entities {
+		if entity.Label != "Product" {
+			t.Errorf("Entity %q: expected Product, got %s", entity.Text, entity.Label)
+		}
+	}
+}
+
+// Test that dropped and unmapped labels are counted and GameNet types pass through
+func TestLabelMap_Report(t *testing.T) {
+	labels := wiki.NewLabelMap([]wiki.LabelRule{
+		{Raw: "ORG", Type: "Developer"},
+		{Raw: "DATE", Type: wiki.DropLabel},
+	})
+	report := &wiki.LabelReport{}
+
+	entities := labels.Map("", []wiki.Entity{
+		{Text: "Capcom", Label: "ORG"},
+		{Text: "1996", Label: "DATE"},
+		{Text: "Osaka", Label: "GPE"},
+		{Text: "Action", Label: "Genre"},
+	}, report)
+	report.CountUnstored([]wiki.Entity{{Text: "Capcom", Label: "Developer"}, {Text: "Sega", Label: "Publisher"}, {Text: "Resident Evil", Label: "Game"}})
+
+	if len(entities) != 2 || entities[0].Label != "Developer" || entities[1].Label != "Genre" {
+		t.Fatalf("Expected Capcom as Developer and Action as Genre, got %+v", entities)
+	}
+	counts := report.Counts()
+	if counts.Mapped["ORG"]["Developer"] != 1 || counts.Dropped["DATE"] != 1 || counts.Unmapped["GPE"] != 1 || counts.Unstored["Game"] != 1 {
+		t.Fatalf("Unexpected counts: %+v", counts)
+	}
+	expected := "mapped ORG→Developer 1; dropped DATE 1; unmapped GPE 1; not stored Game 1"
+	if summary := counts.String(); summary != expected {
+		t.Fatalf("Expected summary %q, got %q", expected, summary)
+	}
+}
+
+// Test reading rules from a file, including an invalid one
+func TestLoadLabelRulesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "labels.tsv")
+	content := "# comment\nORG\tPublisher\tpublished by\nORG\tDeveloper\n\nPERSON\t-\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write label map: %v", err)
+	}
+
+	rules, err := wiki.LoadLabelRulesFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load label map: %v", err)
+	}
+	if len(rules) != 3 || rules[0] != (wiki.LabelRule{Raw: "ORG", Type: "Publisher", Context: "published by"}) {
+		t.Fatalf("Unexpected rules: %+v", rules)
+	}
+
+	invalid := filepath.Join(dir, "invalid.tsv")
+	if err := os.WriteFile(invalid, []byte("ORG\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write label map: %v", err)
+	}
+	if _, err := wiki.LoadLabelRulesFile(invalid); err == nil {
+		t.Fatal("Expected an error for a rule without a type")
+	}
+}
+
+// Test that the NER pool maps the worker's raw labels
+func TestNERPool_Labels(t *testing.T) {
+	report := &wiki.LabelReport{}
+	pool := newFakeNERPoolWithLabels(t, wiki.NewLabelMap([]wiki.LabelRule{{Raw: "Developer", Type: "Publisher", Context: "published by"}}), report)
+
+	entities, err := pool.Extract(context.Background(), "published by Sega")
+	if err != nil {
+		t.Fatalf("Failed to extract entities: %v", err)
+	}
+	if len(entities) != 1 || entities[0].Label != "Publisher" || report.Counts().Mapped["Developer"]["Publisher"] != 1 {
+		t.Fatalf("Expected Sega as Publisher, got %+v", entities)
+	}
+}
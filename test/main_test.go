@@ -1,6 +1,7 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	"gamenet/internal/pkg/db"
 	"gamenet/internal/pkg/wiki"
@@ -108,10 +109,8 @@ func TestGamePipeline_InvalidData(t *testing.T) {
 
 // Helper function to verify that a game and its entities were correctly inserted into the database
 func verifyGameInsertion(conn *db.DB, gameTitle string, expectedEntities []wiki.Entity) error {
-	// Verify that the game exists in the database
-	var gameID int
-	query := `SELECT id FROM Games WHERE title = $1`
-	err := conn.QueryRow(query, gameTitle).Scan(&gameID)
+	// Verify that the game exists in the database, under its title or one of its aliases
+	gameID, err := wiki.FindGameID(context.Background(), conn, gameTitle)
 	if err != nil {
 		return fmt.Errorf("failed to verify game '%s': %v", gameTitle, err)
 	}
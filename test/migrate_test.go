@@ -0,0 +1,146 @@
+package test
+
+import (
+	"context"
+	"gamenet/internal/pkg/db"
+	"strings"
+	"testing"
+)
+
+// Test that the embedded migrations are complete and ordered
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := db.LoadMigrations()
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("Expected at least one migration")
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Fatalf("Expected migration %d to have version %d, got %d", i, i+1, migration.Version)
+		}
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			t.Fatalf("Migration %d_%s is missing its up or down script", migration.Version, migration.Name)
+		}
+	}
+}
+
+// Test applying all migrations and reading their status
+func TestMigrateUp(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := db.MigrateUp(context.Background(), conn); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+
+	// A second run must be a no-op
+	applied, err := db.MigrateUp(context.Background(), conn)
+	if err != nil {
+		t.Fatalf("Failed to re-run migrations: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("Expected no migrations on second run, applied %d", len(applied))
+	}
+
+	statuses, err := db.MigrationStatuses(context.Background(), conn)
+	if err != nil {
+		t.Fatalf("Failed to read migration status: %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Fatalf("Expected migration %d_%s to be applied", status.Version, status.Name)
+		}
+	}
+}
+
+// Test that the unique constraints migration merges the duplicate rows left by the old insert path
+func TestMigrateUp_MergesDuplicates(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	migrations, err := db.LoadMigrations()
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	// Build the initial schema, which allowed duplicates, in a scratch schema on one connection
+	ctx := context.Background()
+	scratch, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open connection: %v", err)
+	}
+	defer scratch.Close()
+	if _, err := scratch.ExecContext(ctx, `CREATE SCHEMA migrate_duplicates; SET search_path TO migrate_duplicates`); err != nil {
+		t.Fatalf("Failed to create scratch schema: %v", err)
+	}
+	defer scratch.ExecContext(ctx, `RESET search_path; DROP SCHEMA migrate_duplicates CASCADE`)
+	if _, err := scratch.ExecContext(ctx, migrations[0].Up); err != nil {
+		t.Fatalf("Failed to apply migration %d_%s: %v", migrations[0].Version, migrations[0].Name, err)
+	}
+
+	// Seed the same game and developer twice, each copy with its own relationship rows
+	var gameIDs, developerIDs [2]int
+	for i := range gameIDs {
+		err := scratch.QueryRowContext(ctx, `INSERT INTO Games (title) VALUES ('Duplicate Quest') RETURNING id`).Scan(&gameIDs[i])
+		if err != nil {
+			t.Fatalf("Failed to seed game: %v", err)
+		}
+		err = scratch.QueryRowContext(ctx, `INSERT INTO Developers (name) VALUES ('Duplicate Studio') RETURNING id`).Scan(&developerIDs[i])
+		if err != nil {
+			t.Fatalf("Failed to seed developer: %v", err)
+		}
+		_, err = scratch.ExecContext(ctx, `INSERT INTO GameDevelopers (game_id, developer_id) VALUES ($1, $2)`, gameIDs[i], developerIDs[i])
+		if err != nil {
+			t.Fatalf("Failed to seed game developer: %v", err)
+		}
+	}
+	var platformID int
+	if err := scratch.QueryRowContext(ctx, `INSERT INTO Platforms (name) VALUES ('Duplicate Console') RETURNING id`).Scan(&platformID); err != nil {
+		t.Fatalf("Failed to seed platform: %v", err)
+	}
+	if _, err := scratch.ExecContext(ctx, `INSERT INTO GamePlatforms (game_id, platform_id) VALUES ($1, $2)`, gameIDs[1], platformID); err != nil {
+		t.Fatalf("Failed to seed game platform: %v", err)
+	}
+
+	tx, err := scratch.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, migrations[1].Up); err != nil {
+		tx.Rollback()
+		t.Fatalf("Failed to apply migration %d_%s over duplicate rows: %v", migrations[1].Version, migrations[1].Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit migration: %v", err)
+	}
+
+	var games, developers int
+	scratch.QueryRowContext(ctx, `SELECT count(*) FROM Games`).Scan(&games)
+	scratch.QueryRowContext(ctx, `SELECT count(*) FROM Developers`).Scan(&developers)
+	if games != 1 || developers != 1 {
+		t.Fatalf("Expected one game and one developer after merging, got %d and %d", games, developers)
+	}
+
+	// The kept game carries the relationships of both copies, pointing at the kept developer
+	var gameDevelopers, gamePlatforms int
+	err = scratch.QueryRowContext(ctx, `SELECT count(*) FROM GameDevelopers WHERE game_id = $1 AND developer_id = $2`, gameIDs[0], developerIDs[0]).Scan(&gameDevelopers)
+	if err != nil {
+		t.Fatalf("Failed to count game developers: %v", err)
+	}
+	err = scratch.QueryRowContext(ctx, `SELECT count(*) FROM GamePlatforms WHERE game_id = $1 AND platform_id = $2`, gameIDs[0], platformID).Scan(&gamePlatforms)
+	if err != nil {
+		t.Fatalf("Failed to count game platforms: %v", err)
+	}
+	if gameDevelopers != 1 || gamePlatforms != 1 {
+		t.Fatalf("Expected the kept game to keep its developer and gain the platform, got %d and %d", gameDevelopers, gamePlatforms)
+	}
+}
@@ -0,0 +1,175 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"gamenet/internal/pkg/wiki"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNERWorker speaks the NER worker protocol without loading a model. Every
+// capitalized word becomes a Developer entity with its offsets, and the special
+// texts "crash" and "hang" simulate a dying and a stuck worker.
+const fakeNERWorker = `
+import sys, json, time
+print(json.dumps({"ready": True, "model": "fake", "version": "1.0"}), flush=True)
+for line in sys.stdin:
+    request = json.loads(line)
+    text = request["text"]
+    if text == "crash":
+        sys.exit(3)
+    if text == "hang":
+        time.sleep(30)
+    entities, offset = [], 0
+    for word in text.split(" "):
+        if word[:1].isupper():
+            entities.append({"text": word, "label": "Developer", "start": offset, "end": offset + len(word)})
+        offset += len(word) + 1
+    print(json.dumps({"id": request["id"], "entities": entities}), flush=True)
+`
+
+// newFakeNERPool starts a pool of fake NER workers for the duration of the test.
+func newFakeNERPool(t *testing.T, size int, timeout time.Duration) *wiki.NERPool {
+	t.Helper()
+	return startFakeNERPool(t, wiki.NERPoolConfig{Size: size, RequestTimeout: timeout})
+}
+
+// newFakeNERPoolWithLabels starts a single fake NER worker whose labels are mapped by labels.
+func newFakeNERPoolWithLabels(t *testing.T, labels *wiki.LabelMap, report *wiki.LabelReport) *wiki.NERPool {
+	t.Helper()
+	return startFakeNERPool(t, wiki.NERPoolConfig{Size: 1, RequestTimeout: 10 * time.Second, Labels: labels, LabelReport: report})
+}
+
+// startFakeNERPool starts a pool of fake NER workers with the given configuration.
+func startFakeNERPool(t *testing.T, config wiki.NERPoolConfig) *wiki.NERPool {
+	t.Helper()
+
+	script := filepath.Join(t.TempDir(), "fake_ner.py")
+	if err := os.WriteFile(script, []byte(fakeNERWorker), 0o644); err != nil {
+		t.Fatalf("Failed to write fake worker: %v", err)
+	}
+
+	config.Command = "python3"
+	config.Args = []string{script}
+	pool, err := wiki.NewNERPool(config)
+	if err != nil {
+		t.Fatalf("Failed to start NER pool: %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool
+}
+
+// Test that the pool answers concurrent requests with the right entities
+func TestNERPool_Concurrent(t *testing.T) {
+	pool := newFakeNERPool(t, 3, 10*time.Second)
+
+	if pool.Alive() != 3 {
+		t.Fatalf("Expected 3 live workers, got %d", pool.Alive())
+	}
+	if pool.Model() != "fake@1.0" {
+		t.Fatalf("Expected model fake@1.0, got %q", pool.Model())
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entities, err := pool.Extract(context.Background(), "Nintendo made this with Sega")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if len(entities) != 2 || entities[0].Text != "Nintendo" || entities[1].Text != "Sega" {
+				errs <- errors.New("unexpected entities")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("Concurrent extraction failed: %v", err)
+	}
+}
+
+// Test that entities are attributed to the worker's model with their offsets
+func TestNERPool_Provenance(t *testing.T) {
+	pool := newFakeNERPool(t, 1, 10*time.Second)
+
+	entities, err := pool.Extract(context.Background(), "Made by Nintendo")
+	if err != nil {
+		t.Fatalf("Failed to extract entities: %v", err)
+	}
+	expected := wiki.Entity{Text: "Nintendo", Label: "Developer", Confidence: wiki.NERConfidence, Start: 8, End: 16, Source: "ner:fake@1.0"}
+	if len(entities) != 2 || entities[1] != expected {
+		t.Fatalf("Expected Made and %+v, got %+v", expected, entities)
+	}
+}
+
+// Test that texts far larger than ARG_MAX reach the worker intact
+func TestNERPool_LargeInput(t *testing.T) {
+	pool := newFakeNERPool(t, 1, 10*time.Second)
+
+	text := strings.Repeat("word ", 1<<20) + "Nintendo"
+	entities, err := pool.Extract(context.Background(), text)
+	if err != nil {
+		t.Fatalf("Failed to extract from large input: %v", err)
+	}
+	if len(entities) != 1 || entities[0].Text != "Nintendo" {
+		t.Fatalf("Expected Nintendo, got %v", entities)
+	}
+}
+
+// Test that a crashed worker is reported and transparently restarted
+func TestNERPool_CrashRestart(t *testing.T) {
+	pool := newFakeNERPool(t, 1, 10*time.Second)
+
+	if _, err := pool.Extract(context.Background(), "crash"); err == nil {
+		t.Fatal("Expected an error from a crashing worker")
+	}
+
+	entities, err := pool.Extract(context.Background(), "Capcom")
+	if err != nil {
+		t.Fatalf("Expected restarted worker to answer, got %v", err)
+	}
+	if len(entities) != 1 || entities[0].Text != "Capcom" {
+		t.Fatalf("Expected Capcom, got %v", entities)
+	}
+	if pool.Restarts() != 1 {
+		t.Fatalf("Expected 1 restart, got %d", pool.Restarts())
+	}
+}
+
+// Test that a stuck worker times out and is replaced
+func TestNERPool_Timeout(t *testing.T) {
+	pool := newFakeNERPool(t, 1, 500*time.Millisecond)
+
+	start := time.Now()
+	if _, err := pool.Extract(context.Background(), "hang"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Expected a timeout error, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("Timeout took far longer than configured")
+	}
+
+	if _, err := pool.Extract(context.Background(), "Konami"); err != nil {
+		t.Fatalf("Expected replacement worker to answer, got %v", err)
+	}
+}
+
+// Test that a closed pool rejects requests
+func TestNERPool_Closed(t *testing.T) {
+	pool := newFakeNERPool(t, 1, time.Second)
+	pool.Close()
+
+	if _, err := pool.Extract(context.Background(), "Atari"); !errors.Is(err, wiki.ErrNERPoolClosed) {
+		t.Fatalf("Expected ErrNERPoolClosed, got %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gamenet/internal/pkg/api"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// Test parsing node references
+func TestParsePathNode(t *testing.T) {
+	node, err := db.ParsePathNode("developers/3")
+	if err != nil || node.Kind != "developers" || node.ID != 3 || node.Ref() != "developers/3" {
+		t.Fatalf("Expected developers/3, got %+v (%v)", node, err)
+	}
+	for _, ref := range []string{"", "games", "games/0", "games/x", "companies/1"} {
+		if _, err := db.ParsePathNode(ref); err == nil {
+			t.Fatalf("Expected an error for %q", ref)
+		}
+	}
+}
+
+// fakePathFinder connects games/1 to games/2 through developers/1, rejects unknown
+// relationship types like the real finders and records the options it was asked for.
+type fakePathFinder struct {
+	lastOptions db.PathOptions
+}
+
+func (f *fakePathFinder) ShortestPath(ctx context.Context, from, to db.PathNode, opts db.PathOptions) (db.Path, error) {
+	f.lastOptions = opts
+	for _, relationship := range opts.Relationships {
+		if !slices.Contains(db.PathRelationships(), relationship) {
+			return db.Path{}, fmt.Errorf("%w: unknown relationship type %q", db.ErrInvalidPathOptions, relationship)
+		}
+	}
+	if from.Ref() != "games/1" || to.Ref() != "games/2" {
+		return db.Path{}, fmt.Errorf("%w within %d hops", db.ErrNoPath, opts.MaxHops)
+	}
+	return db.Path{
+		Nodes:         []db.PathNode{{Kind: "games", ID: 1, Name: "Game 1"}, {Kind: "developers", ID: 1, Name: "Nintendo"}, {Kind: "games", ID: 2, Name: "Game 2"}},
+		Relationships: []string{"DEVELOPED_BY", "DEVELOPED_BY"},
+	}, nil
+}
+
+// Test the path endpoint and its parameters
+func TestPathAPI(t *testing.T) {
+	finder := &fakePathFinder{}
+	server := api.NewServer(":0")
+	server.HandlePath(finder)
+
+	var path db.Path
+	getJSON(t, server, "/path?from=games/1&to=games/2&relationships=developed_by,HAS_GENRE&max_hops=4", http.StatusOK, &path)
+	if len(path.Nodes) != 3 || path.Nodes[1].Name != "Nintendo" || len(path.Relationships) != 2 {
+		t.Fatalf("Unexpected path: %+v", path)
+	}
+	if finder.lastOptions.MaxHops != 4 || len(finder.lastOptions.Relationships) != 2 || finder.lastOptions.Relationships[0] != "DEVELOPED_BY" {
+		t.Fatalf("Expected 4 hops over DEVELOPED_BY and HAS_GENRE, got %+v", finder.lastOptions)
+	}
+
+	var failure map[string]string
+	getJSON(t, server, "/path?from=games/1&to=games/3", http.StatusNotFound, &failure)
+	for _, bad := range []string{
+		"/path?from=games/1",
+		"/path?from=games/1&to=companies/2",
+		"/path?from=games/1&to=games/2&relationships=MADE_BY",
+		"/path?from=games/1&to=games/2&max_hops=11",
+	} {
+		getJSON(t, server, bad, http.StatusBadRequest, &failure)
+	}
+}
+
+// Test the breadth-first search over PostgreSQL
+func TestCatalog_ShortestPath(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	defer deletePathGames(conn)
+	gameIDs := seedPathGames(t, ctx, conn)
+
+	catalog := db.NewCatalog(conn)
+	from, to := db.PathNode{Kind: "games", ID: gameIDs[0]}, db.PathNode{Kind: "games", ID: gameIDs[2]}
+	path, err := catalog.ShortestPath(ctx, from, to, db.PathOptions{})
+	if err != nil {
+		t.Fatalf("Failed to find path: %v", err)
+	}
+	expected := []string{"Path Test Game 1", "Path Test Studio", "Path Test Game 2", "Path Test Genre", "Path Test Game 3"}
+	if len(path.Nodes) != len(expected) {
+		t.Fatalf("Expected %v, got %+v", expected, path)
+	}
+	for i, name := range expected {
+		if path.Nodes[i].Name != name {
+			t.Fatalf("Expected %v, got %+v", expected, path)
+		}
+	}
+	if path.Relationships[0] != "DEVELOPED_BY" || path.Relationships[3] != "HAS_GENRE" {
+		t.Fatalf("Unexpected relationships %v", path.Relationships)
+	}
+
+	if _, err := catalog.ShortestPath(ctx, from, to, db.PathOptions{MaxHops: 3}); !errors.Is(err, db.ErrNoPath) {
+		t.Fatalf("Expected ErrNoPath within 3 hops, got %v", err)
+	}
+	if _, err := catalog.ShortestPath(ctx, from, to, db.PathOptions{Relationships: []string{"DEVELOPED_BY"}}); !errors.Is(err, db.ErrNoPath) {
+		t.Fatalf("Expected ErrNoPath over DEVELOPED_BY only, got %v", err)
+	}
+}
+
+// Test that the Cypher path finder finds paths as short as the PostgreSQL search
+func TestGraphPathFinder_ShortestPath(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+	if err := db.InitNeo4j(); err != nil {
+		t.Fatalf("Failed to connect to Neo4j: %v", err)
+	}
+	defer db.CloseNeo4j()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	defer deletePathGames(conn)
+	gameIDs := seedPathGames(t, ctx, conn)
+
+	session := db.Neo4jDriver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	defer session.Run(`MATCH (g:Game) WHERE g.page_id >= 991001 AND g.page_id <= 991003 DETACH DELETE g`, nil)
+	defer session.Run(`MATCH (e) WHERE e.name IN ['Path Test Studio', 'Path Test Genre'] DETACH DELETE e`, nil)
+
+	var graphGames []db.GraphGame
+	for i, entities := range pathFixture {
+		graphGames = append(graphGames, db.GraphGame{PageID: 991001 + i, Title: fmt.Sprintf("Path Test Game %d", i+1), Entities: entities})
+	}
+	if err := db.NewGraphWriter(db.Neo4jDriver).WriteGames(graphGames); err != nil {
+		t.Fatalf("Failed to write games to Neo4j: %v", err)
+	}
+
+	catalog := db.NewCatalog(conn)
+	finder := db.NewGraphPathFinder(db.Neo4jDriver, catalog)
+	first, last := db.PathNode{Kind: "games", ID: gameIDs[0]}, db.PathNode{Kind: "games", ID: gameIDs[2]}
+	full, err := catalog.ShortestPath(ctx, first, last, db.PathOptions{})
+	if err != nil {
+		t.Fatalf("Failed to find path: %v", err)
+	}
+	genre := full.Nodes[3]
+
+	tests := []struct {
+		from, to db.PathNode
+		opts     db.PathOptions
+		err      error
+	}{
+		{first, last, db.PathOptions{}, nil},
+		{last, first, db.PathOptions{}, nil},
+		{first, genre, db.PathOptions{}, nil},
+		{first, last, db.PathOptions{MaxHops: 3}, db.ErrNoPath},
+		{first, last, db.PathOptions{Relationships: []string{"HAS_GENRE"}}, db.ErrNoPath},
+	}
+	for _, test := range tests {
+		want, err := catalog.ShortestPath(ctx, test.from, test.to, test.opts)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("PostgreSQL path from %s to %s with %+v: expected error %v, got %v", test.from.Ref(), test.to.Ref(), test.opts, test.err, err)
+		}
+		got, err := finder.ShortestPath(ctx, test.from, test.to, test.opts)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("Neo4j path from %s to %s with %+v: expected error %v, got %v", test.from.Ref(), test.to.Ref(), test.opts, test.err, err)
+		}
+		if len(got.Relationships) != len(want.Relationships) {
+			t.Fatalf("Path from %s to %s with %+v: expected %d hops like PostgreSQL, got %+v", test.from.Ref(), test.to.Ref(), test.opts, len(want.Relationships), got)
+		}
+	}
+}
+
+// pathFixture holds the entities of three games: game 1 and game 2 share a developer,
+// game 2 and game 3 a genre.
+var pathFixture = [][]wiki.Entity{
+	{{Text: "Path Test Studio", Label: "Developer"}},
+	{{Text: "Path Test Studio", Label: "Developer"}, {Text: "Path Test Genre", Label: "Genre"}},
+	{{Text: "Path Test Genre", Label: "Genre"}},
+}
+
+// seedPathGames stores pathFixture as pages 991001 to 991003 and returns their IDs.
+func seedPathGames(t *testing.T, ctx context.Context, conn *sql.DB) []int {
+	t.Helper()
+	var gameIDs []int
+	for i, entities := range pathFixture {
+		id, err := wiki.UpsertGame(ctx, conn, wiki.GameRecord{PageID: 991001 + i, Title: fmt.Sprintf("Path Test Game %d", i+1), Entities: entities})
+		if err != nil {
+			t.Fatalf("Failed to upsert game: %v", err)
+		}
+		gameIDs = append(gameIDs, id)
+	}
+	return gameIDs
+}
+
+// deletePathGames deletes the games and entities stored by seedPathGames.
+func deletePathGames(conn *sql.DB) {
+	conn.Exec(`DELETE FROM Games WHERE page_id BETWEEN 991001 AND 991003`)
+	conn.Exec(`DELETE FROM Genres WHERE name = 'Path Test Genre'`)
+	conn.Exec(`DELETE FROM Developers WHERE name = 'Path Test Studio'`)
+}
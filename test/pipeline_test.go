@@ -0,0 +1,139 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"gamenet/internal/pkg/pipeline"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// emitNumbers returns a source stage emitting 1..n.
+func emitNumbers(ctx context.Context, n int) pipeline.Stage[int] {
+	return pipeline.Source(ctx, "numbers", 0, func(ctx context.Context, emit func(int) error) error {
+		for i := 1; i <= n; i++ {
+			if err := emit(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Test that a stage runs its workers concurrently and reports failures on its error channel
+func TestMap_WorkersAndErrors(t *testing.T) {
+	ctx := context.Background()
+	source := emitNumbers(ctx, 20)
+
+	var running, peak atomic.Int32
+	double := pipeline.Map(ctx, "double", pipeline.StageConfig{Workers: 4}, source.Out, func(ctx context.Context, n int) (int, error) {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if current <= old || peak.CompareAndSwap(old, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		switch {
+		case n%5 == 0:
+			return 0, errors.New("multiple of five")
+		case n == 7:
+			return 0, pipeline.ErrSkip
+		}
+		return n * 2, nil
+	})
+
+	var failed []int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pipeline.Collect(func(err error) {
+			var stageErr *pipeline.StageError
+			if !errors.As(err, &stageErr) || stageErr.Stage != "double" {
+				t.Errorf("Expected a StageError from double, got %v", err)
+				return
+			}
+			failed = append(failed, stageErr.Item.(int))
+		}, source.Errors, double.Errors)
+	}()
+
+	var results []int
+	for n := range double.Out {
+		results = append(results, n)
+	}
+	<-done
+
+	if len(results) != 15 {
+		t.Fatalf("Expected 15 results, got %d: %v", len(results), results)
+	}
+	sort.Ints(failed)
+	if len(failed) != 4 || failed[0] != 5 || failed[3] != 20 {
+		t.Fatalf("Expected the multiples of five to fail, got %v", failed)
+	}
+	if peak.Load() < 2 {
+		t.Fatalf("Expected workers to run concurrently, peak was %d", peak.Load())
+	}
+}
+
+// Test that a full buffer holds back the source instead of queueing everything
+func TestMap_Backpressure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var emitted atomic.Int32
+	source := pipeline.Source(ctx, "numbers", 2, func(ctx context.Context, emit func(int) error) error {
+		for i := 0; ; i++ {
+			if err := emit(i); err != nil {
+				return err
+			}
+			emitted.Add(1)
+		}
+	})
+	stage := pipeline.Map(ctx, "identity", pipeline.StageConfig{Workers: 1, Buffer: 2}, source.Out, func(ctx context.Context, n int) (int, error) {
+		return n, nil
+	})
+
+	// Nobody reads the output: at most both buffers plus the item held by the worker can be in flight
+	time.Sleep(50 * time.Millisecond)
+	if n := emitted.Load(); n > 6 {
+		t.Fatalf("Expected the source to block, it emitted %d items", n)
+	}
+
+	// Cancelling stops every stage and closes their channels
+	cancel()
+	for range stage.Out {
+	}
+	for err := range source.Errors {
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected the source to stop with context.Canceled, got %v", err)
+		}
+	}
+}
+
+// Test that Tee delivers every item to every output
+func TestTee(t *testing.T) {
+	ctx := context.Background()
+	source := emitNumbers(ctx, 10)
+	outs := pipeline.Tee(ctx, source.Out, 2, 1)
+
+	sums := make(chan int, 2)
+	for _, out := range outs {
+		go func(out <-chan int) {
+			sum := 0
+			for n := range out {
+				sum += n
+			}
+			sums <- sum
+		}(out)
+	}
+	for i := 0; i < 2; i++ {
+		if sum := <-sums; sum != 55 {
+			t.Fatalf("Expected every output to receive 1..10, got sum %d", sum)
+		}
+	}
+}
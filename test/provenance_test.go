@@ -0,0 +1,84 @@
+package test
+
+import (
+	"context"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"testing"
+)
+
+// Test that merging keeps the provenance of the most confident duplicate
+func TestMergeEntities_Provenance(t *testing.T) {
+	ner := []wiki.Entity{
+		{Text: "Nintendo", Label: "Developer", Confidence: wiki.NERConfidence, Start: 10, End: 18, Source: "ner:fake@1.0"},
+		{Text: "Capcom", Label: "Developer", Confidence: wiki.NERConfidence, Start: 30, End: 36, Source: "ner:fake@1.0"},
+	}
+	infobox := []wiki.Entity{
+		{Text: "Nintendo", Label: "Developer", Link: "Nintendo", Confidence: wiki.InfoboxConfidence, Source: "infobox:developer"},
+	}
+
+	merged := wiki.MergeEntities(ner, infobox)
+	expected := []wiki.Entity{
+		{Text: "Nintendo", Label: "Developer", Link: "Nintendo", Confidence: wiki.InfoboxConfidence, Source: "infobox:developer"},
+		ner[1],
+	}
+	if len(merged) != len(expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, merged)
+	}
+	for i := range expected {
+		if merged[i] != expected[i] {
+			t.Fatalf("Entity %d: expected %+v, got %+v", i, expected[i], merged[i])
+		}
+	}
+}
+
+// Test that each game-entity link stores how it was extracted and can be filtered by confidence
+func TestUpsertGame_Provenance(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	defer conn.Exec(`DELETE FROM Developers WHERE name IN ('Provenance Studio', 'Provenance Maybe')`)
+	defer conn.Exec(`DELETE FROM Games WHERE page_id = 990701`)
+
+	game := wiki.GameRecord{
+		PageID:     990701,
+		Title:      "Provenance Test Game",
+		RevisionID: 123456,
+		Entities: []wiki.Entity{
+			{Text: "Provenance Studio", Label: "Developer", Confidence: 1, Source: "infobox:developer", RevisionID: 123456},
+			{Text: "Provenance Maybe", Label: "Developer", Confidence: 0.4, Start: 12, End: 28, Source: "ner:fake@1.0", RevisionID: 123456},
+		},
+	}
+	gameID, err := wiki.UpsertGame(ctx, conn, game)
+	if err != nil {
+		t.Fatalf("Failed to upsert game: %v", err)
+	}
+
+	entities, err := wiki.GameEntities(ctx, conn, gameID, 0)
+	if err != nil {
+		t.Fatalf("Failed to load entities: %v", err)
+	}
+	if len(entities) != 2 || entities[0] != game.Entities[0] {
+		t.Fatalf("Expected %+v first, got %+v", game.Entities[0], entities)
+	}
+	// REAL columns round-trip 0.4 only approximately
+	maybe := entities[1]
+	if maybe.Start != 12 || maybe.End != 28 || maybe.Source != "ner:fake@1.0" || maybe.RevisionID != 123456 || maybe.Confidence < 0.39 || maybe.Confidence > 0.41 {
+		t.Fatalf("Unexpected provenance: %+v", maybe)
+	}
+
+	confident, err := wiki.GameEntities(ctx, conn, gameID, 0.5)
+	if err != nil {
+		t.Fatalf("Failed to load entities: %v", err)
+	}
+	if len(confident) != 1 || confident[0].Text != "Provenance Studio" {
+		t.Fatalf("Expected only Provenance Studio above 0.5, got %+v", confident)
+	}
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"testing"
+)
+
+// Test sorting tracked games by what happened to their page
+func TestPlanRefresh(t *testing.T) {
+	games := []db.TrackedGame{
+		{PageID: 1, Title: "Unchanged", RevisionID: 10},
+		{PageID: 2, Title: "Edited", RevisionID: 20},
+		{PageID: 3, Title: "Old Title", RevisionID: 30},
+		{PageID: 4, Title: "Deleted", RevisionID: 40},
+		{PageID: 5, Title: "Redirected", RevisionID: 50},
+		{PageID: 6, Title: "Unknown Revision"},
+		{PageID: 7, Title: "Not Reported", RevisionID: 70},
+	}
+	info := map[int]wiki.Page{
+		1: {PageID: 1, Title: "Unchanged", LastRevID: 10},
+		2: {PageID: 2, Title: "Edited", LastRevID: 21},
+		3: {PageID: 3, Title: "New Title", LastRevID: 31},
+		4: {PageID: 4, Missing: true},
+		5: {PageID: 5, Title: "Redirected", LastRevID: 51, Redirect: true},
+		6: {PageID: 6, Title: "Unknown Revision", LastRevID: 60},
+	}
+
+	plan := db.PlanRefresh(games, info)
+	if ids := db.PageIDs(plan.Unchanged); len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("Expected page 1 to be unchanged, got %v", ids)
+	}
+	if ids := db.PageIDs(plan.Changed); len(ids) != 3 || ids[0] != 2 || ids[1] != 3 || ids[2] != 6 {
+		t.Fatalf("Expected pages 2, 3 and 6 to be changed, got %v", ids)
+	}
+	if len(plan.Moved) != 1 || plan.Moved[0].Title != "New Title" {
+		t.Fatalf("Expected page 3 to be moved to its new title, got %+v", plan.Moved)
+	}
+	if ids := db.PageIDs(plan.Deleted); len(ids) != 2 || ids[0] != 4 || ids[1] != 5 {
+		t.Fatalf("Expected pages 4 and 5 to be deleted, got %v", ids)
+	}
+	if ids := db.PageIDs(plan.Unknown); len(ids) != 1 || ids[0] != 7 {
+		t.Fatalf("Expected page 7 to be left alone, got %v", ids)
+	}
+}
+
+// Test that a plan deleting a large share of the tracked games is refused
+func TestRefreshPlan_CheckDeletions(t *testing.T) {
+	var games []db.TrackedGame
+	info := map[int]wiki.Page{}
+	for id := 1; id <= 100; id++ {
+		games = append(games, db.TrackedGame{PageID: id, RevisionID: 1})
+		info[id] = wiki.Page{PageID: id, LastRevID: 1}
+	}
+	for id := 1; id <= 5; id++ {
+		info[id] = wiki.Page{PageID: id, Missing: true}
+	}
+	if err := db.PlanRefresh(games, info).CheckDeletions(len(games)); err != nil {
+		t.Fatalf("Expected a few deletions to be accepted, got %v", err)
+	}
+
+	for id := 1; id <= 20; id++ {
+		info[id] = wiki.Page{PageID: id, Missing: true}
+	}
+	if err := db.PlanRefresh(games, info).CheckDeletions(len(games)); !errors.Is(err, db.ErrTooManyDeletions) {
+		t.Fatalf("Expected 20 of 100 deletions to be refused, got %v", err)
+	}
+}
+
+// Test that pages fetched without their infobox still record their revision, so an
+// unedited page is not extracted again on refresh
+func TestPlanRefresh_WithoutInfobox(t *testing.T) {
+	server := fakeWiki(t)
+	defer server.Close()
+
+	client := wiki.NewClient(server.URL)
+	client.RateLimiter = nil
+	client.IncludeWikitext = false
+
+	var games []db.TrackedGame
+	info := map[int]wiki.Page{}
+	err := client.FetchPages(context.Background(), []int{1, 2}, func(batch []wiki.Page) error {
+		for _, page := range batch {
+			if page.Wikitext != "" {
+				t.Fatalf("Expected no wikitext for %s", page.Title)
+			}
+			games = append(games, db.TrackedGame{PageID: page.PageID, Title: page.Title, RevisionID: page.LastRevID})
+			info[page.PageID] = page
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to fetch pages: %v", err)
+	}
+
+	plan := db.PlanRefresh(games, info)
+	if len(plan.Unchanged) != 2 || len(plan.Changed) != 0 {
+		t.Fatalf("Expected both unedited pages to be unchanged, got %+v", plan)
+	}
+}
+
+// Test that the revision of a stored game is tracked and deleted games disappear
+func TestTrackedGames(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	defer conn.Exec(`DELETE FROM Games WHERE page_id = 990401`)
+
+	game := wiki.GameRecord{PageID: 990401, Title: "Refresh Test Game", RevisionID: 12345,
+		Entities: []wiki.Entity{{Text: "Refresh Studio", Label: "Developer"}}}
+	if _, err := wiki.UpsertGame(ctx, conn, game); err != nil {
+		t.Fatalf("Failed to upsert game: %v", err)
+	}
+
+	games, err := db.TrackedGames(ctx, conn)
+	if err != nil {
+		t.Fatalf("Failed to read tracked games: %v", err)
+	}
+	found := false
+	for _, tracked := range games {
+		if tracked.PageID == game.PageID {
+			found = tracked.RevisionID == game.RevisionID
+		}
+	}
+	if !found {
+		t.Fatalf("Expected the game to be tracked at revision %d", game.RevisionID)
+	}
+
+	deleted, err := db.DeleteGames(ctx, conn, []int{game.PageID})
+	if err != nil || deleted != 1 {
+		t.Fatalf("Expected one game to be deleted, got %d (%v)", deleted, err)
+	}
+	var links int
+	conn.QueryRow(`SELECT count(*) FROM GameDevelopers gd JOIN Developers d ON d.id = gd.developer_id
+		WHERE d.name = 'Refresh Studio'`).Scan(&links)
+	if links != 0 {
+		t.Fatalf("Expected the game's links to be deleted with it, found %d", links)
+	}
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"context"
+	"gamenet/internal/pkg/api"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// fakeSearcher returns one result per query and records its last arguments.
+type fakeSearcher struct {
+	lastQuery         string
+	lastLimit, offset int
+}
+
+func (s *fakeSearcher) Search(ctx context.Context, query string, limit, offset int) ([]db.SearchResult, error) {
+	s.lastQuery, s.lastLimit, s.offset = query, limit, offset
+	return []db.SearchResult{{GameSummary: db.GameSummary{ID: 1, Title: "Metroid"}, Rank: 0.5, Snippet: "<mark>Samus</mark> Aran"}}, nil
+}
+
+func (s *fakeSearcher) Autocomplete(ctx context.Context, prefix string, limit int) ([]db.Suggestion, error) {
+	s.lastQuery, s.lastLimit = prefix, limit
+	return []db.Suggestion{{Kind: "games", ID: 1, Name: "Metroid"}}, nil
+}
+
+// Test the search and autocomplete endpoints and their parameters
+func TestSearchAPI(t *testing.T) {
+	searcher := &fakeSearcher{}
+	server := api.NewServer(":0")
+	server.HandleSearch(searcher)
+
+	var search api.SearchResponse
+	getJSON(t, server, "/search?q=samus+aran&limit=5&offset=10", http.StatusOK, &search)
+	if len(search.Results) != 1 || search.Results[0].Snippet != "<mark>Samus</mark> Aran" {
+		t.Fatalf("Unexpected results: %+v", search)
+	}
+	if searcher.lastQuery != "samus aran" || searcher.lastLimit != 5 || searcher.offset != 10 {
+		t.Fatalf("Expected query, limit and offset to be passed on, got %+v", searcher)
+	}
+
+	var suggestions api.SuggestionResponse
+	getJSON(t, server, "/autocomplete?q=metr", http.StatusOK, &suggestions)
+	if len(suggestions.Suggestions) != 1 || searcher.lastQuery != "metr" || searcher.lastLimit != 0 {
+		t.Fatalf("Unexpected suggestions: %+v", suggestions)
+	}
+
+	var failure map[string]string
+	for _, bad := range []string{"/search", "/search?q=%20", "/search?q=x&limit=0", "/search?q=x&offset=-1", "/autocomplete?q=x&limit=101"} {
+		getJSON(t, server, bad, http.StatusBadRequest, &failure)
+	}
+}
+
+// Test full-text search, typo-tolerant titles and autocomplete in PostgreSQL
+func TestCatalog_Search(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	defer conn.Exec(`DELETE FROM Developers WHERE name = 'Searchtest Softworks'`)
+	defer conn.Exec(`DELETE FROM Games WHERE page_id BETWEEN 991101 AND 991102`)
+
+	records := []wiki.GameRecord{
+		{PageID: 991101, Title: "Searchtest Quasarblade", Summary: "Searchtest Quasarblade is a game about a bounty hunter exploring a derelict space station.",
+			Entities: []wiki.Entity{{Text: "Searchtest Softworks", Label: "Developer"}}},
+		{PageID: 991102, Title: "Searchtest Harvest Days", Summary: "A farming game where the player restores a quasarblade forge in a village <b>& its mill</b>."},
+	}
+	for _, record := range records {
+		if _, err := wiki.UpsertGame(ctx, conn, record); err != nil {
+			t.Fatalf("Failed to upsert game: %v", err)
+		}
+	}
+
+	catalog := db.NewCatalog(conn)
+	results, err := catalog.Search(ctx, "quasarblade", 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(results) != 2 || results[0].Title != "Searchtest Quasarblade" {
+		t.Fatalf("Expected the title match before the summary match, got %+v", results)
+	}
+	if !strings.Contains(results[1].Snippet, "<mark>quasarblade</mark>") {
+		t.Fatalf("Expected a highlighted snippet, got %q", results[1].Snippet)
+	}
+	if strings.Contains(results[1].Snippet, "<b>") || !strings.Contains(results[1].Snippet, "&lt;b&gt;") {
+		t.Fatalf("Expected the summary's markup to be escaped, got %q", results[1].Snippet)
+	}
+
+	results, err = catalog.Search(ctx, "Serchtest Quasarbalde", 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(results) == 0 || results[0].Title != "Searchtest Quasarblade" {
+		t.Fatalf("Expected the misspelled title to be found, got %+v", results)
+	}
+
+	suggestions, err := catalog.Autocomplete(ctx, "searchtest", 10)
+	if err != nil {
+		t.Fatalf("Failed to autocomplete: %v", err)
+	}
+	kinds := make(map[string]int)
+	for _, suggestion := range suggestions {
+		kinds[suggestion.Kind]++
+	}
+	if kinds["games"] != 2 || kinds["developers"] != 1 {
+		t.Fatalf("Expected both games and the developer, got %+v", suggestions)
+	}
+}
@@ -0,0 +1,100 @@
+package test
+
+import (
+	"encoding/json"
+	"gamenet/internal/pkg/db"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Test that SIGTERM stops the service with its ingestion run recorded as interrupted
+func TestService_SIGTERM(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	// Serve one page, then hold the next category request open until the crawl is stopped
+	crawling := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		resp := map[string]interface{}{}
+		switch {
+		case q.Get("list") == "categorymembers" && q.Get("cmcontinue") == "":
+			resp["query"] = map[string]interface{}{"categorymembers": []map[string]interface{}{
+				{"pageid": 1, "ns": 0, "title": "Shutdown Test Game"},
+			}}
+			resp["continue"] = map[string]string{"cmcontinue": "1", "continue": "-||"}
+		case q.Get("list") == "categorymembers":
+			close(crawling)
+			<-r.Context().Done()
+			return
+		case q.Get("prop") == "extracts|info":
+			resp["query"] = map[string]interface{}{"pages": []map[string]interface{}{
+				{"pageid": 1, "ns": 0, "title": "Shutdown Test Game", "extract": "Shutdown Test Game is a video game.", "lastrevid": 1},
+			}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	defer conn.Exec(`DELETE FROM ingest_runs WHERE config->>'api_url' = $1`, server.URL)
+	defer conn.Exec(`DELETE FROM Games WHERE page_id = 1 AND title = 'Shutdown Test Game'`)
+
+	binary := filepath.Join(t.TempDir(), "gamenet")
+	if output, err := exec.Command("go", "build", "-o", binary, "../cmd/gamenet").CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build gamenet: %v\n%s", err, output)
+	}
+
+	cmd := exec.Command(binary)
+	cmd.Env = append(os.Environ(),
+		"HTTP_ADDR=127.0.0.1:0",
+		"NEO4J_HOST=",
+		"ENTITY_EXTRACTOR=gazetteer",
+		"WIKI_API_URL="+server.URL,
+		"WIKI_INFOBOX=false",
+		"WIKI_CACHE_DIR=",
+	)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start gamenet: %v", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	select {
+	case <-crawling:
+	case err := <-exited:
+		t.Fatalf("gamenet exited before crawling: %v", err)
+	case <-time.After(30 * time.Second):
+		cmd.Process.Kill()
+		t.Fatalf("Timed out waiting for the crawl to start")
+	}
+
+	cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case err := <-exited:
+		if err != nil {
+			t.Fatalf("Expected gamenet to exit cleanly, got %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		cmd.Process.Kill()
+		t.Fatalf("Timed out waiting for gamenet to shut down")
+	}
+
+	var status string
+	err = conn.QueryRow(`SELECT status FROM ingest_runs WHERE config->>'api_url' = $1`, server.URL).Scan(&status)
+	if err != nil {
+		t.Fatalf("Failed to read the run: %v", err)
+	}
+	if status != db.RunInterrupted {
+		t.Fatalf("Expected the run to be %s, got %s", db.RunInterrupted, status)
+	}
+}
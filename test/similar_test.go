@@ -0,0 +1,209 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gamenet/internal/pkg/api"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"math"
+	"net/http"
+	"testing"
+)
+
+// Test the Jaccard and Adamic-Adar scores of an overlap and the era score
+func TestOverlapScore(t *testing.T) {
+	// Two shared entities out of 2 and 3: 2 / (2 + 3 - 2)
+	if score := db.OverlapScore(db.MetricJaccard, 2, 3, []int{2, 100}); math.Abs(score-2.0/3) > 1e-9 {
+		t.Fatalf("Expected a Jaccard index of 2/3, got %f", score)
+	}
+	// A rare entity weighs more than a common one
+	rare := db.OverlapScore(db.MetricAdamicAdar, 2, 3, []int{2})
+	common := db.OverlapScore(db.MetricAdamicAdar, 2, 3, []int{100})
+	if math.Abs(rare-1/math.Log(2)) > 1e-9 || common >= rare {
+		t.Fatalf("Expected 1/ln 2 for an entity of two games and less for one of 100, got %f and %f", rare, common)
+	}
+	if score := db.OverlapScore(db.MetricJaccard, 2, 3, nil); score != 0 {
+		t.Fatalf("Expected 0 without shared entities, got %f", score)
+	}
+
+	for _, c := range []struct {
+		a, b  int
+		score float64
+	}{
+		{1998, 1998, 1},
+		{1998, 2003, 0.5},
+		{1990, 2010, 0},
+		{0, 2003, 0},
+	} {
+		if score := db.EraScore(c.a, c.b); math.Abs(score-c.score) > 1e-9 {
+			t.Fatalf("Expected era score %f for %d and %d, got %f", c.score, c.a, c.b, score)
+		}
+	}
+}
+
+// fakeRecommender returns a fixed recommendation and records the options it was asked for.
+type fakeRecommender struct {
+	lastOptions db.SimilarOptions
+}
+
+func (r *fakeRecommender) SimilarGames(ctx context.Context, id int, opts db.SimilarOptions) (db.SimilarGames, error) {
+	r.lastOptions = opts
+	if id != 1 {
+		return db.SimilarGames{}, fmt.Errorf("game %d: %w", id, db.ErrNotFound)
+	}
+	return db.SimilarGames{
+		Game:    db.GameSummary{ID: 1, Title: "Game 1"},
+		Metric:  db.MetricJaccard,
+		Similar: []db.SimilarGame{{GameSummary: db.GameSummary{ID: 2, Title: "Game 2"}, Score: 2.5, Shared: map[string][]string{"series": {"Series"}}}},
+	}, nil
+}
+
+// Test the similar games endpoint and its parameters
+func TestSimilarAPI(t *testing.T) {
+	recommender := &fakeRecommender{}
+	server := api.NewServer(":0")
+	server.HandleSimilar(recommender)
+
+	var similar db.SimilarGames
+	getJSON(t, server, "/games/1/similar?limit=5&metric=adamic-adar", http.StatusOK, &similar)
+	if len(similar.Similar) != 1 || similar.Similar[0].Shared["series"][0] != "Series" {
+		t.Fatalf("Unexpected similar games: %+v", similar)
+	}
+	if recommender.lastOptions.Limit != 5 || recommender.lastOptions.Metric != db.MetricAdamicAdar {
+		t.Fatalf("Expected limit 5 and adamic-adar, got %+v", recommender.lastOptions)
+	}
+
+	var failure map[string]string
+	getJSON(t, server, "/games/2/similar", http.StatusNotFound, &failure)
+	getJSON(t, server, "/games/1/similar?metric=cosine", http.StatusBadRequest, &failure)
+	getJSON(t, server, "/games/1/similar?limit=1000", http.StatusBadRequest, &failure)
+}
+
+// Test ranking similar games over PostgreSQL
+func TestCatalog_SimilarGames(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	defer deleteSimilarGames(conn)
+	gameIDs := seedSimilarGames(t, ctx, conn)
+
+	catalog := db.NewCatalog(conn)
+	for _, metric := range []string{db.MetricJaccard, db.MetricAdamicAdar} {
+		result, err := catalog.SimilarGames(ctx, gameIDs[0], db.SimilarOptions{Metric: metric})
+		if err != nil {
+			t.Fatalf("Failed to find similar games: %v", err)
+		}
+		if len(result.Similar) != 2 || result.Similar[0].ID != gameIDs[1] || result.Similar[1].ID != gameIDs[2] {
+			t.Fatalf("Expected games 2 and 3 by %s, got %+v", metric, result.Similar)
+		}
+		if shared := result.Similar[0].Shared; len(shared["series"]) != 1 || len(shared["developers"]) != 1 {
+			t.Fatalf("Expected game 2 to share the series and developer, got %+v", shared)
+		}
+	}
+
+	if _, err := catalog.SimilarGames(ctx, -1, db.SimilarOptions{}); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+// Test that the Cypher recommender ranks and scores games like the PostgreSQL one
+func TestGraphRecommender_SimilarGames(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+	if err := db.InitNeo4j(); err != nil {
+		t.Fatalf("Failed to connect to Neo4j: %v", err)
+	}
+	defer db.CloseNeo4j()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	defer deleteSimilarGames(conn)
+	gameIDs := seedSimilarGames(t, ctx, conn)
+
+	session := db.Neo4jDriver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+	defer session.Run(`MATCH (g:Game) WHERE g.page_id >= 990901 AND g.page_id <= 990904 DETACH DELETE g`, nil)
+	defer session.Run(`MATCH (e) WHERE e.name IN ['Similar Test Series', 'Similar Test Studio', 'Similar Other Studio', 'Similar Test Console'] DETACH DELETE e`, nil)
+
+	var graphGames []db.GraphGame
+	for i, entities := range similarFixture {
+		graphGames = append(graphGames, db.GraphGame{PageID: 990901 + i, Title: fmt.Sprintf("Similar Test Game %d", i+1), Entities: entities})
+	}
+	if err := db.NewGraphWriter(db.Neo4jDriver).WriteGames(graphGames); err != nil {
+		t.Fatalf("Failed to write games to Neo4j: %v", err)
+	}
+
+	catalog := db.NewCatalog(conn)
+	recommender := db.NewGraphRecommender(db.Neo4jDriver, catalog)
+	for _, metric := range []string{db.MetricJaccard, db.MetricAdamicAdar} {
+		want, err := catalog.SimilarGames(ctx, gameIDs[0], db.SimilarOptions{Metric: metric})
+		if err != nil {
+			t.Fatalf("Failed to find similar games in PostgreSQL: %v", err)
+		}
+		got, err := recommender.SimilarGames(ctx, gameIDs[0], db.SimilarOptions{Metric: metric})
+		if err != nil {
+			t.Fatalf("Failed to find similar games in Neo4j: %v", err)
+		}
+		if len(got.Similar) != len(want.Similar) {
+			t.Fatalf("Expected %d games by %s, got %+v", len(want.Similar), metric, got.Similar)
+		}
+		for i := range want.Similar {
+			if got.Similar[i].ID != want.Similar[i].ID || math.Abs(got.Similar[i].Score-want.Similar[i].Score) > 1e-9 {
+				t.Fatalf("Expected %+v at rank %d by %s, got %+v", want.Similar[i], i+1, metric, got.Similar[i])
+			}
+		}
+	}
+}
+
+// similarFixture holds the entities of four games: game 2 shares series and developer
+// with game 1, game 3 only the platform and game 4 nothing.
+var similarFixture = [][]wiki.Entity{
+	{{Text: "Similar Test Series", Label: "Series"}, {Text: "Similar Test Studio", Label: "Developer"}, {Text: "Similar Test Console", Label: "Platform"}},
+	{{Text: "Similar Test Series", Label: "Series"}, {Text: "Similar Test Studio", Label: "Developer"}},
+	{{Text: "Similar Other Studio", Label: "Developer"}, {Text: "Similar Test Console", Label: "Platform"}},
+	{{Text: "Similar Other Studio", Label: "Developer"}},
+}
+
+// seedSimilarGames stores similarFixture as pages 990901 to 990904, released 2000 to
+// 2003, and returns their IDs.
+func seedSimilarGames(t *testing.T, ctx context.Context, conn *sql.DB) []int {
+	t.Helper()
+	var gameIDs []int
+	for i, entities := range similarFixture {
+		id, err := wiki.UpsertGame(ctx, conn, wiki.GameRecord{
+			PageID:      990901 + i,
+			Title:       fmt.Sprintf("Similar Test Game %d", i+1),
+			ReleaseDate: fmt.Sprintf("200%d", i),
+			Entities:    entities,
+		})
+		if err != nil {
+			t.Fatalf("Failed to upsert game: %v", err)
+		}
+		gameIDs = append(gameIDs, id)
+	}
+	return gameIDs
+}
+
+// deleteSimilarGames deletes the games and entities stored by seedSimilarGames.
+func deleteSimilarGames(conn *sql.DB) {
+	conn.Exec(`DELETE FROM Games WHERE page_id BETWEEN 990901 AND 990904`)
+	conn.Exec(`DELETE FROM Platforms WHERE name = 'Similar Test Console'`)
+	conn.Exec(`DELETE FROM Developers WHERE name IN ('Similar Test Studio', 'Similar Other Studio')`)
+	conn.Exec(`DELETE FROM Series WHERE name = 'Similar Test Series'`)
+}
@@ -0,0 +1,217 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"testing"
+)
+
+// Test that upserting the same page twice updates the game and replaces its entities
+func TestUpsertGame_Idempotent(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	game := wiki.GameRecord{
+		PageID:      990001,
+		Title:       "Upsert Test Game",
+		Summary:     "First summary.",
+		ReleaseDate: "1999",
+		Entities: []wiki.Entity{
+			{Text: "Upsert Studio", Label: "Developer"},
+			{Text: "Upsert Studio", Label: "Developer"},
+			{Text: "Upsert Console", Label: "Platform"},
+		},
+	}
+	defer conn.Exec(`DELETE FROM Games WHERE page_id = $1`, game.PageID)
+
+	firstID, err := wiki.UpsertGame(ctx, conn, game)
+	if err != nil {
+		t.Fatalf("Failed to upsert game: %v", err)
+	}
+
+	// Re-running with a new summary and a different platform must reuse the row
+	game.Summary = "Second summary."
+	game.Entities = []wiki.Entity{
+		{Text: "Upsert Studio", Label: "Developer"},
+		{Text: "Upsert Handheld", Label: "Platform"},
+	}
+	secondID, err := wiki.UpsertGame(ctx, conn, game)
+	if err != nil {
+		t.Fatalf("Failed to upsert game again: %v", err)
+	}
+	if firstID != secondID {
+		t.Fatalf("Expected the same game ID, got %d and %d", firstID, secondID)
+	}
+
+	var count int
+	var summary string
+	err = conn.QueryRow(`SELECT count(*), max(summary) FROM Games WHERE page_id = $1`, game.PageID).Scan(&count, &summary)
+	if err != nil {
+		t.Fatalf("Failed to count games: %v", err)
+	}
+	if count != 1 || summary != "Second summary." {
+		t.Fatalf("Expected one updated game, got %d rows with summary %q", count, summary)
+	}
+
+	var platforms []string
+	rows, err := conn.Query(`SELECT p.name FROM GamePlatforms gp JOIN Platforms p ON p.id = gp.platform_id WHERE gp.game_id = $1`, firstID)
+	if err != nil {
+		t.Fatalf("Failed to read platforms: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		platforms = append(platforms, name)
+	}
+	if len(platforms) != 1 || platforms[0] != "Upsert Handheld" {
+		t.Fatalf("Expected the platform set to be replaced, got %v", platforms)
+	}
+
+	if err := verifyGameInsertion(conn, game.Title, game.Entities); err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+}
+
+// Test that two pages can swap titles, each keeping its own game row
+func TestUpsertGame_SwapTitles(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	first := wiki.GameRecord{PageID: 990011, Title: "Swap Test Game"}
+	second := wiki.GameRecord{PageID: 990012, Title: "Swap Test Game (remake)"}
+	defer conn.Exec(`DELETE FROM Games WHERE page_id IN ($1, $2)`, first.PageID, second.PageID)
+
+	firstID, err := wiki.UpsertGame(ctx, conn, first)
+	if err != nil {
+		t.Fatalf("Failed to upsert first game: %v", err)
+	}
+	secondID, err := wiki.UpsertGame(ctx, conn, second)
+	if err != nil {
+		t.Fatalf("Failed to upsert second game: %v", err)
+	}
+
+	// The first page is refreshed under the second's title before the second is refreshed
+	first.Title, second.Title = second.Title, first.Title
+	if id, err := wiki.UpsertGame(ctx, conn, first); err != nil || id != firstID {
+		t.Fatalf("Expected the first page to keep game %d under its new title, got %d: %v", firstID, id, err)
+	}
+	if id, err := wiki.UpsertGame(ctx, conn, second); err != nil || id != secondID {
+		t.Fatalf("Expected the second page to keep game %d under its new title, got %d: %v", secondID, id, err)
+	}
+}
+
+// Test that one name linking to two pages is stored as two entities, not merged into one
+func TestUpsertGame_SameNameDifferentLinks(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	// Both links lose their disambiguation when canonicalized to "Link Test Studio"
+	first := wiki.GameRecord{PageID: 990021, Title: "Link Test Game", Entities: []wiki.Entity{
+		{Text: "Link Test Studio", Label: "Developer", Link: "Link Test Studio (company)"},
+	}}
+	second := wiki.GameRecord{PageID: 990022, Title: "Link Test Game 2", Entities: []wiki.Entity{
+		{Text: "Link Test Studio", Label: "Developer", Link: "Link Test Studio (publisher)"},
+	}}
+	defer conn.Exec(`DELETE FROM Developers WHERE name LIKE 'Link Test Studio%'`)
+	defer conn.Exec(`DELETE FROM Games WHERE page_id IN ($1, $2)`, first.PageID, second.PageID)
+
+	for _, game := range []wiki.GameRecord{first, second, first} {
+		if _, err := wiki.UpsertGame(ctx, conn, game); err != nil {
+			t.Fatalf("Failed to upsert %s: %v", game.Title, err)
+		}
+	}
+
+	developers := map[string]string{}
+	rows, err := conn.Query(`SELECT name, wiki_title FROM Developers WHERE wiki_title LIKE 'Link Test Studio%'`)
+	if err != nil {
+		t.Fatalf("Failed to read developers: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, wikiTitle string
+		rows.Scan(&name, &wikiTitle)
+		developers[wikiTitle] = name
+	}
+	if len(developers) != 2 || developers["Link Test Studio (company)"] != "Link Test Studio" ||
+		developers["Link Test Studio (publisher)"] != "Link Test Studio (publisher)" {
+		t.Fatalf("Expected one developer per page, the second under its page title, got %v", developers)
+	}
+
+	// The graph is written with the names the entities were stored under
+	second.Entities = append(second.Entities, wiki.Entity{Text: "Link Test Studio (publisher)", Label: "Developer", Link: "Link Test Studio (publisher)", Confidence: 1})
+	_, stored, err := wiki.UpsertGameEntities(ctx, conn, second)
+	if err != nil {
+		t.Fatalf("Failed to upsert %s: %v", second.Title, err)
+	}
+	if len(stored) != 1 || stored[0].Text != "Link Test Studio (publisher)" || stored[0].Link != "Link Test Studio (publisher)" || stored[0].Confidence != 1 {
+		t.Fatalf("Expected the developer once under its stored name, got %+v", stored)
+	}
+}
+
+// Test that publishers are stored in PostgreSQL next to developers
+func TestUpsertGame_Publishers(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	game := wiki.GameRecord{PageID: 990031, Title: "Publisher Test Game", Entities: []wiki.Entity{
+		{Text: "Publisher Test Studio", Label: "Developer"},
+		{Text: "Publisher Test House", Label: "Publisher"},
+	}}
+	defer conn.Exec(`DELETE FROM Publishers WHERE name = 'Publisher Test House'`)
+	defer conn.Exec(`DELETE FROM Games WHERE page_id = $1`, game.PageID)
+
+	gameID, err := wiki.UpsertGame(ctx, conn, game)
+	if err != nil {
+		t.Fatalf("Failed to upsert game: %v", err)
+	}
+	entities, err := wiki.GameEntities(ctx, conn, gameID, 0)
+	if err != nil {
+		t.Fatalf("Failed to read entities: %v", err)
+	}
+	if len(entities) != 2 || entities[1].Label != "Publisher" || entities[1].Text != "Publisher Test House" {
+		t.Fatalf("Expected the developer and the publisher, got %+v", entities)
+	}
+}
+
+// Test that a game without a title is rejected before touching the database
+func TestUpsertGame_EmptyTitle(t *testing.T) {
+	_, err := wiki.UpsertGame(context.Background(), nil, wiki.GameRecord{PageID: 1})
+	if !errors.Is(err, wiki.ErrEmptyTitle) {
+		t.Fatalf("Expected ErrEmptyTitle, got %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gamenet/internal/pkg/wiki"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// cachingWiki wraps fakeWiki with prop=info revisions that can be bumped, an ETag on
+// category listings, and counters of the requests made per kind.
+type cachingWiki struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	requests  map[string]int // Requests per list/prop value
+	revisions map[int]int64  // Latest revision per page ID
+}
+
+func newCachingWiki(t *testing.T) *cachingWiki {
+	t.Helper()
+
+	inner := fakeWiki(t)
+	handler := inner.Config.Handler
+	inner.Close()
+
+	wiki := &cachingWiki{requests: map[string]int{}, revisions: map[int]int64{1: 11, 2: 21, 3: 31, 4: 41}}
+	wiki.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		kind := q.Get("list") + q.Get("prop")
+
+		wiki.mu.Lock()
+		wiki.requests[kind]++
+		wiki.mu.Unlock()
+
+		switch kind {
+		case "info":
+			var pages []map[string]interface{}
+			wiki.mu.Lock()
+			for _, id := range strings.Split(q.Get("pageids"), "|") {
+				pageID, _ := strconv.Atoi(id)
+				pages = append(pages, map[string]interface{}{"pageid": pageID, "ns": 0, "lastrevid": wiki.revisions[pageID]})
+			}
+			wiki.mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"query": map[string]interface{}{"pages": pages}})
+		case "categorymembers":
+			// Category listings never change here, so answer revalidations with 304
+			etag := `"` + q.Get("cmtitle") + q.Get("cmcontinue") + `"`
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			handler.ServeHTTP(w, r)
+		default:
+			handler.ServeHTTP(w, r)
+		}
+	}))
+	return wiki
+}
+
+// count returns how many requests of the given kind were made and resets the counter.
+func (w *cachingWiki) count(kind string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := w.requests[kind]
+	w.requests[kind] = 0
+	return n
+}
+
+// walkTitles walks Category:Video games and returns the titles and extracts it reports.
+func walkTitles(t *testing.T, client *wiki.Client) map[string]string {
+	t.Helper()
+
+	pages := map[string]string{}
+	err := client.WalkCategory(context.Background(), "Video games", func(batch []wiki.Page) error {
+		for _, page := range batch {
+			pages[page.Title] = page.Extract
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk category: %v", err)
+	}
+	return pages
+}
+
+// Test that cached pages are reused until their revision changes, and listings are revalidated
+func TestClientCache_Revisions(t *testing.T) {
+	server := newCachingWiki(t)
+	defer server.Close()
+
+	cache, err := wiki.NewCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	client := wiki.NewClient(server.URL)
+	client.RateLimiter = nil
+	client.Cache = cache
+
+	first := walkTitles(t, client)
+	if server.count("extracts|info") == 0 || server.count("revisions") == 0 {
+		t.Fatalf("Expected the first walk to fetch extracts and wikitext")
+	}
+	server.count("categorymembers")
+
+	// Nothing changed: only the revisions are looked up and listings revalidated
+	second := walkTitles(t, client)
+	if len(second) != len(first) {
+		t.Fatalf("Expected the same pages from the cache, got %v", second)
+	}
+	if n := server.count("extracts|info") + server.count("revisions"); n != 0 {
+		t.Fatalf("Expected page content to come from the cache, made %d requests", n)
+	}
+	if server.count("info") == 0 {
+		t.Fatalf("Expected the current revisions to be checked")
+	}
+	stats := cache.Stats()
+	if stats.Hits == 0 || stats.NotModified != int64(server.count("categorymembers")) {
+		t.Fatalf("Expected cache hits and every listing answered with 304, got %+v", stats)
+	}
+
+	// Editing a page invalidates the cached responses that include it
+	server.mu.Lock()
+	server.revisions[3] = 32
+	server.mu.Unlock()
+	walkTitles(t, client)
+	if server.count("extracts|info") == 0 {
+		t.Fatalf("Expected the edited page to be fetched again")
+	}
+}
+
+// Test that an offline client serves a previous walk from the cache without network access
+func TestClientCache_Offline(t *testing.T) {
+	server := newCachingWiki(t)
+	dir := t.TempDir()
+
+	online := wiki.NewClient(server.URL)
+	online.RateLimiter = nil
+	online.Cache = &wiki.Cache{Dir: dir}
+	expected := walkTitles(t, online)
+	server.Close()
+
+	offline := wiki.NewClient(server.URL)
+	offline.Cache = &wiki.Cache{Dir: dir, Offline: true}
+	pages := walkTitles(t, offline)
+	if len(pages) != len(expected) {
+		t.Fatalf("Expected %d pages offline, got %v", len(expected), pages)
+	}
+	for title, extract := range expected {
+		if pages[title] != extract {
+			t.Fatalf("Expected the cached extract of %s, got %q", title, pages[title])
+		}
+	}
+
+	// A category that was never fetched cannot be served offline
+	err := offline.CategoryMembers(context.Background(), "Unknown games", func(wiki.CategoryMember) error { return nil })
+	if !errors.Is(err, wiki.ErrNotCached) {
+		t.Fatalf("Expected ErrNotCached, got %v", err)
+	}
+}
+
+// Test that an extract fetched through a redirect is refetched when only its target is edited
+func TestClientCache_RedirectTarget(t *testing.T) {
+	// Page 5 is a redirect listed in the category; it leads to page 6
+	var mu sync.Mutex
+	targetRevision := int64(61)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mu.Lock()
+		defer mu.Unlock()
+
+		query := map[string]interface{}{}
+		switch q.Get("list") + q.Get("prop") {
+		case "categorymembers":
+			query["categorymembers"] = []map[string]interface{}{{"pageid": 5, "ns": 0, "title": "Old Title"}}
+		case "info":
+			page := map[string]interface{}{"pageid": 5, "ns": 0, "title": "Old Title", "redirect": true, "lastrevid": 51}
+			if q.Get("redirects") != "" {
+				query["redirects"] = []map[string]string{{"from": "Old Title", "to": "New Title"}}
+				page = map[string]interface{}{"pageid": 6, "ns": 0, "title": "New Title", "lastrevid": targetRevision}
+			}
+			query["pages"] = []map[string]interface{}{page}
+		case "extracts|info":
+			query["redirects"] = []map[string]string{{"from": "Old Title", "to": "New Title"}}
+			query["pages"] = []map[string]interface{}{{
+				"pageid": 6, "ns": 0, "title": "New Title", "lastrevid": targetRevision,
+				"extract": "Revision " + strconv.FormatInt(targetRevision, 10) + ".",
+			}}
+		default:
+			t.Errorf("unexpected request: %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"query": query})
+	}))
+	defer server.Close()
+
+	client := wiki.NewClient(server.URL)
+	client.RateLimiter = nil
+	client.IncludeWikitext = false
+	client.Cache = &wiki.Cache{Dir: t.TempDir()}
+
+	if extract := walkTitles(t, client)["New Title"]; extract != "Revision 61." {
+		t.Fatalf("Expected the target's extract, got %q", extract)
+	}
+
+	// The redirect itself is unchanged; only the article it leads to is edited
+	mu.Lock()
+	targetRevision = 62
+	mu.Unlock()
+	if extract := walkTitles(t, client)["New Title"]; extract != "Revision 62." {
+		t.Fatalf("Expected the edited target's extract, got %q", extract)
+	}
+}
+
+// Test that index entries with a malformed blob are treated as misses instead of read
+func TestClientCache_MalformedBlob(t *testing.T) {
+	server := newCachingWiki(t)
+	defer server.Close()
+
+	dir := t.TempDir()
+	client := wiki.NewClient(server.URL)
+	client.RateLimiter = nil
+	client.Cache = &wiki.Cache{Dir: dir}
+	expected := walkTitles(t, client)
+	server.count("extracts|info")
+
+	// Truncate the blob of every index entry, as a hand-edited cache might
+	blobs := []string{"", "a", "../../etc"}
+	n := 0
+	err := filepath.WalkDir(filepath.Join(dir, "index"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		entry["blob"] = blobs[n%len(blobs)]
+		n++
+		data, _ = json.Marshal(entry)
+		return os.WriteFile(path, data, 0o644)
+	})
+	if err != nil || n == 0 {
+		t.Fatalf("Failed to edit the cache index (%d entries): %v", n, err)
+	}
+
+	pages := walkTitles(t, client)
+	if len(pages) != len(expected) {
+		t.Fatalf("Expected %d pages after refetching, got %v", len(expected), pages)
+	}
+	if server.count("extracts|info") == 0 {
+		t.Fatalf("Expected the extracts to be fetched again")
+	}
+}
@@ -0,0 +1,187 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"gamenet/internal/pkg/wiki"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeWiki is a minimal stand-in for the MediaWiki API serving a small category tree:
+//
+//	Category:Video games -> Zelda, Mario, Category:Nintendo games
+//	Category:Nintendo games -> Mario, Metroid, Category:Deep games
+//	Category:Deep games -> Kirby
+func fakeWiki(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	members := map[string][]map[string]interface{}{
+		"Category:Video games": {
+			{"pageid": 1, "ns": 0, "title": "The Legend of Zelda"},
+			{"pageid": 2, "ns": 0, "title": "Super Mario Bros."},
+			{"pageid": 100, "ns": 14, "title": "Category:Nintendo games"},
+		},
+		"Category:Nintendo games": {
+			{"pageid": 2, "ns": 0, "title": "Super Mario Bros."},
+			{"pageid": 3, "ns": 0, "title": "Metroid"},
+			{"pageid": 101, "ns": 14, "title": "Category:Deep games"},
+		},
+		"Category:Deep games": {
+			{"pageid": 4, "ns": 0, "title": "Kirby"},
+		},
+	}
+	titles := map[int]string{1: "The Legend of Zelda", 2: "Super Mario Bros.", 3: "Metroid", 4: "Kirby"}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		resp := map[string]interface{}{}
+
+		switch {
+		case q.Get("list") == "categorymembers":
+			// Serve one member per response to exercise cmcontinue handling
+			all := members[q.Get("cmtitle")]
+			offset, _ := strconv.Atoi(q.Get("cmcontinue"))
+			if offset < len(all) {
+				resp["query"] = map[string]interface{}{"categorymembers": all[offset : offset+1]}
+			}
+			if offset+1 < len(all) {
+				resp["continue"] = map[string]string{"cmcontinue": strconv.Itoa(offset + 1), "continue": "-||"}
+			}
+		case q.Get("prop") == "extracts|info":
+			var pages []map[string]interface{}
+			for _, id := range strings.Split(q.Get("pageids"), "|") {
+				pageID, _ := strconv.Atoi(id)
+				pages = append(pages, map[string]interface{}{
+					"pageid":    pageID,
+					"ns":        0,
+					"title":     titles[pageID],
+					"extract":   titles[pageID] + " is a video game.",
+					"lastrevid": 1000 + pageID,
+				})
+			}
+			resp["query"] = map[string]interface{}{"pages": pages}
+		case q.Get("prop") == "revisions":
+			var pages []map[string]interface{}
+			for _, id := range strings.Split(q.Get("pageids"), "|") {
+				pageID, _ := strconv.Atoi(id)
+				content := "{{Infobox video game\n| developer = [[Nintendo]]\n}}\n" + titles[pageID]
+				pages = append(pages, map[string]interface{}{
+					"pageid":    pageID,
+					"ns":        0,
+					"title":     titles[pageID],
+					"revisions": []map[string]interface{}{{"revid": 1000 + pageID, "slots": map[string]interface{}{"main": map[string]string{"content": content}}}},
+				})
+			}
+			resp["query"] = map[string]interface{}{"pages": pages}
+		default:
+			t.Errorf("unexpected request: %s", r.URL.RawQuery)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+// Test walking a category tree with continuation and a depth limit
+func TestWalkCategory(t *testing.T) {
+	server := fakeWiki(t)
+	defer server.Close()
+
+	client := wiki.NewClient(server.URL)
+	client.MaxDepth = 1
+	client.BatchSize = 2
+
+	var batches [][]wiki.Page
+	err := client.WalkCategory(context.Background(), "video_games", func(batch []wiki.Page) error {
+		batches = append(batches, batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk category: %v", err)
+	}
+
+	// Kirby lives two levels down and must be excluded, Mario must only appear once
+	seen := map[string]int{}
+	for _, batch := range batches {
+		if len(batch) > 2 {
+			t.Fatalf("Expected batches of at most 2 pages, got %d", len(batch))
+		}
+		for _, page := range batch {
+			seen[page.Title]++
+			if page.Extract == "" {
+				t.Fatalf("Expected an extract for %s", page.Title)
+			}
+			if entities := wiki.InfoboxEntities(page.Wikitext); len(entities) != 1 || entities[0].Text != "Nintendo" {
+				t.Fatalf("Expected the infobox of %s to list Nintendo, got %v", page.Title, entities)
+			}
+		}
+	}
+	expected := []string{"The Legend of Zelda", "Super Mario Bros.", "Metroid"}
+	for _, title := range expected {
+		if seen[title] != 1 {
+			t.Fatalf("Expected %s exactly once, saw it %d times", title, seen[title])
+		}
+	}
+	if len(seen) != len(expected) {
+		t.Fatalf("Expected %d pages, got %v", len(expected), seen)
+	}
+}
+
+// Test that pages rejected by SkipPage are neither fetched nor reported
+func TestWalkCategory_SkipPage(t *testing.T) {
+	server := fakeWiki(t)
+	defer server.Close()
+
+	client := wiki.NewClient(server.URL)
+	client.SkipPage = func(pageID int) bool { return pageID == 2 }
+
+	var titles []string
+	err := client.WalkCategory(context.Background(), "video_games", func(batch []wiki.Page) error {
+		for _, page := range batch {
+			titles = append(titles, page.Title)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk category: %v", err)
+	}
+	if len(titles) != 2 || titles[0] != "The Legend of Zelda" || titles[1] != "Metroid" {
+		t.Fatalf("Expected Super Mario Bros. to be skipped, got %v", titles)
+	}
+}
+
+// Test that FetchWikiData honours WIKI_API_URL and returns all pages in one response
+func TestFetchWikiData(t *testing.T) {
+	server := fakeWiki(t)
+	defer server.Close()
+
+	t.Setenv("WIKI_API_URL", server.URL)
+	t.Setenv("WIKI_CATEGORY_DEPTH", "2")
+
+	resp, err := wiki.FetchWikiData("Category:Video games")
+	if err != nil {
+		t.Fatalf("Failed to fetch wiki data: %v", err)
+	}
+	if len(resp.Query.Pages) != 4 {
+		t.Fatalf("Expected 4 pages, got %d", len(resp.Query.Pages))
+	}
+}
+
+// Test that API errors reported in the response body are surfaced
+func TestWalkCategory_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":{"code":"badvalue","info":"Unrecognized value"}}`))
+	}))
+	defer server.Close()
+
+	err := wiki.NewClient(server.URL).WalkCategory(context.Background(), "Video games", func([]wiki.Page) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "badvalue") {
+		t.Fatalf("Expected API error, got %v", err)
+	}
+}
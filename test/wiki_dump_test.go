@@ -0,0 +1,160 @@
+package test
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"gamenet/internal/pkg/wiki"
+	"strings"
+	"testing"
+)
+
+// dumpXML builds a pages-articles export holding the given pages.
+func dumpXML(pages ...string) string {
+	return `<mediawiki xmlns="http://www.mediawiki.org/xml/export-0.11/" version="0.11" xml:lang="en">
+  <siteinfo><sitename>Wikipedia</sitename><namespaces><namespace key="14">Category</namespace></namespaces></siteinfo>
+` + strings.Join(pages, "\n") + `
+</mediawiki>`
+}
+
+// dumpPage renders a single <page> element; redirect is the redirect target, if any.
+func dumpPage(id, ns int, title, redirect, wikitext string) string {
+	var text strings.Builder
+	xml.EscapeText(&text, []byte(wikitext))
+	redirectTag := ""
+	if redirect != "" {
+		redirectTag = fmt.Sprintf(`<redirect title=%q />`, redirect)
+	}
+	return fmt.Sprintf(`  <page>
+    <title>%s</title>
+    <ns>%d</ns>
+    <id>%d</id>
+    %s
+    <revision>
+      <id>%d</id>
+      <text bytes="%d" xml:space="preserve">%s</text>
+    </revision>
+  </page>`, title, ns, id, redirectTag, id*10, len(wikitext), text.String())
+}
+
+// Test that only video game articles are read from a dump, as the API would report them
+func TestDumpReader_Walk(t *testing.T) {
+	dump := dumpXML(
+		dumpPage(1, 0, "The Legend of Zelda (video game)", "", zeldaWikitext+"\n\n[[Category:1986 video games]]"),
+		dumpPage(2, 0, "Tetris", "", "'''Tetris''' is a [[puzzle video game]].\n\n== Gameplay ==\nBlocks fall.\n[[Category:Puzzle video games]]"),
+		dumpPage(3, 0, "Nintendo", "", "'''Nintendo''' is a company.\n[[Category:Video game companies]]"),
+		dumpPage(4, 0, "Zelda 1", "The Legend of Zelda (video game)", "#REDIRECT [[The Legend of Zelda (video game)]]"),
+		dumpPage(5, 14, "Category:Video games", "", "[[Category:Games]]"),
+		dumpPage(6, 0, "Chess", "", "'''Chess''' is a board game.\n[[Category:Board games]]"),
+	)
+
+	reader := wiki.NewDumpReader(wiki.DefaultCategory)
+	reader.BatchSize = 1
+	var pages []wiki.Page
+	err := reader.Walk(context.Background(), strings.NewReader(dump), func(batch []wiki.Page) error {
+		if len(batch) != 1 {
+			t.Fatalf("Expected batches of 1 page, got %d", len(batch))
+		}
+		pages = append(pages, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk dump: %v", err)
+	}
+
+	if len(pages) != 2 || pages[0].PageID != 1 || pages[1].PageID != 2 {
+		t.Fatalf("Expected the Zelda and Tetris articles, got %+v", pages)
+	}
+	zelda := pages[0]
+	if zelda.Extract != "The Legend of Zelda is a 1986 action-adventure game." {
+		t.Fatalf("Unexpected extract %q", zelda.Extract)
+	}
+	if zelda.LastRevID != 10 || zelda.Wikitext == "" {
+		t.Fatalf("Expected the revision ID and wikitext to be kept, got %+v", zelda)
+	}
+	if entities := wiki.InfoboxEntities(zelda.Wikitext); len(entities) == 0 {
+		t.Fatalf("Expected the infobox to survive the dump round trip")
+	}
+	if pages[1].Extract != "Tetris is a puzzle video game." {
+		t.Fatalf("Expected only the introduction of Tetris, got %q", pages[1].Extract)
+	}
+}
+
+// Test that stored pages are skipped and the infobox filter can be turned off
+func TestDumpReader_Filters(t *testing.T) {
+	dump := dumpXML(
+		dumpPage(1, 0, "Zelda", "", zeldaWikitext),
+		dumpPage(2, 0, "Tetris", "", "'''Tetris'''\n[[Category:Puzzle video games|Tetris]]"),
+		dumpPage(3, 0, "Doom", "", "'''Doom'''\n[[Category:1993 video games]]"),
+	)
+
+	reader := wiki.NewDumpReader("Video games")
+	reader.Infobox = false
+	reader.IncludeWikitext = false
+	reader.SkipPage = func(pageID int) bool { return pageID == 3 }
+
+	var ids []int
+	err := reader.Walk(context.Background(), strings.NewReader(dump), func(batch []wiki.Page) error {
+		for _, page := range batch {
+			if page.Wikitext != "" {
+				t.Errorf("Expected no wikitext for %s", page.Title)
+			}
+			ids = append(ids, page.PageID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk dump: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("Expected only Tetris, got %v", ids)
+	}
+}
+
+// Test that list and topic pages ending in the category name are not taken for games
+func TestDumpReader_ListPages(t *testing.T) {
+	reader := wiki.NewDumpReader(wiki.DefaultCategory)
+	reader.Infobox = false
+
+	pages := map[string]bool{
+		"[[Category:Lists of video games]]":                      false,
+		"[[Category:History of video games]]":                    false,
+		"[[Category:Lists of 2010 video games]]":                 false,
+		"[[Category:Video game companies]]":                      false,
+		"[[Category:Video games]]":                               true,
+		"[[Category:2010 video games]]":                          true,
+		"[[Category:Role-playing video games]]":                  true,
+		"[[Category:Video games developed in Japan]]":            true,
+		"[[Category:Multiplayer and single-player video games]]": true,
+	}
+	for wikitext, want := range pages {
+		if got := reader.Match(wikitext); got != want {
+			t.Fatalf("Expected Match(%q) to be %v, got %v", wikitext, want, got)
+		}
+	}
+}
+
+// Test turning the introduction of an article into plain text
+func TestPlainTextExtract(t *testing.T) {
+	wikitext := `{{Short description|Game}}
+{{Infobox video game
+| title = Example
+
+| developer = [[Studio]]
+}}
+[[File:Cover.png|thumb|The [[box art]]]]
+'''''Example''''' is a game by [[Studio|Example Studio]].<ref>{{cite web|title=x}}</ref>
+
+It was released in 2001.<!-- check -->
+{| class="wikitable"
+| Score || 9
+|}
+__NOTOC__
+== Plot ==
+Not part of the extract.`
+
+	expected := "Example is a game by Example Studio.\nIt was released in 2001."
+	if extract := wiki.PlainTextExtract(wikitext); extract != expected {
+		t.Fatalf("Expected %q, got %q", expected, extract)
+	}
+}
@@ -0,0 +1,176 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"gamenet/internal/pkg/db"
+	"gamenet/internal/pkg/wiki"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// redirectWiki serves a category listing both an article and a redirect to it, and
+// answers title lookups with the normalization and redirect lists of the real API.
+func redirectWiki(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var resp map[string]interface{}
+
+		switch {
+		case q.Get("list") == "categorymembers":
+			resp = map[string]interface{}{"query": map[string]interface{}{"categorymembers": []map[string]interface{}{
+				{"pageid": 10, "ns": 0, "title": "The Legend of Zelda (video game)"},
+				{"pageid": 11, "ns": 0, "title": "Zelda 1"},
+			}}}
+		case q.Get("prop") == "extracts|info":
+			if q.Get("redirects") != "1" {
+				t.Errorf("Expected extracts to follow redirects")
+			}
+			// Page 11 redirects to page 10, which is reported once
+			resp = map[string]interface{}{"query": map[string]interface{}{
+				"redirects": []map[string]string{{"from": "Zelda 1", "to": "The Legend of Zelda (video game)"}},
+				"pages": []map[string]interface{}{
+					{"pageid": 10, "ns": 0, "title": "The Legend of Zelda (video game)", "extract": "A 1986 game.", "lastrevid": 100},
+				},
+			}}
+		case q.Get("prop") == "info" && q.Get("titles") != "":
+			resp = map[string]interface{}{"query": map[string]interface{}{
+				"normalized": []map[string]string{{"from": "zelda_1", "to": "Zelda 1"}},
+				"redirects":  []map[string]string{{"from": "Zelda 1", "to": "The Legend of Zelda (video game)"}},
+				"pages": []map[string]interface{}{
+					{"pageid": 10, "ns": 0, "title": "The Legend of Zelda (video game)", "lastrevid": 100},
+					{"ns": 0, "title": "No Such Game", "missing": true},
+				},
+			}}
+		default:
+			t.Errorf("Unexpected request %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+// Test that a redirect listed in a category resolves to its target, reported once with its alias
+func TestWalkCategory_Redirects(t *testing.T) {
+	server := redirectWiki(t)
+	defer server.Close()
+
+	client := wiki.NewClient(server.URL)
+	client.RateLimiter = nil
+	client.IncludeWikitext = false
+
+	var pages []wiki.Page
+	err := client.WalkCategory(context.Background(), "Video games", func(batch []wiki.Page) error {
+		pages = append(pages, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk category: %v", err)
+	}
+	if len(pages) != 1 || pages[0].PageID != 10 {
+		t.Fatalf("Expected only the target article, got %+v", pages)
+	}
+	if len(pages[0].Aliases) != 1 || pages[0].Aliases[0] != "Zelda 1" {
+		t.Fatalf("Expected the redirect as an alias, got %v", pages[0].Aliases)
+	}
+}
+
+// Test that a redirect whose target a resumed run has stored is skipped before its wikitext is fetched
+func TestWalkCategory_SkipRedirectTarget(t *testing.T) {
+	server := redirectWiki(t)
+	defer server.Close()
+
+	client := wiki.NewClient(server.URL)
+	client.RateLimiter = nil
+	client.SkipPage = func(pageID int) bool { return pageID == 10 }
+
+	var pages []wiki.Page
+	err := client.WalkCategory(context.Background(), "Video games", func(batch []wiki.Page) error {
+		pages = append(pages, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk category: %v", err)
+	}
+	if len(pages) != 0 {
+		t.Fatalf("Expected the redirect to its stored target to be skipped, got %+v", pages)
+	}
+}
+
+// Test resolving titles through normalization and redirects
+func TestResolveTitles(t *testing.T) {
+	server := redirectWiki(t)
+	defer server.Close()
+
+	client := wiki.NewClient(server.URL)
+	client.RateLimiter = nil
+
+	resolved, err := client.ResolveTitles(context.Background(), []string{"zelda_1", "The Legend of Zelda (video game)", "No Such Game"})
+	if err != nil {
+		t.Fatalf("Failed to resolve titles: %v", err)
+	}
+	for _, title := range []string{"zelda_1", "The Legend of Zelda (video game)"} {
+		if page := resolved[title]; page.PageID != 10 || page.Title != "The Legend of Zelda (video game)" {
+			t.Fatalf("Expected %q to resolve to page 10, got %+v", title, page)
+		}
+	}
+	if !resolved["No Such Game"].Missing {
+		t.Fatalf("Expected the unknown title to be missing, got %+v", resolved["No Such Game"])
+	}
+}
+
+// Test normalizing titles like MediaWiki
+func TestNormalizeTitle(t *testing.T) {
+	tests := map[string]string{
+		"zelda_1":                     "Zelda 1",
+		"  Super  Mario\tBros. ":      "Super Mario Bros.",
+		"ōkami":                       "Ōkami",
+		"The Legend of Zelda":         "The Legend of Zelda",
+		"":                            "",
+		"iPhone_games_of_the_year_2x": "IPhone games of the year 2x",
+	}
+	for title, expected := range tests {
+		if normalized := wiki.NormalizeTitle(title); normalized != expected {
+			t.Errorf("NormalizeTitle(%q) = %q, expected %q", title, normalized, expected)
+		}
+	}
+}
+
+// Test that a game can be found by any of its titles, including ones it had before a move
+func TestFindGameID_Aliases(t *testing.T) {
+	conn, err := db.InitPostgres()
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := db.MigrateUp(ctx, conn); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	defer conn.Exec(`DELETE FROM Games WHERE page_id = 990501`)
+
+	game := wiki.GameRecord{PageID: 990501, Title: "Alias Test Game (video game)", Aliases: []string{"Alias Test 1"}}
+	gameID, err := wiki.UpsertGame(ctx, conn, game)
+	if err != nil {
+		t.Fatalf("Failed to upsert game: %v", err)
+	}
+
+	// The page is moved; the old title stays an alias
+	game.Title = "Alias Test Game"
+	if _, err := wiki.UpsertGame(ctx, conn, game); err != nil {
+		t.Fatalf("Failed to upsert moved game: %v", err)
+	}
+
+	for _, title := range []string{"Alias Test Game", "alias_Test_Game_(video_game)", "alias Test 1"} {
+		id, err := wiki.FindGameID(ctx, conn, title)
+		if err != nil || id != gameID {
+			t.Fatalf("Expected %q to find game %d, got %d (%v)", title, gameID, id, err)
+		}
+	}
+	if _, err := wiki.FindGameID(ctx, conn, "Alias Test 2"); err == nil {
+		t.Fatalf("Expected an unknown title not to be found")
+	}
+}
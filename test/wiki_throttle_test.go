@@ -0,0 +1,146 @@
+package test
+
+import (
+	"context"
+	"gamenet/internal/pkg/wiki"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// throttlingWiki returns a server answering the first failures requests with the given
+// handler and every later request with an empty category listing.
+func throttlingWiki(t *testing.T, failures int32, fail http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "GameNet-test/1.0 (test@example.org)" {
+			t.Errorf("Expected the configured User-Agent, got %q", r.Header.Get("User-Agent"))
+		}
+		if r.URL.Query().Get("maxlag") != "5" {
+			t.Errorf("Expected maxlag=5, got %q", r.URL.Query().Get("maxlag"))
+		}
+		if calls.Add(1) <= failures {
+			fail(w, r)
+			return
+		}
+		w.Write([]byte(`{"query": {"categorymembers": []}}`))
+	}))
+	return server, &calls
+}
+
+// newThrottleClient returns a client with fast retries for the tests.
+func newThrottleClient(url string) *wiki.Client {
+	client := wiki.NewClient(url)
+	client.UserAgent = "GameNet-test/1.0 (test@example.org)"
+	client.BackoffBase = time.Millisecond
+	client.BackoffMax = 10 * time.Millisecond
+	client.RateLimiter = nil
+	return client
+}
+
+// Test that 429 responses are retried and counted as throttles
+func TestClient_RetriesTooManyRequests(t *testing.T) {
+	server, calls := throttlingWiki(t, 2, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+	defer server.Close()
+
+	client := newThrottleClient(server.URL)
+	if err := client.CategoryMembers(context.Background(), "Video games", func(wiki.CategoryMember) error { return nil }); err != nil {
+		t.Fatalf("Expected the request to succeed after retries, got %v", err)
+	}
+
+	stats := client.Stats()
+	if calls.Load() != 3 || stats.Requests != 3 || stats.Retries != 2 || stats.Throttled != 2 {
+		t.Fatalf("Expected 3 requests with 2 throttled retries, got %d calls and %+v", calls.Load(), stats)
+	}
+}
+
+// Test that a maxlag error waits for Retry-After before retrying
+func TestClient_MaxLag(t *testing.T) {
+	server, _ := throttlingWiki(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.Write([]byte(`{"error": {"code": "maxlag", "info": "Waiting for a database server: 7 seconds lagged."}}`))
+	})
+	defer server.Close()
+
+	client := newThrottleClient(server.URL)
+	start := time.Now()
+	if err := client.CategoryMembers(context.Background(), "Video games", func(wiki.CategoryMember) error { return nil }); err != nil {
+		t.Fatalf("Expected the request to succeed after the lag cleared, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("Expected to wait for Retry-After, retried after %s", elapsed)
+	}
+	if stats := client.Stats(); stats.Throttled != 1 || stats.Retries != 1 {
+		t.Fatalf("Expected one throttled retry, got %+v", stats)
+	}
+}
+
+// Test that server errors are retried MaxRetries times and then reported
+func TestClient_GivesUpOnServerErrors(t *testing.T) {
+	server, calls := throttlingWiki(t, 100, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "backend down", http.StatusBadGateway)
+	})
+	defer server.Close()
+
+	client := newThrottleClient(server.URL)
+	client.MaxRetries = 3
+	err := client.CategoryMembers(context.Background(), "Video games", func(wiki.CategoryMember) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("Expected a 502 error, got %v", err)
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("Expected 1 request and 3 retries, got %d requests", calls.Load())
+	}
+	if stats := client.Stats(); stats.Throttled != 0 || stats.Retries != 3 {
+		t.Fatalf("Expected 3 unthrottled retries, got %+v", stats)
+	}
+}
+
+// Test that client errors other than 429 are not retried
+func TestClient_NoRetryOnClientError(t *testing.T) {
+	server, calls := throttlingWiki(t, 100, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+	defer server.Close()
+
+	client := newThrottleClient(server.URL)
+	if err := client.CategoryMembers(context.Background(), "Video games", func(wiki.CategoryMember) error { return nil }); err == nil {
+		t.Fatalf("Expected a 403 error")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("Expected a single request, got %d", calls.Load())
+	}
+}
+
+// Test that the token bucket spaces requests out once the burst is used up
+func TestRateLimiter(t *testing.T) {
+	limiter := wiki.NewRateLimiter(20, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := limiter.Wait(ctx); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	// 2 requests from the burst, then 4 at 20 per second
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Fatalf("Expected about 200ms of waiting, took %s", elapsed)
+	}
+
+	// A pause holds back every request and Wait honours cancellation
+	limiter.Pause(time.Hour)
+	cancelled, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(cancelled); err == nil {
+		t.Fatalf("Expected Wait to fail while paused")
+	}
+}